@@ -1,6 +1,19 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/config"
+	"github.com/webforspeed/bono/internal/doctor"
+	"github.com/webforspeed/bono/internal/permissions"
+	"github.com/webforspeed/bono/internal/session"
+	"github.com/webforspeed/bono/tui"
+)
 
 func TestParseCLIArgsHeadlessPrompt(t *testing.T) {
 	opts, err := parseCLIArgs([]string{"-p", "Find and fix the bug"})
@@ -61,3 +74,316 @@ func TestParseCLIArgsSkipApprovals(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCLIArgsOutputFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "default text", args: []string{"-p", "hi"}, want: "text"},
+		{name: "stream json", args: []string{"-p", "hi", "--output-format", "stream-json"}, want: "stream-json"},
+		{name: "single json", args: []string{"-p", "hi", "--output-format", "json"}, want: "json"},
+		{name: "unknown format", args: []string{"-p", "hi", "--output-format", "yaml"}, wantErr: true},
+		{name: "json requires prompt", args: []string{"--output-format", "json"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := parseCLIArgs(tc.args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseCLIArgs error = nil, want non-nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCLIArgs returned error: %v", err)
+			}
+			if opts.OutputFormat != tc.want {
+				t.Fatalf("OutputFormat = %q, want %q", opts.OutputFormat, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseCLIArgsResumeAndContinueAreExclusive(t *testing.T) {
+	if _, err := parseCLIArgs([]string{"--resume", "abc", "--continue"}); err == nil {
+		t.Fatalf("parseCLIArgs error = nil, want non-nil")
+	}
+	opts, err := parseCLIArgs([]string{"--resume", "20260101-120000-abcdef"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs returned error: %v", err)
+	}
+	if opts.Resume != "20260101-120000-abcdef" {
+		t.Fatalf("Resume = %q, want %q", opts.Resume, "20260101-120000-abcdef")
+	}
+}
+
+func TestParseCLIArgsUndo(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantBatch int
+		wantErr   bool
+	}{
+		{name: "bare undo", args: []string{"--undo"}, wantBatch: 0},
+		{name: "numbered undo", args: []string{"--undo=3"}, wantBatch: 3},
+		{name: "zero batch", args: []string{"--undo=0"}, wantErr: true},
+		{name: "with history", args: []string{"--undo", "--history"}, wantErr: true},
+		{name: "with prompt", args: []string{"--undo", "-p", "hi"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := parseCLIArgs(tc.args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseCLIArgs error = nil, want non-nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCLIArgs returned error: %v", err)
+			}
+			if !opts.Undo || opts.UndoBatch != tc.wantBatch {
+				t.Fatalf("Undo = %v, UndoBatch = %d, want true, %d", opts.Undo, opts.UndoBatch, tc.wantBatch)
+			}
+		})
+	}
+}
+
+func TestParseCLIArgsPatchOut(t *testing.T) {
+	opts, err := parseCLIArgs([]string{"-p", "fix it", "--patch-out", "fix.patch"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs returned error: %v", err)
+	}
+	if opts.PatchOut != "fix.patch" {
+		t.Fatalf("PatchOut = %q, want %q", opts.PatchOut, "fix.patch")
+	}
+	if _, err := parseCLIArgs([]string{"--patch-out", "fix.patch"}); err == nil {
+		t.Fatalf("--patch-out without -p: error = nil, want non-nil")
+	}
+}
+
+func TestParseCLIArgsConfigFlags(t *testing.T) {
+	opts, err := parseCLIArgs([]string{"--model", "a/b", "--set", "limits.max_chat_turns=5", "--set", "web.search_engine=native"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs returned error: %v", err)
+	}
+	flags := opts.ConfigFlags()
+	if len(flags) != 3 {
+		t.Fatalf("ConfigFlags = %+v, want 3 flags", flags)
+	}
+	if flags[0].Key != "model" || flags[0].Value != "a/b" || flags[1].Key != "limits.max_chat_turns" || flags[1].Value != "5" {
+		t.Fatalf("ConfigFlags = %+v", flags)
+	}
+	if _, err := parseCLIArgs([]string{"--set", "model"}); err == nil {
+		t.Fatalf("--set without '=': error = nil, want non-nil")
+	}
+}
+
+func TestLoadProfileAppliesRoutingAndRefusesRestartKeys(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, env := range []string{"MODEL", "BASE_URL", "BONO_PROFILE", "BONO_FALLBACK_MODELS"} {
+		t.Setenv(env, "")
+	}
+	cwd := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(cwd, ".bono"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settings := "[profiles.local]\nmodel = \"ollama/qwen\"\nreasoning = \"none\"\n\n" +
+		"[profiles.local.fallback]\nmodels = \"openai/gpt-5\"\n\n" +
+		"[profiles.local.roles.plan]\nmodel = \"ollama/qwen\"\n\n" +
+		"[profiles.deep.limits]\nmax_chat_turns = 10\n"
+	if err := os.WriteFile(filepath.Join(cwd, config.ProjectFile), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseCLIArgs([]string{"--profile", "missing"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs returned error: %v", err)
+	}
+	if flags := opts.ConfigFlags(); len(flags) != 1 || flags[0].Key != "profile" || flags[0].Value != "missing" {
+		t.Fatalf("ConfigFlags = %+v", flags)
+	}
+	proj := project{dir: cwd, trusted: true}
+	current, err := config.Load(cwd, proj.configOptions(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	models := []tui.ModelInfo{{ID: "ollama/qwen", BaseURL: "http://localhost:11434/v1", IsLocal: true}}
+	var applied *config.Config
+	p, err := loadProfile(proj, current, models, opts, "local", func(next *config.Config) { applied = next })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Model != "ollama/qwen" || p.BaseURL != "http://localhost:11434/v1" || p.Reasoning != "none" {
+		t.Errorf("profile = %+v", p)
+	}
+	if p.Apply == nil {
+		t.Fatal("profile has no Apply")
+	}
+	p.Apply()
+	if applied == nil || fallbackPolicy(applied, models).Models[0] != "openai/gpt-5" || roleRoutes(applied, models).Routes[session.RolePlan].Model != "ollama/qwen" {
+		t.Errorf("applied config = %+v", applied)
+	}
+
+	if _, err := loadProfile(proj, current, models, opts, "deep", nil); err == nil || !strings.Contains(err.Error(), "limits.max_chat_turns") {
+		t.Errorf("restart-only profile: error = %v, want it refused", err)
+	}
+	if _, err := loadProfile(proj, current, models, opts, "nope", nil); err == nil {
+		t.Error("unknown profile: error = nil, want non-nil")
+	}
+}
+
+func TestFallbackPolicy(t *testing.T) {
+	cfg := config.Default()
+	cfg.Fallback.Models = "openai/gpt-5, ,qwen3:8b"
+	models := []tui.ModelInfo{
+		{ID: "openai/gpt-5", BaseURL: tui.OpenRouterBaseURL},
+		{ID: "qwen3:8b", BaseURL: "http://127.0.0.1:11434/v1", IsLocal: true},
+	}
+	f := fallbackPolicy(cfg, models)
+	if strings.Join(f.Models, ",") != "openai/gpt-5,qwen3:8b" || f.Retries != 2 || f.Backoff != 2*time.Second {
+		t.Fatalf("policy = %+v", f)
+	}
+	if got := f.BaseURL("qwen3:8b"); got != "http://127.0.0.1:11434/v1" {
+		t.Errorf("local model base URL = %s", got)
+	}
+	if got := f.BaseURL("unknown/model"); got != tui.OpenRouterBaseURL {
+		t.Errorf("unknown model base URL = %s", got)
+	}
+	cfg.BaseURL = "https://gateway.example/v1"
+	if got, local := f.BaseURL("openai/gpt-5"), f.BaseURL("qwen3:8b"); got != cfg.BaseURL || local != "http://127.0.0.1:11434/v1" {
+		t.Errorf("with base_url set: remote = %s, local = %s", got, local)
+	}
+}
+
+func TestRoleRoutes(t *testing.T) {
+	cfg := config.Default()
+	cfg.Roles.Explore = config.Role{Model: "qwen3:8b", Reasoning: "none"}
+	cfg.Roles.Plan = config.Role{Reasoning: "xhigh"}
+	models := []tui.ModelInfo{{ID: "qwen3:8b", BaseURL: "http://127.0.0.1:11434/v1", IsLocal: true}}
+	roles := roleRoutes(cfg, models)
+	if len(roles.Routes) != 2 {
+		t.Fatalf("routes = %v, want explore and plan only", roles.Routes)
+	}
+	if r := roles.Routes[session.RoleExplore]; r.Model != "qwen3:8b" || r.Reasoning != "none" {
+		t.Errorf("explore route = %+v", r)
+	}
+	if r := roles.Routes[session.RolePlan]; r.Model != "" || r.Reasoning != "xhigh" {
+		t.Errorf("plan route = %+v", r)
+	}
+	if got := roles.BaseURL("qwen3:8b"); got != "http://127.0.0.1:11434/v1" {
+		t.Errorf("explore base URL = %s", got)
+	}
+}
+
+func TestProjectFilesNeedTrustForPermissions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".bono"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, permissions.FileName), []byte("allow run_shell *\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := projectFiles(dir); len(got) != 1 || got[0] != permissions.FileName {
+		t.Errorf("projectFiles = %v, want the permissions file to need trust", got)
+	}
+}
+
+func TestLookupCommand(t *testing.T) {
+	for _, name := range []string{"index", "models", "config", "doctor", "version", "help"} {
+		if cmd, ok := lookupCommand([]string{name, "--flag"}); !ok || cmd.Name != name {
+			t.Fatalf("lookupCommand(%q) = %q, %v", name, cmd.Name, ok)
+		}
+	}
+	for _, args := range [][]string{nil, {"-p", "index"}, {"indexes"}} {
+		if _, ok := lookupCommand(args); ok {
+			t.Fatalf("lookupCommand(%q) found a command", args)
+		}
+	}
+	if _, err := parseCLIArgs([]string{"indexes"}); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("parseCLIArgs(indexes) error = %v, want unknown command", err)
+	}
+}
+
+func TestCommandErrorsPrintRegisteredUsage(t *testing.T) {
+	var out strings.Builder
+	if code := runTrustCommand(&out, []string{"--deny", "--reset"}); code != 2 {
+		t.Fatalf("exit = %d, want 2", code)
+	}
+	cmd, _ := lookupCommand([]string{"trust"})
+	if !strings.Contains(out.String(), "Usage: "+cmd.Usage+"\n") {
+		t.Errorf("output %q does not show the registered usage %q", out.String(), cmd.Usage)
+	}
+}
+
+func TestRunVersionAndConfigCommands(t *testing.T) {
+	var out strings.Builder
+	if code := runVersionCommand(&out, nil); code != 0 || !strings.HasPrefix(out.String(), "bono "+version) {
+		t.Fatalf("version: code %d, output %q", code, out.String())
+	}
+
+	out.Reset()
+	if code := runConfigCommand(&out, []string{"edit"}); code != 2 || !strings.Contains(out.String(), "Usage: bono config show") {
+		t.Fatalf("config edit: code %d, output %q", code, out.String())
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	out.Reset()
+	if code := runConfigCommand(&out, []string{"show", "--set", "limits.max_chat_turns=9"}); code != 0 {
+		t.Fatalf("config show: code %d, output %q", code, out.String())
+	}
+	if !strings.Contains(out.String(), "limits.max_chat_turns = 9") {
+		t.Fatalf("config show output missing override:\n%s", out.String())
+	}
+}
+
+func TestIndexProgressThrottlesWithinPhase(t *testing.T) {
+	var out strings.Builder
+	p := indexProgress{out: &out}
+	p.update(core.CodeSearchIndexProgress{Phase: "scan", FilesDone: 0, FilesTotal: 10})
+	p.update(core.CodeSearchIndexProgress{Phase: "scan", FilesDone: 5, FilesTotal: 10})
+	p.update(core.CodeSearchIndexProgress{Phase: "scan", FilesDone: 10, FilesTotal: 10})
+	p.update(core.CodeSearchIndexProgress{Phase: "embed", FilesDone: 1, FilesTotal: 10})
+	want := "Indexing: scan (0/10 files)\nIndexing: scan (10/10 files)\nIndexing: embed (1/10 files)\n"
+	if out.String() != want {
+		t.Fatalf("progress output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCheckIndexReportsStaleness(t *testing.T) {
+	cwd := t.TempDir()
+	stats := func() (int, int, error) { return 2, 10, nil }
+	if status, detail := checkIndex(cwd, ".bono/index.db", nil, stats); status != doctor.Warn || !strings.Contains(detail, "no index") {
+		t.Fatalf("missing index: %s %q", status, detail)
+	}
+
+	write := func(rel string, mod time.Time) {
+		path := filepath.Join(cwd, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexed := time.Now().Add(-time.Hour)
+	write("main.go", indexed.Add(-time.Minute))
+	write(".bono/index.db", indexed)
+	write("logs/bono.jsonl", time.Now())
+	if status, detail := checkIndex(cwd, ".bono/index.db", []string{"logs/bono.jsonl"}, stats); status != doctor.Pass {
+		t.Fatalf("fresh index: %s %q", status, detail)
+	}
+
+	write("main.go", time.Now())
+	if status, detail := checkIndex(cwd, ".bono/index.db", []string{"logs/bono.jsonl"}, stats); status != doctor.Warn || !strings.Contains(detail, "1 files changed") {
+		t.Fatalf("stale index: %s %q", status, detail)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/config"
+	"github.com/webforspeed/bono/tui"
+)
+
+// command is a bono subcommand. Run gets the arguments after the command
+// name and returns the exit code.
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(out io.Writer, args []string) int
+}
+
+// commands lists the subcommands. Anything else on the command line is
+// parsed by parseCLIArgs and starts the TUI or a headless run.
+func commands() []command {
+	return []command{
+		{Name: "index", Usage: "bono index [--profile <name>] [--model <id>] [--set key=value]", Summary: "Index the codebase for semantic search", Run: runIndexCommand},
+		{Name: "models", Usage: "bono models [--json] [--refresh]", Summary: "List available models, including local ones", Run: runModelsCommand},
+		{Name: "config", Usage: "bono config show [--profile <name>] [--model <id>] [--set key=value]", Summary: "Show the effective configuration", Run: runConfigCommand},
+		{Name: "doctor", Usage: "bono doctor [--json] [--profile <name>] [--model <id>] [--set key=value]", Summary: "Check the environment and report problems", Run: runDoctorCommand},
+		{Name: "trust", Usage: "bono trust [--deny | --reset]", Summary: "Trust this folder's .env, config, hooks and permissions", Run: runTrustCommand},
+		{Name: "version", Usage: "bono version", Summary: "Print the bono version", Run: runVersionCommand},
+		{Name: "help", Usage: "bono help", Summary: "Show this help", Run: runHelpCommand},
+	}
+}
+
+// lookupCommand returns the subcommand named by the first argument, if any.
+func lookupCommand(args []string) (command, bool) {
+	if len(args) == 0 {
+		return command{}, false
+	}
+	for _, cmd := range commands() {
+		if cmd.Name == args[0] {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// commandUsage returns the Usage line of the named subcommand, so help and
+// error output share one copy.
+func commandUsage(name string) string {
+	cmd, _ := lookupCommand([]string{name})
+	return cmd.Usage
+}
+
+// commandFlags returns a flag set for a subcommand that reports its own
+// errors, with the config override flags defined when opts is non-nil.
+func commandFlags(name string, opts *cliOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("bono "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if opts != nil {
+		addConfigFlags(fs, opts)
+	}
+	return fs
+}
+
+// parseCommandFlags parses args and rejects positional arguments. It prints
+// the error and usage and returns false on failure.
+func parseCommandFlags(out io.Writer, fs *flag.FlagSet, usage string, args []string) bool {
+	err := fs.Parse(args)
+	if err == nil && fs.NArg() > 0 {
+		err = fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error parsing arguments: %v\nUsage: %s\n", err, usage)
+		return false
+	}
+	return true
+}
+
+func workingDir() string {
+	cwd, _ := os.Getwd()
+	if cwd == "" {
+		cwd = "."
+	}
+	return cwd
+}
+
+// runIndexCommand implements `bono index`: it builds or refreshes the code
+// search index without starting the TUI, printing progress as plain lines so
+// CI logs stay readable.
+func runIndexCommand(out io.Writer, args []string) int {
+	usage := commandUsage("index")
+	var opts cliOptions
+	if !parseCommandFlags(out, commandFlags("index", &opts), usage, args) {
+		return 2
+	}
+	proj, err := openProject(workingDir(), false, out)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading project: %v\n", err)
+		return 1
+	}
+	cfg, err := config.Load(proj.dir, proj.configOptions(opts.ConfigFlags()))
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		return 1
+	}
+
+	agent, err := core.NewAgent(cfg.Core(cfg.Model, ""))
+	if err != nil {
+		fmt.Fprintf(out, "Error creating agent: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if err := agent.Close(); err != nil {
+			fmt.Fprintf(out, "Warning: failed to close agent resources: %v\n", err)
+		}
+	}()
+	if err := agent.CodeSearchInitError(); err != nil {
+		fmt.Fprintf(out, "Error: code search unavailable: %v\n", err)
+		return 1
+	}
+	svc := agent.CodeSearchService()
+	if svc == nil {
+		fmt.Fprintln(out, "Error: code search engine not initialized. Check configuration.")
+		return 1
+	}
+	if !svc.CodeSearchSupportsVector() {
+		fmt.Fprintln(out, "Warning: sqlite-vec unavailable; indexing for text-only search.")
+	}
+
+	logger, closeLog := openLogger(cfg.Log.Path, out)
+	defer closeLog()
+	dispatcher, flushWebhooks, err := newDispatcher(proj, logger, out)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading hooks: %v\n", err)
+		return 1
+	}
+	defer flushWebhooks()
+
+	ctx := context.Background()
+	root, _ := filepath.Abs(".")
+	dispatcher.Fire(ctx, hooks.IndexStart, hooks.IndexStartPayload{Root: root})
+	progress := indexProgress{out: out}
+	stats, err := svc.CodeSearchIndex(ctx, ".", core.CodeSearchIndexOptions{}, progress.update)
+	dispatcher.Fire(ctx, hooks.IndexComplete, hooks.IndexCompletePayload{
+		Root:            root,
+		TotalFiles:      stats.TotalFiles,
+		TotalChunks:     stats.TotalChunks,
+		DurationSeconds: stats.Duration.Seconds(),
+		Err:             err,
+	})
+	drainHooks(dispatcher, out)
+
+	if err != nil {
+		fmt.Fprintf(out, "Error: indexing failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "● Index complete: %d chunks across %d files (%.1fs)\n", stats.TotalChunks, stats.TotalFiles, stats.Duration.Seconds())
+	return 0
+}
+
+// indexProgress prints a line when the indexing phase changes and at most
+// once a second within a phase.
+type indexProgress struct {
+	out     io.Writer
+	phase   string
+	printed time.Time
+}
+
+func (p *indexProgress) update(u core.CodeSearchIndexProgress) {
+	now := time.Now()
+	if u.Phase == p.phase && now.Sub(p.printed) < time.Second && u.FilesDone != u.FilesTotal {
+		return
+	}
+	p.phase, p.printed = u.Phase, now
+	fmt.Fprintf(p.out, "Indexing: %s (%d/%d files)\n", u.Phase, u.FilesDone, u.FilesTotal)
+}
+
+// drainHooks waits for async hook handlers and prints the errors they
+// reported.
+func drainHooks(dispatcher *hooks.Dispatcher, out io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), hooks.DefaultDrainTimeout)
+	defer cancel()
+	_ = dispatcher.Drain(ctx)
+	for {
+		select {
+		case err := <-dispatcher.Errors():
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		default:
+			return
+		}
+	}
+}
+
+// runModelsCommand implements `bono models`.
+func runModelsCommand(out io.Writer, args []string) int {
+	usage := commandUsage("models")
+	fs := commandFlags("models", nil)
+	asJSON := fs.Bool("json", false, "print the catalog as JSON")
+	refresh := fs.Bool("refresh", false, "fetch pricing and context lengths from OpenRouter now")
+	if !parseCommandFlags(out, fs, usage, args) {
+		return 2
+	}
+	proj, err := openProject(workingDir(), false, io.Discard)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading project: %v\n", err)
+		return 1
+	}
+	cfg, err := config.Load(proj.dir, proj.configOptions(nil))
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	opts, err := catalogOptions(proj, cfg)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		return 1
+	}
+	if *refresh {
+		opts.Refresh, opts.Force = true, true
+	}
+	models, providers, err := tui.LoadModelCatalog(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(models); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPROVIDER\tCONTEXT\tPRICE/1M\tWHERE")
+	for _, m := range models {
+		where := "remote"
+		if m.IsLocal {
+			where = "local"
+		}
+		price := "-"
+		if m.Pricing != nil {
+			price = m.Pricing.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", m.ID, m.Name, m.Provider, m.ContextLabel(), price, where)
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	for _, s := range providers {
+		if s.Err != nil {
+			fmt.Fprintf(out, "Warning: %s\n", s)
+		}
+	}
+	return 0
+}
+
+// runConfigCommand implements `bono config show`.
+func runConfigCommand(out io.Writer, args []string) int {
+	usage := commandUsage("config")
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintf(out, "Usage: %s\n", usage)
+		return 2
+	}
+	var opts cliOptions
+	if !parseCommandFlags(out, commandFlags("config show", &opts), usage, args[1:]) {
+		return 2
+	}
+	proj, err := openProject(workingDir(), false, out)
+	if err != nil {
+		fmt.Fprintf(out, "Error loading project: %v\n", err)
+		return 1
+	}
+	cfg, err := config.Load(proj.dir, proj.configOptions(opts.ConfigFlags()))
+	if err != nil {
+		fmt.Fprintf(out, "Error loading config: %v\n", err)
+		return 1
+	}
+	if err := cfg.Show(out); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runVersionCommand implements `bono version`.
+func runVersionCommand(out io.Writer, args []string) int {
+	if !parseCommandFlags(out, commandFlags("version", nil), "bono version", args) {
+		return 2
+	}
+	fmt.Fprintf(out, "bono %s (prompt %s, %s, %s/%s)\n", version, systemPromptVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// runHelpCommand implements `bono help`.
+func runHelpCommand(out io.Writer, args []string) int {
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  bono [flags]                 start the interactive TUI")
+	fmt.Fprintln(out, "  bono -p <prompt> [flags]     run one prompt headless")
+	fmt.Fprintln(out, "  bono <command> [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Name, cmd.Summary)
+	}
+	_ = tw.Flush()
+	return 0
+}
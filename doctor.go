@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/config"
+	"github.com/webforspeed/bono/internal/doctor"
+	"github.com/webforspeed/bono/internal/trust"
+	"github.com/webforspeed/bono/tui"
+)
+
+// runDoctorCommand implements `bono doctor`. It exits 1 when any check fails.
+func runDoctorCommand(out io.Writer, args []string) int {
+	usage := commandUsage("doctor")
+	var opts cliOptions
+	fs := commandFlags("doctor", &opts)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if !parseCommandFlags(out, fs, usage, args) {
+		return 2
+	}
+
+	cwd := workingDir()
+	ctx := context.Background()
+	proj, cfgErr := openProject(cwd, false, io.Discard)
+	cfg := config.Default()
+	if cfgErr == nil {
+		if loaded, err := config.Load(cwd, proj.configOptions(opts.ConfigFlags())); err == nil {
+			cfg = loaded
+		} else {
+			cfgErr = err
+		}
+	}
+	catalog, catalogErr := catalogOptions(proj, cfg)
+	var models []tui.ModelInfo
+	if catalogErr == nil {
+		models, _, catalogErr = tui.LoadModelCatalog(ctx, catalog)
+	}
+	if catalogErr != nil && cfgErr == nil {
+		cfgErr = catalogErr
+	}
+	model := resolveModel(cfg, models)
+	agent, agentErr := core.NewAgent(cfg.Core(model, ""))
+	if agentErr == nil {
+		defer agent.Close()
+	}
+
+	results := doctor.Run(ctx, doctorChecks(proj, cfg, cfgErr, model, catalog.Providers, agent, agentErr))
+	write := doctor.Write
+	if *asJSON {
+		write = doctor.WriteJSON
+	}
+	if err := write(out, results); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	if doctor.Failed(results) {
+		return 1
+	}
+	return 0
+}
+
+// doctorChecks lists the checks in report order. agent is nil when agentErr
+// is set.
+func doctorChecks(proj project, cfg *config.Config, cfgErr error, model string, providers []tui.Provider, agent *core.Agent, agentErr error) []doctor.Check {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = tui.OpenRouterBaseURL
+	}
+	needAgent := func(run func(ctx context.Context) (doctor.Status, string)) func(ctx context.Context) (doctor.Status, string) {
+		return func(ctx context.Context) (doctor.Status, string) {
+			if agent == nil {
+				return doctor.Fail, fmt.Sprintf("agent unavailable: %v", agentErr)
+			}
+			return run(ctx)
+		}
+	}
+
+	cwd := proj.dir
+	checks := []doctor.Check{
+		{Name: "folder trust", Run: func(context.Context) (doctor.Status, string) {
+			files := strings.Join(proj.files, ", ")
+			switch {
+			case len(proj.files) == 0:
+				return doctor.Pass, "no project files"
+			case proj.trusted:
+				return doctor.Pass, "trusted; loading " + files
+			case proj.trust == trust.Denied:
+				return doctor.Warn, "denied; ignoring " + files + " (run bono trust to load them)"
+			default:
+				return doctor.Warn, "not trusted yet; ignoring " + files + " (run bono trust to load them)"
+			}
+		}},
+		{Name: "config", Run: func(context.Context) (doctor.Status, string) {
+			if cfgErr != nil {
+				return doctor.Fail, cfgErr.Error()
+			}
+			return doctor.Pass, configSummary(cfg)
+		}},
+		{Name: "prompt template", Run: func(context.Context) (doctor.Status, string) {
+			version := promptVersion(cfg)
+			if _, err := loadSystemPrompt(cwd, version); err != nil {
+				return doctor.Fail, fmt.Sprintf("%s: %v", version, err)
+			}
+			return doctor.Pass, version
+		}},
+		{Name: "api key", Run: func(ctx context.Context) (doctor.Status, string) {
+			if cfg.APIKey == "" {
+				return doctor.Warn, "OPENROUTER_API_KEY not set; only local models will work"
+			}
+			status, detail := doctor.Authorized("api key", keyCheckURL(baseURL), cfg.APIKey).Run(ctx)
+			return status, "set (" + cfg.Source("api_key") + "); " + detail
+		}},
+		doctor.Reachable("base url", strings.TrimSuffix(baseURL, "/")+"/models", doctor.Fail),
+		{Name: "model limits", Run: needAgent(func(ctx context.Context) (doctor.Status, string) {
+			if err := agent.WarmModelUsageLimits(ctx, model); err != nil {
+				return doctor.Warn, fmt.Sprintf("%s: context usage unknown until the first response: %v", model, err)
+			}
+			return doctor.Pass, model
+		})},
+		{Name: "code search", Run: needAgent(func(context.Context) (doctor.Status, string) {
+			if err := agent.CodeSearchInitError(); err != nil {
+				return doctor.Fail, err.Error()
+			}
+			svc := agent.CodeSearchService()
+			if svc == nil {
+				return doctor.Fail, "code search engine not initialized"
+			}
+			if !svc.CodeSearchSupportsVector() {
+				return doctor.Warn, "sqlite-vec unavailable; search is text-only"
+			}
+			return doctor.Pass, "sqlite-vec loaded"
+		})},
+		{Name: "index", Run: needAgent(func(context.Context) (doctor.Status, string) {
+			svc := agent.CodeSearchService()
+			if svc == nil {
+				return doctor.Fail, "code search engine not initialized"
+			}
+			return checkIndex(cwd, cfg.Index.DBPath, []string{cfg.Log.Path}, func() (int, int, error) {
+				stats, err := svc.CodeSearchStats()
+				return stats.TotalFiles, stats.TotalChunks, err
+			})
+		})},
+		{Name: "sandbox", Run: func(context.Context) (doctor.Status, string) {
+			if !core.IsSandboxEnabled() {
+				return doctor.Warn, "unavailable; shell commands run unsandboxed after approval"
+			}
+			return doctor.Pass, "enabled"
+		}},
+		doctor.Executable("git", "git", doctor.Warn),
+		doctor.Writable("log directory", filepath.Dir(cfg.Log.Path)),
+	}
+	for _, p := range providers {
+		checks = append(checks, doctor.Reachable("local: "+p.Name, p.ModelsURL(), doctor.Warn))
+	}
+	return checks
+}
+
+// keyCheckURL returns an endpoint that needs a valid API key. OpenRouter
+// lists models without one, so its key endpoint is used instead.
+func keyCheckURL(baseURL string) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if strings.HasPrefix(baseURL, tui.OpenRouterBaseURL) {
+		return baseURL + "/key"
+	}
+	return baseURL + "/models"
+}
+
+// configSummary lists the files that contributed to cfg.
+func configSummary(cfg *config.Config) string {
+	var files []string
+	for _, key := range config.Keys() {
+		src := cfg.Source(key)
+		if src != config.SourceDefault && !strings.HasPrefix(src, "env ") && !strings.HasPrefix(src, "flag ") && !slices.Contains(files, src) {
+			files = append(files, src)
+		}
+	}
+	if len(files) == 0 {
+		return "defaults only"
+	}
+	return strings.Join(files, ", ")
+}
+
+// checkIndex reports whether the index at dbPath exists, is readable and is
+// newer than every workspace file. Files in skip, like the log, are ignored
+// when looking for changes.
+func checkIndex(cwd, dbPath string, skip []string, stats func() (files, chunks int, err error)) (doctor.Status, string) {
+	if !filepath.IsAbs(dbPath) {
+		dbPath = filepath.Join(cwd, dbPath)
+	}
+	indexed, ok := modTime(dbPath, dbPath+"-wal")
+	if !ok {
+		return doctor.Warn, "no index; run bono index"
+	}
+	files, chunks, err := stats()
+	if err != nil {
+		return doctor.Fail, fmt.Sprintf("%s unreadable: %v", dbPath, err)
+	}
+	if chunks == 0 {
+		return doctor.Warn, "index is empty; run bono index"
+	}
+
+	exclude := []string{dbPath, dbPath + "-wal", dbPath + "-shm"}
+	for _, p := range skip {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		exclude = append(exclude, filepath.Clean(p))
+	}
+	paths, err := changebatch.ListWorkspace(cwd)
+	if err != nil {
+		return doctor.Warn, fmt.Sprintf("%d files indexed %s; staleness unknown: %v", files, ago(indexed), err)
+	}
+	changed := 0
+	for _, rel := range paths {
+		abs := filepath.Join(cwd, filepath.FromSlash(rel))
+		if slices.Contains(exclude, abs) {
+			continue
+		}
+		if t, ok := modTime(abs); ok && t.After(indexed) {
+			changed++
+		}
+	}
+	if changed > 0 {
+		return doctor.Warn, fmt.Sprintf("%d files changed since the last index %s; run bono index", changed, ago(indexed))
+	}
+	return doctor.Pass, fmt.Sprintf("%d files, %d chunks, indexed %s", files, chunks, ago(indexed))
+}
+
+// modTime returns the latest modification time of the paths that exist.
+func modTime(paths ...string) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			found = true
+			if info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+		}
+	}
+	return latest, found
+}
+
+func ago(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,175 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultCommandTimeout bounds a command hook that sets no timeout.
+const DefaultCommandTimeout = 60 * time.Second
+
+// blockExitCode is the exit status a command uses to block an event. Its
+// stderr becomes the reason shown to the user.
+const blockExitCode = 2
+
+// CommandHook runs a shell command for an event. The event's Envelope is
+// written to the command's stdin as JSON.
+//
+// Exit status 0 lets the event proceed. Its stdout may be a JSON object
+// {"decision": "block", "reason": "...", "context": "..."}; for
+// UserPromptSubmit, plain stdout is added to the prompt as context. Exit
+// status 2 blocks the event with stderr as the reason. Any other failure,
+// including a timeout, is reported but does not block unless OnError is
+// BlockOnError.
+type CommandHook struct {
+	Event   Event
+	Command string
+	Matcher string // regexp matched against the whole tool name; empty or "*" matches all
+	Timeout time.Duration
+	Dir     string
+	Async   bool // run without holding up the agent; the hook cannot block
+	OnError ErrorPolicy
+
+	matcher *regexp.Regexp
+}
+
+// NewCommandHook validates the matcher and returns a hook for event.
+func NewCommandHook(event Event, command, matcher string, timeout time.Duration, dir string) (*CommandHook, error) {
+	h := &CommandHook{Event: event, Command: command, Matcher: matcher, Timeout: timeout, Dir: dir}
+	if matcher != "" && matcher != "*" {
+		re, err := regexp.Compile("^(?:" + matcher + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %w", matcher, err)
+		}
+		h.matcher = re
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = DefaultCommandTimeout
+	}
+	return h, nil
+}
+
+// Options returns the dispatcher options the hook should be registered with.
+func (h *CommandHook) Options() []Option {
+	opts := []Option{OnError(h.OnError)}
+	if h.Async {
+		opts = append(opts, Async())
+	}
+	return opts
+}
+
+func (h *CommandHook) Handle(ctx context.Context, event Event, payload any) {
+	h.Decide(ctx, event, payload)
+}
+
+// commandOutput is the optional JSON a command prints on stdout.
+type commandOutput struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	Context  string `json:"context"`
+}
+
+func (h *CommandHook) Decide(ctx context.Context, event Event, payload any) Decision {
+	if !h.matches(payload) {
+		return Decision{}
+	}
+	input, err := h.input(ctx, event, payload)
+	if err != nil {
+		return Decision{Err: h.errorf("encode payload: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, h.Command)
+	cmd.Dir = h.Dir
+	cmd.Env = append(os.Environ(), "BONO_HOOK_EVENT="+string(event), "BONO_PROJECT_DIR="+h.Dir)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return Decision{Err: h.errorf("timed out after %s", h.Timeout)}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == blockExitCode {
+			reason := strings.TrimSpace(stderr.String())
+			if reason == "" {
+				reason = "blocked by hook"
+			}
+			return Decision{Block: true, Reason: reason}
+		}
+		return Decision{Err: h.errorf("%v: %s", err, strings.TrimSpace(stderr.String()))}
+	}
+	if err != nil {
+		return Decision{Err: h.errorf("%w", err)}
+	}
+	return parseOutput(event, strings.TrimSpace(stdout.String()))
+}
+
+func parseOutput(event Event, out string) Decision {
+	if strings.HasPrefix(out, "{") {
+		var parsed commandOutput
+		if err := json.Unmarshal([]byte(out), &parsed); err == nil {
+			return Decision{
+				Block:   parsed.Decision == "block",
+				Reason:  parsed.Reason,
+				Context: parsed.Context,
+			}
+		}
+	}
+	if event == UserPromptSubmit {
+		return Decision{Context: out}
+	}
+	return Decision{}
+}
+
+func (h *CommandHook) errorf(format string, args ...any) error {
+	return fmt.Errorf("command %q: "+format, append([]any{h.Command}, args...)...)
+}
+
+// matches reports whether the hook applies to a tool payload. Events without
+// a tool name always match.
+func (h *CommandHook) matches(payload any) bool {
+	if h.matcher == nil {
+		return true
+	}
+	var name string
+	switch p := payload.(type) {
+	case ToolPayload:
+		name = p.ToolName
+	case ToolResultPayload:
+		name = p.ToolName
+	case PermissionPayload:
+		name = p.ToolName
+	default:
+		return true
+	}
+	return h.matcher.MatchString(name)
+}
+
+func (h *CommandHook) input(ctx context.Context, event Event, payload any) ([]byte, error) {
+	env := EnvelopeFrom(ctx, event, payload)
+	if env.CWD == "" {
+		env.CWD = h.Dir
+	}
+	return json.Marshal(env)
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,118 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHook(t *testing.T, event Event, command, matcher string) *CommandHook {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("command hook tests use sh")
+	}
+	h, err := NewCommandHook(event, command, matcher, 5*time.Second, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestCommandHookReceivesPayloadOnStdin(t *testing.T) {
+	h := newTestHook(t, PreToolUse, "cat > payload.json", "")
+	d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "run_shell", Args: map[string]any{"command": "ls"}})
+	if d.Err != nil || d.Block {
+		t.Fatalf("decision = %+v", d)
+	}
+	data, err := os.ReadFile(filepath.Join(h.Dir, "payload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"event":"PreToolUse"`, `"tool_name":"run_shell"`, `"command":"ls"`, `"cwd":`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("payload %s missing %s", data, want)
+		}
+	}
+}
+
+func TestCommandHookExitTwoBlocks(t *testing.T) {
+	h := newTestHook(t, PreToolUse, "echo 'writes to .env are not allowed' >&2; exit 2", "")
+	dispatcher := NewDispatcher()
+	dispatcher.On(PreToolUse, h)
+	d := dispatcher.Fire(context.Background(), PreToolUse, ToolPayload{ToolName: "write_file"})
+	if !d.Block || d.Reason != "writes to .env are not allowed" {
+		t.Fatalf("decision = %+v, want block with stderr reason", d)
+	}
+}
+
+func TestCommandHookStdoutDecisions(t *testing.T) {
+	h := newTestHook(t, UserPromptSubmit, `echo '{"decision":"block","reason":"off topic"}'`, "")
+	if d := h.Decide(context.Background(), UserPromptSubmit, UserPromptSubmitPayload{Input: "hi"}); !d.Block || d.Reason != "off topic" {
+		t.Fatalf("decision = %+v, want JSON block", d)
+	}
+
+	h = newTestHook(t, UserPromptSubmit, "echo 'branch: main'", "")
+	if d := h.Decide(context.Background(), UserPromptSubmit, UserPromptSubmitPayload{Input: "hi"}); d.Block || d.Context != "branch: main" {
+		t.Fatalf("decision = %+v, want plain stdout as context", d)
+	}
+}
+
+func TestCommandHookMatcher(t *testing.T) {
+	h := newTestHook(t, PreToolUse, "exit 2", "write_file|edit_file")
+	if d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "read_file"}); d.Block {
+		t.Fatalf("read_file blocked by matcher %q", h.Matcher)
+	}
+	if d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "edit_file"}); !d.Block {
+		t.Fatalf("edit_file not blocked by matcher %q", h.Matcher)
+	}
+	if d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "edit_file_v2"}); d.Block {
+		t.Fatalf("matcher %q is not anchored", h.Matcher)
+	}
+}
+
+func TestCommandHookFailuresDoNotBlock(t *testing.T) {
+	h := newTestHook(t, PreToolUse, "sleep 5", "")
+	h.Timeout = 50 * time.Millisecond
+	d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "run_shell"})
+	if d.Block || d.Err == nil || !strings.Contains(d.Err.Error(), "timed out") {
+		t.Fatalf("decision = %+v, want non-blocking timeout error", d)
+	}
+
+	h = newTestHook(t, PreToolUse, "exit 1", "")
+	if d := h.Decide(context.Background(), PreToolUse, ToolPayload{ToolName: "run_shell"}); d.Block || d.Err == nil {
+		t.Fatalf("decision = %+v, want non-blocking error", d)
+	}
+}
+
+func TestLoadCommandHooks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cwd := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(cwd, ".bono"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"hooks": {"PreToolUse": [{"matcher": "run_shell", "command": "./check", "timeout": 2}]}}`
+	if err := os.WriteFile(filepath.Join(cwd, SettingsFile), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := LoadCommandHooks(cwd, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].Event != PreToolUse || hooks[0].Command != "./check" || hooks[0].Timeout != 2*time.Second || hooks[0].Dir != cwd {
+		t.Fatalf("hooks = %+v", hooks)
+	}
+	if hooks, err := LoadCommandHooks(cwd, false); err != nil || len(hooks) != 0 {
+		t.Fatalf("untrusted project: hooks = %+v, err = %v", hooks, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(cwd, SettingsFile), []byte(`{"hooks": {"PreToolUs": []}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCommandHooks(cwd, true); err == nil || !strings.Contains(err.Error(), "PreToolUs") {
+		t.Fatalf("err = %v, want unknown event error", err)
+	}
+}
@@ -0,0 +1,136 @@
+package hooks
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// SchemaVersion is the envelope format version. It changes only when a field
+// is removed or changes meaning; new fields and events keep the version.
+const SchemaVersion = 1
+
+// Schema is the JSON Schema for Envelope, published as event.schema.json.
+//
+//go:embed event.schema.json
+var Schema []byte
+
+// Envelope wraps a hook event with the session state it happened in. It is
+// the format written to logs/bono.jsonl, sent on stdin to command hooks and
+// POSTed by webhooks.
+type Envelope struct {
+	Version    int       `json:"version"`
+	Event      Event     `json:"event"`
+	SessionID  string    `json:"session_id,omitempty"`
+	TurnID     int       `json:"turn_id"`                // 1 for the first prompt; 0 before it
+	ToolCallID string    `json:"tool_call_id,omitempty"` // tool events only
+	Timestamp  time.Time `json:"timestamp"`
+	CWD        string    `json:"cwd,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	Payload    any       `json:"payload"`
+}
+
+// SessionInfo supplies the session-level envelope fields. The functions are
+// called on every Fire, so the values may change during a run.
+type SessionInfo struct {
+	CWD       string
+	SessionID func() string
+	Model     func() string
+}
+
+type envelopeKey struct{}
+type toolCallIDKey struct{}
+
+// EnvelopeFrom returns the envelope the dispatcher attached to ctx, or a
+// bare one for handlers called outside Fire.
+func EnvelopeFrom(ctx context.Context, event Event, payload any) Envelope {
+	if env, ok := ctx.Value(envelopeKey{}).(Envelope); ok {
+		return env
+	}
+	return Envelope{Version: SchemaVersion, Event: event, Timestamp: time.Now().UTC(), Payload: payload}
+}
+
+// WithToolCallID tags events fired with ctx as belonging to one tool call.
+func WithToolCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, toolCallIDKey{}, id)
+}
+
+// NewToolCallID returns a random identifier for WithToolCallID.
+func NewToolCallID() string {
+	return "call_" + newID()
+}
+
+// SetSession sets where the dispatcher reads session-level envelope fields.
+func (d *Dispatcher) SetSession(info SessionInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.session = info
+}
+
+// envelope builds the envelope for an event. UserPromptSubmit starts a new
+// turn; a new session ID starts counting again.
+func (d *Dispatcher) envelope(ctx context.Context, event Event, payload any) Envelope {
+	d.mu.Lock()
+	info := d.session
+	var sessionID string
+	if info.SessionID != nil {
+		sessionID = info.SessionID()
+	}
+	if sessionID != d.turnSession {
+		d.turnSession, d.turn = sessionID, 0
+	}
+	if event == UserPromptSubmit {
+		d.turn++
+	}
+	turn := d.turn
+	d.mu.Unlock()
+
+	env := Envelope{
+		Version:   SchemaVersion,
+		Event:     event,
+		SessionID: sessionID,
+		TurnID:    turn,
+		Timestamp: time.Now().UTC(),
+		CWD:       info.CWD,
+		Payload:   payload,
+	}
+	if info.Model != nil {
+		env.Model = info.Model()
+	}
+	env.ToolCallID, _ = ctx.Value(toolCallIDKey{}).(string)
+	return env
+}
+
+// MarshalJSON includes Err as an "error" string.
+func (p StopPayload) MarshalJSON() ([]byte, error) {
+	type plain StopPayload
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain(p), errorString(p.Err)})
+}
+
+// MarshalJSON includes Err as an "error" string.
+func (p IndexCompletePayload) MarshalJSON() ([]byte, error) {
+	type plain IndexCompletePayload
+	return json.Marshal(struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain(p), errorString(p.Err)})
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
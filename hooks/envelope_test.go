@@ -0,0 +1,166 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFireAttachesEnvelope(t *testing.T) {
+	d := NewDispatcher()
+	d.SetSession(SessionInfo{
+		CWD:       "/work",
+		SessionID: func() string { return "s1" },
+		Model:     func() string { return "openai/gpt-5" },
+	})
+	var got []Envelope
+	record := HandlerFunc(func(ctx context.Context, event Event, payload any) {
+		got = append(got, EnvelopeFrom(ctx, event, payload))
+	})
+	d.On(UserPromptSubmit, record)
+	d.On(PreToolUse, record)
+
+	ctx := context.Background()
+	d.Fire(ctx, UserPromptSubmit, UserPromptSubmitPayload{Input: "one"})
+	d.Fire(WithToolCallID(ctx, "call_1"), PreToolUse, ToolPayload{ToolName: "read_file"})
+	d.Fire(ctx, UserPromptSubmit, UserPromptSubmitPayload{Input: "two"})
+
+	if len(got) != 3 {
+		t.Fatalf("got %d envelopes", len(got))
+	}
+	tool := got[1]
+	if tool.Version != SchemaVersion || tool.SessionID != "s1" || tool.CWD != "/work" || tool.Model != "openai/gpt-5" {
+		t.Fatalf("envelope = %+v", tool)
+	}
+	if tool.TurnID != 1 || tool.ToolCallID != "call_1" || tool.Timestamp.IsZero() {
+		t.Fatalf("envelope = %+v, want turn 1 and call_1", tool)
+	}
+	if got[2].TurnID != 2 || got[2].ToolCallID != "" {
+		t.Fatalf("second prompt envelope = %+v", got[2])
+	}
+}
+
+func TestPayloadErrorsMarshalAsStrings(t *testing.T) {
+	data, err := json.Marshal(StopPayload{Response: "partial", Err: errors.New("rate limited")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"response":"partial","error":"rate limited"}` {
+		t.Fatalf("StopPayload = %s", data)
+	}
+	data, err = json.Marshal(StopPayload{Response: "done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "error") {
+		t.Fatalf("StopPayload without error = %s", data)
+	}
+}
+
+// samplePayloads has one payload per event, with every optional field set.
+var samplePayloads = map[Event]any{
+	SessionStart:        SessionStartPayload{},
+	UserPromptSubmit:    UserPromptSubmitPayload{Input: "hi"},
+	PreToolUse:          ToolPayload{ToolName: "read_file", Args: map[string]any{"path": "a"}},
+	PermissionRequest:   PermissionPayload{ToolName: "run_shell"},
+	SandboxFallback:     SandboxFallbackPayload{Command: "ls", Reason: "denied", Approved: true},
+	PostToolUse:         ToolResultPayload{ToolName: "read_file", Status: "ok", Success: true},
+	PostToolUseFailure:  ToolResultPayload{ToolName: "read_file", Status: "failed"},
+	SubAgentStart:       SubAgentPayload{Name: "explore"},
+	SubAgentStop:        SubAgentPayload{Name: "explore"},
+	PreCompact:          PreCompactPayload{Trigger: CompactTriggerAgent},
+	Stop:                StopPayload{Response: "done", Err: errors.New("x")},
+	ChangeBatchDecision: ChangeBatchDecisionPayload{Decision: BatchApproved, Files: []string{"a"}, Kept: []string{"a"}},
+	Notification:        NotificationPayload{Kind: NotificationIdle, Message: "waiting"},
+	ModelChanged:        ModelChangedPayload{From: "a", To: "b", Reason: ModelChangeFallback},
+	ReasoningChanged:    ReasoningChangedPayload{From: "", To: "high"},
+	IndexStart:          IndexStartPayload{Root: "/work"},
+	IndexComplete:       IndexCompletePayload{Root: "/work", TotalFiles: 1, Err: errors.New("x")},
+	SessionEnd:          SessionEndPayload{},
+}
+
+type schemaObject struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+func TestSchemaMatchesEnvelopeAndPayloads(t *testing.T) {
+	var schema struct {
+		schemaObject
+		AllOf []struct {
+			If struct {
+				Properties struct {
+					Event struct {
+						Const Event `json:"const"`
+					} `json:"event"`
+				} `json:"properties"`
+			} `json:"if"`
+			Then struct {
+				Properties struct {
+					Payload struct {
+						Ref string `json:"$ref"`
+					} `json:"payload"`
+				} `json:"properties"`
+			} `json:"then"`
+		} `json:"allOf"`
+		Defs map[string]schemaObject `json:"$defs"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+
+	envFields := jsonFields(t, Envelope{ToolCallID: "c", SessionID: "s", CWD: "/", Model: "m"})
+	checkFields(t, "envelope", schema.schemaObject, envFields)
+
+	refs := map[Event]string{}
+	for _, rule := range schema.AllOf {
+		refs[rule.If.Properties.Event.Const] = strings.TrimPrefix(rule.Then.Properties.Payload.Ref, "#/$defs/")
+	}
+	for _, event := range Events {
+		def, ok := schema.Defs[refs[event]]
+		if !ok {
+			t.Errorf("%s: no payload schema", event)
+			continue
+		}
+		payload, ok := samplePayloads[event]
+		if !ok {
+			t.Errorf("%s: no sample payload", event)
+			continue
+		}
+		checkFields(t, string(event), def, jsonFields(t, payload))
+	}
+}
+
+func jsonFields(t *testing.T, v any) []string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func checkFields(t *testing.T, name string, schema schemaObject, fields []string) {
+	t.Helper()
+	for _, f := range fields {
+		if _, ok := schema.Properties[f]; !ok {
+			t.Errorf("%s: field %q missing from schema", name, f)
+		}
+	}
+	for _, r := range schema.Required {
+		if !slices.Contains(fields, r) {
+			t.Errorf("%s: required field %q not marshaled", name, r)
+		}
+	}
+}
@@ -12,8 +12,47 @@ const (
 	PostToolUseFailure Event = "PostToolUseFailure"
 	PermissionRequest  Event = "PermissionRequest"
 	Stop               Event = "Stop"
+
+	SubAgentStart       Event = "SubAgentStart"
+	SubAgentStop        Event = "SubAgentStop"
+	PreCompact          Event = "PreCompact"
+	ModelChanged        Event = "ModelChanged"
+	ReasoningChanged    Event = "ReasoningChanged"
+	ChangeBatchDecision Event = "ChangeBatchDecision"
+	IndexStart          Event = "IndexStart"
+	IndexComplete       Event = "IndexComplete"
+	SandboxFallback     Event = "SandboxFallback"
+	Notification        Event = "Notification"
 )
 
+// Events lists every hook point in lifecycle order.
+var Events = []Event{
+	SessionStart,
+	UserPromptSubmit,
+	PreToolUse,
+	PermissionRequest,
+	SandboxFallback,
+	PostToolUse,
+	PostToolUseFailure,
+	SubAgentStart,
+	SubAgentStop,
+	PreCompact,
+	Stop,
+	ChangeBatchDecision,
+	Notification,
+	ModelChanged,
+	ReasoningChanged,
+	IndexStart,
+	IndexComplete,
+	SessionEnd,
+}
+
+// Blockable reports whether handlers may veto the event: PreToolUse stops
+// the tool call, UserPromptSubmit rejects the prompt.
+func (e Event) Blockable() bool {
+	return e == PreToolUse || e == UserPromptSubmit
+}
+
 // Payload structs — one per event that carries data.
 // Handlers type-assert the payload to access fields.
 
@@ -22,27 +61,107 @@ type SessionStartPayload struct{}
 type SessionEndPayload struct{}
 
 type UserPromptSubmitPayload struct {
-	Input string
+	Input string `json:"input"`
 }
 
 type ToolPayload struct {
-	ToolName string
-	Args     map[string]any
+	ToolName string         `json:"tool_name"`
+	Args     map[string]any `json:"args"`
 }
 
 type ToolResultPayload struct {
-	ToolName string
-	Args     map[string]any
-	Status   string
-	Success  bool
+	ToolName string         `json:"tool_name"`
+	Args     map[string]any `json:"args"`
+	Status   string         `json:"status"`
+	Success  bool           `json:"success"`
 }
 
 type PermissionPayload struct {
-	ToolName string
-	Args     map[string]any
+	ToolName string         `json:"tool_name"`
+	Args     map[string]any `json:"args"`
 }
 
 type StopPayload struct {
-	Response string
-	Err      error
+	Response string `json:"response"`
+	Err      error  `json:"-"`
+}
+
+type SubAgentPayload struct {
+	Name string `json:"name"`
+}
+
+// Compaction triggers.
+const (
+	CompactTriggerAgent = "agent" // the model called compact_context
+)
+
+// PreCompactPayload is sent before the context is compacted. Only the
+// compact_context tool fires it: bono-core reports no callback for
+// compaction it starts on its own, so that goes unseen by hooks.
+type PreCompactPayload struct {
+	Trigger string `json:"trigger"`
+}
+
+// Model change reasons. A change made by the user has no reason.
+const (
+	ModelChangeFallback = "fallback" // a failed request moved to a fallback model, or back
+	ModelChangeRole     = "role"     // a role such as plan moved to its own model, or back
+)
+
+type ModelChangedPayload struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReasoningChangedPayload carries reasoning effort levels; "" means disabled.
+type ReasoningChangedPayload struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Change batch decisions.
+const (
+	BatchApproved     = "approved"      // every change kept
+	BatchPartial      = "partial"       // some files or hunks reverted in review
+	BatchRejected     = "rejected"      // the batch was undone
+	BatchAutoApproved = "auto_approved" // approvals are skipped
+)
+
+// ChangeBatchDecisionPayload reports how a change batch was resolved. Kept
+// lists the files that still carry some of the agent's changes.
+type ChangeBatchDecisionPayload struct {
+	Decision string   `json:"decision"`
+	Files    []string `json:"files"`
+	Kept     []string `json:"kept"`
+}
+
+type IndexStartPayload struct {
+	Root string `json:"root"`
+}
+
+type IndexCompletePayload struct {
+	Root            string  `json:"root"`
+	TotalFiles      int     `json:"total_files"`
+	TotalChunks     int     `json:"total_chunks"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Err             error   `json:"-"`
+}
+
+type SandboxFallbackPayload struct {
+	Command  string `json:"command"`
+	Reason   string `json:"reason"`
+	Approved bool   `json:"approved"`
+}
+
+// Notification kinds other than the session's approval kinds.
+const (
+	NotificationIdle = "idle" // a turn finished and bono waits for the next prompt
+)
+
+// NotificationPayload is sent whenever bono waits on the user. Kind is an
+// approval kind such as "tool" or "change_batch", or NotificationIdle.
+type NotificationPayload struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
 }
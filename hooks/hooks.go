@@ -2,9 +2,12 @@ package hooks
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Handler processes a hook event. Implementations should be fast and non-blocking.
@@ -19,39 +22,267 @@ func (f HandlerFunc) Handle(ctx context.Context, event Event, payload any) {
 	f(ctx, event, payload)
 }
 
-// Dispatcher manages handler registration and event dispatch.
+// Decision is a handler's verdict on an event. Block is honoured only for
+// events where Blockable reports true; Context is extra text for the model
+// and is used by UserPromptSubmit. Err carries non-fatal handler failures.
+type Decision struct {
+	Block   bool
+	Reason  string
+	Context string
+	Err     error
+}
+
+// Decider is implemented by handlers that can block an event or add context
+// to it. The dispatcher calls Decide instead of Handle for them.
+type Decider interface {
+	Handler
+	Decide(ctx context.Context, event Event, payload any) Decision
+}
+
+// WithContext prepends hook-provided context to a prompt.
+func WithContext(prompt, context string) string {
+	if context == "" {
+		return prompt
+	}
+	return context + "\n\n" + prompt
+}
+
+// HandlerError reports a handler that failed, panicked or timed out.
+type HandlerError struct {
+	Event Event
+	Err   error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("%s hook: %v", e.Event, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error { return e.Err }
+
+// Dispatcher queue sizes and the time SessionEnd waits for async handlers.
+const (
+	asyncQueueSize      = 256
+	errorQueueSize      = 64
+	DefaultDrainTimeout = 5 * time.Second
+)
+
+// Dispatcher manages handler registration and event dispatch. Handler
+// failures, panics and timeouts are sent to Errors instead of being printed,
+// so frontends can show them without corrupting the terminal.
 type Dispatcher struct {
 	mu       sync.RWMutex
-	handlers map[Event][]Handler
+	handlers map[Event][]*registration
+	errors   chan error
+
+	session     SessionInfo
+	turn        int
+	turnSession string // session ID the turn count belongs to
+
+	pendingMu sync.Mutex
+	pending   int
+	idle      chan struct{} // closed when pending drops to zero
 }
 
 // NewDispatcher creates an empty dispatcher.
 func NewDispatcher() *Dispatcher {
-	return &Dispatcher{handlers: make(map[Event][]Handler)}
+	return &Dispatcher{
+		handlers: make(map[Event][]*registration),
+		errors:   make(chan error, errorQueueSize),
+	}
 }
 
-// On registers one or more handlers for an event.
+// On registers one or more handlers for an event with default options: they
+// run synchronously, in registration order, and report errors.
 func (d *Dispatcher) On(event Event, h ...Handler) {
+	for _, handler := range h {
+		d.Register(event, handler)
+	}
+}
+
+// Register adds a handler for an event. Handlers run in descending priority;
+// equal priorities keep registration order.
+func (d *Dispatcher) Register(event Event, h Handler, opts ...Option) {
+	r := &registration{handler: h}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.async {
+		r.queue = make(chan asyncJob, asyncQueueSize)
+		go d.runAsync(r)
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.handlers[event] = append(d.handlers[event], h...)
+	regs := append(d.handlers[event], r)
+	sort.SliceStable(regs, func(i, j int) bool { return regs[i].priority > regs[j].priority })
+	d.handlers[event] = regs
+}
+
+// Errors returns the channel handler errors are reported on. Errors are
+// dropped when nobody reads them and the buffer is full.
+func (d *Dispatcher) Errors() <-chan error {
+	return d.errors
 }
 
-// Fire dispatches an event to all registered handlers.
-// Panics in handlers are recovered and printed to stderr.
-func (d *Dispatcher) Fire(ctx context.Context, event Event, payload any) {
+// Fire dispatches an event to its handlers and combines the decisions of the
+// synchronous ones. When a Decider blocks a blockable event, the remaining
+// synchronous handlers are skipped. Async handlers are queued either way, so
+// observers such as webhooks also see blocked events, and Fire does not wait
+// for them, except for SessionEnd, which drains every async queue first.
+// Handlers can read the event's Envelope with EnvelopeFrom.
+func (d *Dispatcher) Fire(ctx context.Context, event Event, payload any) Decision {
 	d.mu.RLock()
-	hs := d.handlers[event]
+	regs := d.handlers[event]
 	d.mu.RUnlock()
+	ctx = context.WithValue(ctx, envelopeKey{}, d.envelope(ctx, event, payload))
 
-	for _, h := range hs {
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					fmt.Fprintf(os.Stderr, "hook panic [%s]: %v\n", event, r)
-				}
-			}()
-			h.Handle(ctx, event, payload)
+	var result Decision
+	var contexts []string
+	var errs []error
+	for _, r := range regs {
+		if r.async {
+			d.enqueue(ctx, r, event, payload)
+			continue
+		}
+		if result.Block {
+			continue
+		}
+		decision := d.call(ctx, r, event, payload)
+		if decision.Err != nil {
+			errs = append(errs, decision.Err)
+		}
+		if decision.Context != "" {
+			contexts = append(contexts, decision.Context)
+		}
+		if decision.Block && event.Blockable() {
+			result.Block, result.Reason = true, decision.Reason
+		}
+	}
+	result.Context = strings.Join(contexts, "\n\n")
+	result.Err = errors.Join(errs...)
+
+	if event == SessionEnd {
+		drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultDrainTimeout)
+		defer cancel()
+		if err := d.Drain(drainCtx); err != nil {
+			d.report(event, fmt.Errorf("async handlers still running at exit: %w", err))
+		}
+	}
+	return result
+}
+
+// Drain waits until every queued async event has been handled or ctx ends.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	d.pendingMu.Lock()
+	if d.pending == 0 {
+		d.pendingMu.Unlock()
+		return nil
+	}
+	idle := d.idle
+	d.pendingMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// call runs one handler, applying its timeout and error policy. Panics are
+// recovered and reported like errors.
+func (d *Dispatcher) call(ctx context.Context, r *registration, event Event, payload any) Decision {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	done := make(chan Decision, 1)
+	run := func() {
+		var decision Decision
+		defer func() {
+			if p := recover(); p != nil {
+				decision = Decision{Err: fmt.Errorf("panic: %v", p)}
+			}
+			done <- decision
 		}()
+		if decider, ok := r.handler.(Decider); ok {
+			decision = decider.Decide(ctx, event, payload)
+		} else {
+			r.handler.Handle(ctx, event, payload)
+		}
+	}
+
+	var decision Decision
+	if r.timeout > 0 {
+		// A handler that ignores its context is abandoned, not waited for.
+		go run()
+		select {
+		case decision = <-done:
+		case <-ctx.Done():
+			decision = Decision{Err: fmt.Errorf("timed out after %s", r.timeout)}
+		}
+	} else {
+		run()
+		decision = <-done
+	}
+
+	if decision.Err == nil {
+		return decision
+	}
+	switch r.onError {
+	case IgnoreErrors:
+		decision.Err = nil
+	case BlockOnError:
+		d.report(event, decision.Err)
+		decision.Block, decision.Reason = true, "hook failed: "+decision.Err.Error()
+	default:
+		d.report(event, decision.Err)
+	}
+	return decision
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, r *registration, event Event, payload any) {
+	d.addPending()
+	select {
+	case r.queue <- asyncJob{ctx: context.WithoutCancel(ctx), event: event, payload: payload}:
+	default:
+		d.donePending()
+		if r.onError != IgnoreErrors {
+			d.report(event, errors.New("async handler queue full; event dropped"))
+		}
+	}
+}
+
+func (d *Dispatcher) runAsync(r *registration) {
+	for job := range r.queue {
+		d.call(job.ctx, r, job.event, job.payload)
+		d.donePending()
+	}
+}
+
+func (d *Dispatcher) report(event Event, err error) {
+	select {
+	case d.errors <- &HandlerError{Event: event, Err: err}:
+	default:
+	}
+}
+
+func (d *Dispatcher) addPending() {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	if d.pending == 0 {
+		d.idle = make(chan struct{})
+	}
+	d.pending++
+}
+
+func (d *Dispatcher) donePending() {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	d.pending--
+	if d.pending == 0 {
+		close(d.idle)
 	}
 }
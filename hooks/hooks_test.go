@@ -0,0 +1,135 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type decideFunc func(ctx context.Context, event Event, payload any) Decision
+
+func (f decideFunc) Handle(ctx context.Context, event Event, payload any) { f(ctx, event, payload) }
+
+func (f decideFunc) Decide(ctx context.Context, event Event, payload any) Decision {
+	return f(ctx, event, payload)
+}
+
+func nextError(t *testing.T, d *Dispatcher) error {
+	t.Helper()
+	select {
+	case err := <-d.Errors():
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+		return nil
+	}
+}
+
+func TestDispatcherRunsHandlersByPriority(t *testing.T) {
+	d := NewDispatcher()
+	var order []string
+	record := func(name string) Handler {
+		return HandlerFunc(func(context.Context, Event, any) { order = append(order, name) })
+	}
+	d.Register(Stop, record("low"), Priority(-1))
+	d.On(Stop, record("first"), record("second"))
+	d.Register(Stop, record("high"), Priority(10))
+
+	d.Fire(context.Background(), Stop, StopPayload{})
+
+	if got := strings.Join(order, ","); got != "high,first,second,low" {
+		t.Fatalf("order = %s", got)
+	}
+}
+
+func TestDispatcherAsyncHandlersDrainOnSessionEnd(t *testing.T) {
+	d := NewDispatcher()
+	release := make(chan struct{})
+	var handled atomic.Int32
+	d.Register(PostToolUse, HandlerFunc(func(context.Context, Event, any) {
+		<-release
+		handled.Add(1)
+	}), Async())
+
+	start := time.Now()
+	for range 3 {
+		d.Fire(context.Background(), PostToolUse, ToolResultPayload{})
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Fire waited %s for an async handler", elapsed)
+	}
+	close(release)
+
+	d.Fire(context.Background(), SessionEnd, SessionEndPayload{})
+	if n := handled.Load(); n != 3 {
+		t.Fatalf("handled = %d after SessionEnd, want 3", n)
+	}
+}
+
+func TestDispatcherQueuesAsyncHandlersForBlockedEvents(t *testing.T) {
+	d := NewDispatcher()
+	var skipped, observed atomic.Int32
+	d.Register(PreToolUse, decideFunc(func(context.Context, Event, any) Decision {
+		return Decision{Block: true, Reason: "no"}
+	}), Priority(10))
+	d.On(PreToolUse, HandlerFunc(func(context.Context, Event, any) { skipped.Add(1) }))
+	d.Register(PreToolUse, HandlerFunc(func(context.Context, Event, any) { observed.Add(1) }), Async())
+
+	if decision := d.Fire(context.Background(), PreToolUse, ToolPayload{}); !decision.Block {
+		t.Fatalf("decision = %+v, want block", decision)
+	}
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if skipped.Load() != 0 || observed.Load() != 1 {
+		t.Fatalf("sync after block ran %d times, async ran %d times; want 0 and 1", skipped.Load(), observed.Load())
+	}
+}
+
+func TestDispatcherReportsPanicsAndTimeouts(t *testing.T) {
+	d := NewDispatcher()
+	d.On(Stop, HandlerFunc(func(context.Context, Event, any) { panic("boom") }))
+	d.Register(PostToolUse, HandlerFunc(func(context.Context, Event, any) { time.Sleep(time.Second) }), Timeout(20*time.Millisecond))
+
+	d.Fire(context.Background(), Stop, StopPayload{})
+	var handlerErr *HandlerError
+	if err := nextError(t, d); !errors.As(err, &handlerErr) || handlerErr.Event != Stop || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("err = %v, want Stop panic", err)
+	}
+
+	start := time.Now()
+	d.Fire(context.Background(), PostToolUse, ToolResultPayload{})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Fire waited %s past the handler timeout", elapsed)
+	}
+	if err := nextError(t, d); !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+}
+
+func TestDispatcherErrorPolicies(t *testing.T) {
+	failing := decideFunc(func(context.Context, Event, any) Decision {
+		return Decision{Err: errors.New("policy server down")}
+	})
+
+	d := NewDispatcher()
+	d.Register(PreToolUse, failing, OnError(BlockOnError))
+	if decision := d.Fire(context.Background(), PreToolUse, ToolPayload{}); !decision.Block {
+		t.Fatalf("decision = %+v, want fail-closed block", decision)
+	}
+	nextError(t, d)
+
+	d = NewDispatcher()
+	d.Register(PreToolUse, failing, OnError(IgnoreErrors))
+	if decision := d.Fire(context.Background(), PreToolUse, ToolPayload{}); decision.Block || decision.Err != nil {
+		t.Fatalf("decision = %+v, want ignored error", decision)
+	}
+	select {
+	case err := <-d.Errors():
+		t.Fatalf("ignored error reported: %v", err)
+	default:
+	}
+}
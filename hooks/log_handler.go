@@ -5,7 +5,7 @@ import (
 	"log/slog"
 )
 
-// LogHandler logs every hook event via a structured logger.
+// LogHandler logs the Envelope of every hook event via a structured logger.
 type LogHandler struct {
 	Logger *slog.Logger
 }
@@ -16,8 +16,5 @@ func NewLogHandler(logger *slog.Logger) *LogHandler {
 }
 
 func (h *LogHandler) Handle(ctx context.Context, event Event, payload any) {
-	h.Logger.InfoContext(ctx, "hook",
-		"event", string(event),
-		"payload", payload,
-	)
+	h.Logger.InfoContext(ctx, "hook", "envelope", EnvelopeFrom(ctx, event, payload))
 }
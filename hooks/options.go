@@ -0,0 +1,59 @@
+package hooks
+
+import (
+	"context"
+	"time"
+)
+
+// ErrorPolicy decides what happens when a handler fails, panics or times out.
+type ErrorPolicy int
+
+const (
+	// ReportErrors sends the failure to Dispatcher.Errors and carries on.
+	ReportErrors ErrorPolicy = iota
+	// IgnoreErrors drops the failure silently.
+	IgnoreErrors
+	// BlockOnError reports the failure and, for a synchronous handler on a
+	// blockable event, blocks the event: fail closed.
+	BlockOnError
+)
+
+// Option configures a handler registered with Dispatcher.Register.
+type Option func(*registration)
+
+// Async runs the handler on its own goroutine, in event order, so Fire does
+// not wait for it. Async handlers cannot block events or add context.
+func Async() Option {
+	return func(r *registration) { r.async = true }
+}
+
+// Priority orders handlers for an event; higher priorities run first.
+func Priority(p int) Option {
+	return func(r *registration) { r.priority = p }
+}
+
+// Timeout bounds each call. The handler's context is cancelled when it
+// expires, and a synchronous caller stops waiting for it.
+func Timeout(d time.Duration) Option {
+	return func(r *registration) { r.timeout = d }
+}
+
+// OnError sets the handler's error policy. The default is ReportErrors.
+func OnError(policy ErrorPolicy) Option {
+	return func(r *registration) { r.onError = policy }
+}
+
+type registration struct {
+	handler  Handler
+	async    bool
+	priority int
+	timeout  time.Duration
+	onError  ErrorPolicy
+	queue    chan asyncJob
+}
+
+type asyncJob struct {
+	ctx     context.Context
+	event   Event
+	payload any
+}
@@ -0,0 +1,212 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// SettingsFile is the project-level hook settings path, relative to the
+// workspace root.
+const SettingsFile = ".bono/hooks.json"
+
+// Settings is the layout of a hooks.json file:
+//
+//	{
+//	  "hooks": {
+//	    "PreToolUse": [
+//	      {"matcher": "write_file|edit_file", "command": "./scripts/check.sh", "timeout": 10}
+//	    ]
+//	  },
+//	  "webhooks": [
+//	    {"url": "https://example.com/bono", "events": ["Stop"], "secret": "$BONO_WEBHOOK_SECRET"}
+//	  ]
+//	}
+type Settings struct {
+	Hooks    map[Event][]CommandSpec `json:"hooks"`
+	Webhooks []WebhookSpec           `json:"webhooks"`
+}
+
+// CommandSpec configures one command hook. Timeout is in seconds. Async
+// defaults to false for events a hook can block and true for the rest.
+// OnError is "report" (the default), "ignore" or "block".
+type CommandSpec struct {
+	Matcher string  `json:"matcher,omitempty"`
+	Command string  `json:"command"`
+	Timeout float64 `json:"timeout,omitempty"`
+	Async   *bool   `json:"async,omitempty"`
+	OnError string  `json:"on_error,omitempty"`
+}
+
+var errorPolicies = map[string]ErrorPolicy{
+	"":       ReportErrors,
+	"report": ReportErrors,
+	"ignore": IgnoreErrors,
+	"block":  BlockOnError,
+}
+
+// WebhookSpec configures one webhook. An empty Events list subscribes to
+// every event. Environment variables in URL, Headers and Secret are expanded
+// so secrets can stay out of the file. Timeout is in seconds.
+type WebhookSpec struct {
+	URL         string            `json:"url"`
+	Events      []Event           `json:"events,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Secret      string            `json:"secret,omitempty"`
+	Timeout     float64           `json:"timeout,omitempty"`
+	MaxAttempts int               `json:"max_attempts,omitempty"`
+	QueueSize   int               `json:"queue_size,omitempty"`
+}
+
+// Webhook is a loaded webhook: the handler configuration and the events to
+// register it for.
+type Webhook struct {
+	Events []Event
+	Config WebhookConfig
+}
+
+// UserSettingsFile returns the user-level hook settings path
+// ($XDG_CONFIG_HOME/bono/hooks.json, defaulting to ~/.config/bono/hooks.json).
+func UserSettingsFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bono", "hooks.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bono", "hooks.json"), nil
+}
+
+// LoadCommandHooks reads the user-level and then, when project is set, the
+// project-level settings file and returns their command hooks in that order.
+// Commands run in cwd. Missing files are not an error; malformed ones are,
+// and the error names the file.
+func LoadCommandHooks(cwd string, project bool) ([]*CommandHook, error) {
+	all, err := loadSettings(cwd, project, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	var out []*CommandHook
+	for _, settings := range all {
+		// Iterate in lifecycle order so registration order is deterministic.
+		for _, event := range Events {
+			for _, spec := range settings.Hooks[event] {
+				timeout := time.Duration(spec.Timeout * float64(time.Second))
+				h, err := NewCommandHook(event, spec.Command, spec.Matcher, timeout, cwd)
+				if err != nil {
+					return nil, err // validated by readSettings
+				}
+				h.Async = !event.Blockable()
+				if spec.Async != nil {
+					h.Async = *spec.Async
+				}
+				h.OnError = errorPolicies[spec.OnError]
+				out = append(out, h)
+			}
+		}
+	}
+	return out, nil
+}
+
+// LoadWebhooks reads the same files as LoadCommandHooks and returns their
+// webhooks with environment variables expanded by getenv.
+func LoadWebhooks(cwd string, project bool, getenv func(string) string) ([]Webhook, error) {
+	all, err := loadSettings(cwd, project, getenv)
+	if err != nil {
+		return nil, err
+	}
+	var out []Webhook
+	for _, settings := range all {
+		for _, spec := range settings.Webhooks {
+			config := WebhookConfig{
+				URL:         os.Expand(spec.URL, getenv),
+				Secret:      os.Expand(spec.Secret, getenv),
+				Timeout:     time.Duration(spec.Timeout * float64(time.Second)),
+				MaxAttempts: spec.MaxAttempts,
+				QueueSize:   spec.QueueSize,
+			}
+			if len(spec.Headers) > 0 {
+				config.Headers = make(map[string]string, len(spec.Headers))
+				for k, v := range spec.Headers {
+					config.Headers[k] = os.Expand(v, getenv)
+				}
+			}
+			events := spec.Events
+			if len(events) == 0 {
+				events = Events
+			}
+			out = append(out, Webhook{Events: events, Config: config})
+		}
+	}
+	return out, nil
+}
+
+func loadSettings(cwd string, project bool, getenv func(string) string) ([]Settings, error) {
+	var files []string
+	if userFile, err := UserSettingsFile(); err == nil {
+		files = append(files, userFile)
+	}
+	if project {
+		files = append(files, filepath.Join(cwd, SettingsFile))
+	}
+
+	var out []Settings
+	for _, file := range files {
+		settings, err := readSettings(file, getenv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		out = append(out, settings)
+	}
+	return out, nil
+}
+
+func readSettings(file string, getenv func(string) string) (Settings, error) {
+	var settings Settings
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, err
+	}
+
+	for event, specs := range settings.Hooks {
+		if !slices.Contains(Events, event) {
+			return settings, fmt.Errorf("unknown hook event %q", event)
+		}
+		for i, spec := range specs {
+			if spec.Command == "" {
+				return settings, fmt.Errorf("%s[%d]: command is required", event, i)
+			}
+			if _, err := NewCommandHook(event, spec.Command, spec.Matcher, 0, ""); err != nil {
+				return settings, fmt.Errorf("%s[%d]: %w", event, i, err)
+			}
+			if _, ok := errorPolicies[spec.OnError]; !ok {
+				return settings, fmt.Errorf("%s[%d]: on_error must be report, ignore or block", event, i)
+			}
+		}
+	}
+	for i, spec := range settings.Webhooks {
+		u, err := url.Parse(os.Expand(spec.URL, getenv))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return settings, fmt.Errorf("webhooks[%d]: url must be an http or https URL", i)
+		}
+		for _, event := range spec.Events {
+			if !slices.Contains(Events, event) {
+				return settings, fmt.Errorf("webhooks[%d]: unknown hook event %q", i, event)
+			}
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,240 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Webhook defaults, used when the matching WebhookConfig field is zero.
+const (
+	DefaultWebhookQueueSize   = 256
+	DefaultWebhookMaxAttempts = 5
+	DefaultWebhookBackoff     = 500 * time.Millisecond
+	DefaultWebhookTimeout     = 10 * time.Second
+
+	maxWebhookBackoff = 30 * time.Second
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed
+// with "sha256=", when a webhook has a secret.
+const SignatureHeader = "X-Bono-Signature"
+
+// WebhookConfig configures a WebhookHandler.
+type WebhookConfig struct {
+	URL         string
+	Headers     map[string]string
+	Secret      string        // signs each body with HMAC-SHA256 when set
+	QueueSize   int           // deliveries waiting to be sent; further events are dropped
+	MaxAttempts int           // attempts per delivery, including the first
+	Backoff     time.Duration // delay before the first retry; doubles after each attempt
+	Timeout     time.Duration // per-request timeout
+	Client      *http.Client
+	Logger      *slog.Logger // delivery log; discarded when nil
+}
+
+// WebhookHandler POSTs events to an HTTP endpoint. Handle only encodes the
+// event and queues it; a background goroutine sends deliveries in order,
+// retrying network errors, 429s and 5xx responses with exponential backoff.
+// Every attempt is recorded in the delivery log.
+type WebhookHandler struct {
+	config WebhookConfig
+	client *http.Client
+	logger *slog.Logger
+	queue  chan webhookDelivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type webhookDelivery struct {
+	id    string
+	event Event
+	body  []byte
+}
+
+// NewWebhookHandler starts a handler's delivery goroutine. Call Close to
+// flush the queue and stop it.
+func NewWebhookHandler(config WebhookConfig) *WebhookHandler {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultWebhookQueueSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultWebhookBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultWebhookTimeout
+	}
+	h := &WebhookHandler{
+		config: config,
+		client: config.Client,
+		logger: config.Logger,
+		queue:  make(chan webhookDelivery, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	if h.client == nil {
+		h.client = http.DefaultClient
+	}
+	if h.logger == nil {
+		h.logger = slog.New(slog.DiscardHandler)
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	go h.run()
+	return h
+}
+
+// Handle queues the event's Envelope for delivery without waiting for the
+// network. When the queue is full the event is dropped and the drop is logged.
+func (h *WebhookHandler) Handle(ctx context.Context, event Event, payload any) {
+	id := newID()
+	body, err := json.Marshal(EnvelopeFrom(ctx, event, payload))
+	if err != nil {
+		h.logger.Error("webhook encode failed", "event", string(event), "url", h.config.URL, "error", err.Error())
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return
+	}
+	select {
+	case h.queue <- webhookDelivery{id: id, event: event, body: body}:
+	default:
+		h.logger.Warn("webhook queue full, event dropped", "delivery", id, "event", string(event), "url", h.config.URL)
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish.
+// If ctx ends first, pending retries are abandoned and ctx's error returned.
+func (h *WebhookHandler) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		<-h.done
+		return ctx.Err()
+	}
+}
+
+func (h *WebhookHandler) run() {
+	defer close(h.done)
+	defer h.cancel()
+	for d := range h.queue {
+		h.deliver(d)
+	}
+}
+
+func (h *WebhookHandler) deliver(d webhookDelivery) {
+	backoff := h.config.Backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		status, err := h.post(d)
+		attrs := []any{
+			"delivery", d.id,
+			"event", string(d.event),
+			"url", h.config.URL,
+			"attempt", attempt,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		if err == nil {
+			h.logger.Info("webhook delivered", attrs...)
+			return
+		}
+		attrs = append(attrs, "error", err.Error())
+		if !retryable(status, err) || attempt >= h.config.MaxAttempts {
+			h.logger.Error("webhook delivery failed", attrs...)
+			return
+		}
+		h.logger.Warn("webhook delivery retrying", append(attrs, "retry_in_ms", backoff.Milliseconds())...)
+
+		select {
+		case <-time.After(backoff):
+		case <-h.ctx.Done():
+			h.logger.Error("webhook delivery abandoned", "delivery", d.id, "event", string(d.event), "url", h.config.URL)
+			return
+		}
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+// post sends one attempt. It returns the HTTP status (0 when no response was
+// received) and an error unless the endpoint answered 2xx.
+func (h *WebhookHandler) post(d webhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(h.ctx, h.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	// Configured headers go first so they cannot replace bono's own,
+	// such as the signature.
+	for k, v := range h.config.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bono")
+	req.Header.Set("X-Bono-Event", string(d.event))
+	req.Header.Set("X-Bono-Delivery", d.id)
+	if h.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.config.Secret, d.body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// permanentError marks a failed attempt that no retry can fix, such as a
+// URL that does not parse.
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// retryable reports whether an attempt that ended with status and err is
+// worth repeating. Status 0 means the request never got a response.
+func retryable(status int, err error) bool {
+	if errors.As(err, new(permanentError)) {
+		return false
+	}
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,178 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliversSignedPayload(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- request{header: r.Header, body: body}
+	}))
+	defer srv.Close()
+
+	h := NewWebhookHandler(WebhookConfig{
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer token", SignatureHeader: "sha256=forged", "Content-Type": "text/plain"},
+		Secret:  "s3cret",
+	})
+	h.Handle(context.Background(), PreToolUse, ToolPayload{ToolName: "run_shell", Args: map[string]any{"command": "ls"}})
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-got
+	if sig := req.header.Get(SignatureHeader); sig != Sign("s3cret", req.body) {
+		t.Fatalf("signature = %q, want %q", sig, Sign("s3cret", req.body))
+	}
+	if req.header.Get("Authorization") != "Bearer token" || req.header.Get("X-Bono-Event") != "PreToolUse" || req.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", req.header)
+	}
+	if req.header.Get("X-Bono-Delivery") == "" {
+		t.Fatalf("missing delivery ID header")
+	}
+	var env struct {
+		Version int            `json:"version"`
+		Event   string         `json:"event"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal(req.body, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Version != SchemaVersion || env.Event != "PreToolUse" || env.Payload["tool_name"] != "run_shell" {
+		t.Fatalf("body = %s", req.body)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	h := NewWebhookHandler(WebhookConfig{URL: srv.URL, Backoff: time.Millisecond})
+	h.Handle(context.Background(), Stop, StopPayload{Response: "done"})
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("calls = %d, want 3", n)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	h := NewWebhookHandler(WebhookConfig{URL: srv.URL, Backoff: time.Millisecond})
+	h.Handle(context.Background(), Stop, StopPayload{})
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("calls = %d, want 1", n)
+	}
+}
+
+func TestWebhookDoesNotRetryBadURL(t *testing.T) {
+	var logs bytes.Buffer
+	h := NewWebhookHandler(WebhookConfig{
+		URL:     "http://[::1",
+		Backoff: time.Millisecond,
+		Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	h.Handle(context.Background(), Stop, StopPayload{})
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "retrying") || !strings.Contains(logs.String(), "webhook delivery failed") {
+		t.Fatalf("log = %s, want one failed attempt", logs.String())
+	}
+}
+
+func TestWebhookSlowEndpointDoesNotBlockHandle(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer once.Do(func() { close(release) })
+
+	h := NewWebhookHandler(WebhookConfig{URL: srv.URL, QueueSize: 2})
+	start := time.Now()
+	for range 10 {
+		h.Handle(context.Background(), PostToolUse, ToolResultPayload{ToolName: "read_file"})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Handle took %s with a stalled endpoint", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.Close(ctx); err == nil {
+		t.Fatalf("Close returned nil while deliveries were stuck")
+	}
+	once.Do(func() { close(release) })
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TEST_WEBHOOK_SECRET", "from-env")
+	cwd := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(cwd, ".bono"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	settings := `{"webhooks": [
+		{"url": "https://example.com/a", "secret": "$TEST_WEBHOOK_SECRET"},
+		{"url": "https://example.com/b", "events": ["Stop"]}
+	]}`
+	if err := os.WriteFile(filepath.Join(cwd, SettingsFile), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	webhooks, err := LoadWebhooks(cwd, true, os.Getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks) != 2 {
+		t.Fatalf("webhooks = %+v", webhooks)
+	}
+	if webhooks[0].Config.Secret != "from-env" || len(webhooks[0].Events) != len(Events) {
+		t.Fatalf("first webhook = %+v, want expanded secret and every event", webhooks[0])
+	}
+	if len(webhooks[1].Events) != 1 || webhooks[1].Events[0] != Stop {
+		t.Fatalf("second webhook events = %v", webhooks[1].Events)
+	}
+
+	if err := os.WriteFile(filepath.Join(cwd, SettingsFile), []byte(`{"webhooks": [{"url": "ftp://x"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadWebhooks(cwd, true, os.Getenv); err == nil {
+		t.Fatalf("LoadWebhooks accepted a non-http URL")
+	}
+}
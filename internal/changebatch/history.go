@@ -0,0 +1,193 @@
+package changebatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch is one approved change batch kept in the history.
+type Batch struct {
+	ID       string       `json:"id"`
+	Time     time.Time    `json:"time"`
+	Prompt   string       `json:"prompt,omitempty"`
+	Changes  []FileChange `json:"changes"`
+	UndoneAt time.Time    `json:"undone_at,omitzero"`
+}
+
+// Undone reports whether the batch has already been reverted.
+func (b Batch) Undone() bool {
+	return !b.UndoneAt.IsZero()
+}
+
+// Summary renders the batch as a single history line.
+func (b Batch) Summary() string {
+	paths := make([]string, 0, len(b.Changes))
+	for _, c := range b.Changes {
+		paths = append(paths, c.DisplayPath)
+	}
+	files := "1 file"
+	if len(paths) != 1 {
+		files = fmt.Sprintf("%d files", len(paths))
+	}
+	line := fmt.Sprintf("%s · %s (%s)", b.Time.Local().Format("2006-01-02 15:04"), files, strings.Join(paths, ", "))
+	if prompt := strings.Join(strings.Fields(b.Prompt), " "); prompt != "" {
+		if r := []rune(prompt); len(r) > 60 {
+			prompt = string(r[:57]) + "..."
+		}
+		line += fmt.Sprintf(" · %q", prompt)
+	}
+	return line
+}
+
+// History is a disk-backed ChangeLog with one JSON file per approved batch.
+// A nil History records nothing.
+type History struct {
+	dir string
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func NewHistory(dir string) *History {
+	return &History{dir: dir, now: time.Now}
+}
+
+// Dir returns the history directory.
+func (h *History) Dir() string {
+	return h.dir
+}
+
+func (h *History) RecordApprovedBatch(prompt string, changes []FileChange) error {
+	if h == nil || len(changes) == 0 {
+		return nil
+	}
+	now := h.now()
+	batch := Batch{
+		ID:      fmt.Sprintf("%s-%09d", now.Format("20060102-150405"), now.Nanosecond()),
+		Time:    now,
+		Prompt:  prompt,
+		Changes: changes,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.writeLocked(batch)
+}
+
+// List returns recorded batches, most recent first.
+func (h *History) List() ([]Batch, error) {
+	if h == nil {
+		return nil, fmt.Errorf("change history unavailable")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.listLocked()
+}
+
+// Get returns the n-th most recent batch (1-based, as numbered by List).
+func (h *History) Get(n int) (Batch, error) {
+	batches, err := h.List()
+	if err != nil {
+		return Batch{}, err
+	}
+	if n < 1 || n > len(batches) {
+		return Batch{}, fmt.Errorf("no batch %d (history has %d)", n, len(batches))
+	}
+	return batches[n-1], nil
+}
+
+// Undo reverts the n-th most recent batch (1-based, as numbered by List).
+// n == 0 picks the most recent batch that has not been undone yet. Edits
+// made since the batch are kept by merging; when any of them overlaps the
+// batch's changes, Undo touches nothing and returns a *ConflictError.
+func (h *History) Undo(n int) (Batch, error) {
+	if h == nil {
+		return Batch{}, fmt.Errorf("change history unavailable")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	batches, err := h.listLocked()
+	if err != nil {
+		return Batch{}, err
+	}
+	var batch Batch
+	switch {
+	case n == 0:
+		i := 0
+		for i < len(batches) && batches[i].Undone() {
+			i++
+		}
+		if i == len(batches) {
+			return Batch{}, fmt.Errorf("nothing to undo")
+		}
+		batch = batches[i]
+	case n < 0 || n > len(batches):
+		return Batch{}, fmt.Errorf("no batch %d (history has %d)", n, len(batches))
+	default:
+		batch = batches[n-1]
+		if batch.Undone() {
+			return Batch{}, fmt.Errorf("batch %d was already undone", n)
+		}
+	}
+
+	plans, conflicts, err := planUndo(batch.Changes)
+	if err != nil {
+		return Batch{}, err
+	}
+	if err := conflictError(conflicts); err != nil {
+		return Batch{}, err
+	}
+	if err := applyReverts(plans); err != nil {
+		return Batch{}, err
+	}
+	batch.UndoneAt = h.now()
+	return batch, h.writeLocked(batch)
+}
+
+func (h *History) listLocked() ([]Batch, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var batches []Batch
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(h.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var b Batch
+		if err := json.Unmarshal(data, &b); err != nil {
+			continue
+		}
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].ID > batches[j].ID })
+	return batches, nil
+}
+
+func (h *History) writeLocked(batch Batch) error {
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(h.dir, batch.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,128 @@
+package changebatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func recordEdit(t *testing.T, h *History, cwd, name, before, after string) {
+	t.Helper()
+	path := filepath.Join(cwd, name)
+	if err := os.WriteFile(path, []byte(before), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewManager()
+	if _, err := mgr.BeginChange(cwd, "edit_file", name); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(after), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := mgr.CompleteChange("edit_file", name); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.RecordApprovedBatch("edit "+name, mgr.DrainCompleted()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestHistory(t *testing.T) *History {
+	t.Helper()
+	h := NewHistory(t.TempDir())
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return h
+}
+
+func TestHistoryListsNewestFirst(t *testing.T) {
+	cwd := t.TempDir()
+	h := newTestHistory(t)
+	recordEdit(t, h, cwd, "a.txt", "a0", "a1")
+	recordEdit(t, h, cwd, "b.txt", "b0", "b1")
+
+	batches, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("len(batches) = %d, want 2", len(batches))
+	}
+	if batches[0].Prompt != "edit b.txt" || batches[1].Prompt != "edit a.txt" {
+		t.Fatalf("order = %q, %q", batches[0].Prompt, batches[1].Prompt)
+	}
+	if got := batches[0].Changes[0].BeforeContent; got != "b0" {
+		t.Fatalf("BeforeContent = %q, want b0", got)
+	}
+}
+
+func TestHistoryUndoLatestThenOlder(t *testing.T) {
+	cwd := t.TempDir()
+	h := newTestHistory(t)
+	recordEdit(t, h, cwd, "a.txt", "a0", "a1")
+	recordEdit(t, h, cwd, "b.txt", "b0", "b1")
+
+	if _, err := h.Undo(0); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(cwd, "b.txt"), "b0")
+
+	// The latest batch is undone, so a bare undo moves on to the older one.
+	if _, err := h.Undo(0); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(cwd, "a.txt"), "a0")
+
+	if _, err := h.Undo(1); err == nil {
+		t.Fatal("undoing an undone batch succeeded")
+	}
+	if _, err := h.Undo(0); err == nil {
+		t.Fatal("undo with nothing left succeeded")
+	}
+}
+
+func TestHistoryUndoRefusesExternallyModifiedFile(t *testing.T) {
+	cwd := t.TempDir()
+	h := newTestHistory(t)
+	recordEdit(t, h, cwd, "a.txt", "a0", "a1")
+	path := filepath.Join(cwd, "a.txt")
+	if err := os.WriteFile(path, []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := h.Undo(1)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want *ConflictError", err)
+	}
+	if len(conflict.Paths) != 1 || conflict.Paths[0] != "a.txt" {
+		t.Fatalf("Paths = %v, want [a.txt]", conflict.Paths)
+	}
+	assertFile(t, path, "mine")
+}
+
+func TestNilHistoryRecordsNothing(t *testing.T) {
+	var h *History
+	if err := h.RecordApprovedBatch("p", []FileChange{{DisplayPath: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.List(); err == nil {
+		t.Fatal("List on nil history succeeded")
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Fatalf("%s = %q, want %q", filepath.Base(path), data, want)
+	}
+}
@@ -6,35 +6,52 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"github.com/webforspeed/bono/internal/diff"
 )
 
 type FileChange struct {
-	ToolName      string
-	InputPath     string
-	AbsolutePath  string
-	DisplayPath   string
-	WasNewFile    bool
-	BeforeContent string
-	AfterContent  string
+	ToolName      string `json:"tool_name"`
+	InputPath     string `json:"input_path"`
+	AbsolutePath  string `json:"absolute_path"`
+	DisplayPath   string `json:"display_path"`
+	WasNewFile    bool   `json:"was_new_file,omitempty"`
+	WasDeleted    bool   `json:"was_deleted,omitempty"`
+	BeforeContent string `json:"before_content"`
+	AfterContent  string `json:"after_content"`
 }
 
 type BatchTracker interface {
 	BeginChange(cwd, toolName, inputPath string) (FileChange, error)
 	CompleteChange(toolName, inputPath string) (FileChange, bool, error)
 	DiscardChange(toolName, inputPath string) bool
+	BeginSnapshot(cwd, toolName, key string) error
+	CompleteSnapshot(toolName, key string) ([]FileChange, error)
 	DrainCompleted() []FileChange
 	UndoBatch(changes []FileChange) error
+	ForceUndo(changes []FileChange) error
+	ApplyReview(changes []FileChange, decisions []FileDecision) ([]FileChange, error)
 	Reset()
 }
 
-// ChangeLog is reserved for future approved-batch history.
+// FileDecision is a reviewer's verdict on one file of a batch, matched by
+// DisplayPath. When Hunks is set it holds a keep flag per hunk of
+// diff.Hunks(BeforeContent, AfterContent) and overrides Keep.
+type FileDecision struct {
+	Path  string `json:"path"`
+	Keep  bool   `json:"keep"`
+	Hunks []bool `json:"hunks,omitempty"`
+}
+
+// ChangeLog records approved batches so they can be undone later.
 type ChangeLog interface {
-	RecordApprovedBatch(changes []FileChange) error
+	RecordApprovedBatch(prompt string, changes []FileChange) error
 }
 
 type Manager struct {
 	mu             sync.Mutex
 	pending        map[string][]FileChange
+	snapshots      map[string][]*Snapshot
 	completed      []FileChange
 	completedIndex map[string]int
 }
@@ -42,6 +59,7 @@ type Manager struct {
 func NewManager() *Manager {
 	return &Manager{
 		pending:        make(map[string][]FileChange),
+		snapshots:      make(map[string][]*Snapshot),
 		completedIndex: make(map[string]int),
 	}
 }
@@ -89,17 +107,38 @@ func (m *Manager) CompleteChange(toolName, inputPath string) (FileChange, bool,
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if idx, ok := m.completedIndex[change.AbsolutePath]; ok {
-		m.completed[idx].AfterContent = change.AfterContent
-		m.completed[idx].ToolName = change.ToolName
-		m.completed[idx].InputPath = change.InputPath
-		m.completed[idx].DisplayPath = change.DisplayPath
-		return m.completed[idx], true, nil
+	return m.recordLocked(change), true, nil
+}
+
+// recordLocked folds a finished change into the batch. A file touched more
+// than once keeps its first BeforeContent; a file whose final state matches
+// its original state drops out of the batch.
+func (m *Manager) recordLocked(change FileChange) FileChange {
+	idx, ok := m.completedIndex[change.AbsolutePath]
+	if !ok {
+		m.completedIndex[change.AbsolutePath] = len(m.completed)
+		m.completed = append(m.completed, change)
+		return change
 	}
 
-	m.completedIndex[change.AbsolutePath] = len(m.completed)
-	m.completed = append(m.completed, change)
-	return change, true, nil
+	merged := m.completed[idx]
+	merged.AfterContent = change.AfterContent
+	merged.WasDeleted = change.WasDeleted
+	merged.ToolName = change.ToolName
+	merged.InputPath = change.InputPath
+	merged.DisplayPath = change.DisplayPath
+	m.completed[idx] = merged
+
+	if merged.WasNewFile == merged.WasDeleted && merged.BeforeContent == merged.AfterContent {
+		m.completed = append(m.completed[:idx], m.completed[idx+1:]...)
+		delete(m.completedIndex, merged.AbsolutePath)
+		for path, i := range m.completedIndex {
+			if i > idx {
+				m.completedIndex[path] = i - 1
+			}
+		}
+	}
+	return merged
 }
 
 func (m *Manager) DiscardChange(toolName, inputPath string) bool {
@@ -118,23 +157,99 @@ func (m *Manager) DrainCompleted() []FileChange {
 	return result
 }
 
-func (m *Manager) UndoBatch(changes []FileChange) error {
+// ApplyReview reverts whatever the reviewer rejected: whole files, or single
+// hunks of a file. Files without a decision are kept. It returns the changes
+// that remain applied, with AfterContent updated to what the batch now
+// leaves in the file. Like UndoBatch, it merges around edits made after the
+// batch and leaves a file alone when they overlap the rejected hunks.
+func (m *Manager) ApplyReview(changes []FileChange, decisions []FileDecision) ([]FileChange, error) {
+	byPath := make(map[string]FileDecision, len(decisions))
+	for _, d := range decisions {
+		byPath[d.Path] = d
+	}
+
+	var kept []FileChange
 	var errs []string
 	for _, change := range changes {
-		if err := restoreOriginal(change); err != nil {
+		d, ok := byPath[change.DisplayPath]
+		if !ok {
+			kept = append(kept, change)
+			continue
+		}
+		content := change.AfterContent
+		switch {
+		case d.Hunks != nil:
+			content = diff.Select(change.BeforeContent, change.AfterContent, func(i int) bool {
+				return i >= len(d.Hunks) || d.Hunks[i]
+			})
+		case !d.Keep:
+			content = change.BeforeContent
+		}
+		if content == change.AfterContent {
+			kept = append(kept, change)
+			continue
+		}
+
+		plan, ok, err := planReview(change, content)
+		switch {
+		case err != nil:
 			errs = append(errs, fmt.Sprintf("%s: %v", change.DisplayPath, err))
+			kept = append(kept, change)
+			continue
+		case !ok:
+			errs = append(errs, fmt.Sprintf("%s: modified since the batch was applied; left as is", change.DisplayPath))
+			kept = append(kept, change)
+			continue
 		}
+		if err := writeOrRemove(change.AbsolutePath, plan.content, plan.remove); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", change.DisplayPath, err))
+			kept = append(kept, change)
+			continue
+		}
+		if content == change.BeforeContent {
+			continue
+		}
+		// The file now holds the kept hunks, so it exists even if the batch
+		// deleted it.
+		change.AfterContent = content
+		change.WasDeleted = false
+		kept = append(kept, change)
 	}
-	if len(errs) == 0 {
-		return nil
+	if len(errs) > 0 {
+		return kept, fmt.Errorf("revert rejected changes: %s", strings.Join(errs, "; "))
 	}
-	return fmt.Errorf("undo changes: %s", strings.Join(errs, "; "))
+	return kept, nil
+}
+
+// planReview works out how to turn change's file into content, the batch
+// with its rejected hunks taken out, given what is on disk now. A file still
+// as the batch left it is written outright; one edited since is three-way
+// merged. ok is false when the later edits overlap the rejected hunks or the
+// file was deleted or recreated behind the batch's back.
+func planReview(change FileChange, content string) (plan revert, ok bool, err error) {
+	current, missing, err := ReadFileOrEmpty(change.AbsolutePath)
+	if err != nil {
+		return revert{}, false, err
+	}
+	remove := content == change.BeforeContent && change.WasNewFile
+	switch {
+	case missing != change.WasDeleted:
+		return revert{}, false, nil
+	case current == change.AfterContent:
+		return revert{change: change, content: content, remove: remove}, true, nil
+	}
+	merged, ok := diff.Merge3(change.AfterContent, current, content)
+	if !ok {
+		return revert{}, false, nil
+	}
+	return revert{change: change, content: merged, remove: remove && merged == ""}, true, nil
 }
 
 func (m *Manager) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.pending = make(map[string][]FileChange)
+	m.snapshots = make(map[string][]*Snapshot)
 	m.completed = nil
 	m.completedIndex = make(map[string]int)
 }
@@ -165,19 +280,6 @@ func ReadFileOrEmpty(path string) (content string, wasNew bool, err error) {
 	return string(data), false, nil
 }
 
-func restoreOriginal(change FileChange) error {
-	if change.WasNewFile {
-		if err := os.Remove(change.AbsolutePath); err != nil && !os.IsNotExist(err) {
-			return err
-		}
-		return nil
-	}
-	if err := os.MkdirAll(filepath.Dir(change.AbsolutePath), 0o755); err != nil {
-		return err
-	}
-	return os.WriteFile(change.AbsolutePath, []byte(change.BeforeContent), 0o644)
-}
-
 func resolvePath(cwd, inputPath string) (absPath, displayPath string, err error) {
 	if strings.TrimSpace(inputPath) == "" {
 		return "", "", fmt.Errorf("empty path")
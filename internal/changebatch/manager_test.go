@@ -3,6 +3,7 @@ package changebatch
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -177,3 +178,130 @@ func TestDiscardChangeRemovesPendingEntry(t *testing.T) {
 		t.Fatalf("CompleteChange after DiscardChange = (_, %v, %v), want (_, false, nil)", ok, err)
 	}
 }
+
+func TestApplyReviewRevertsRejectedFilesAndHunks(t *testing.T) {
+	cwd := t.TempDir()
+	mgr := NewManager()
+	edit := func(name, before, after string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(cwd, name), []byte(before), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mgr.BeginChange(cwd, "edit_file", name); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cwd, name), []byte(after), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := mgr.CompleteChange("edit_file", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit("keep.txt", "k\n", "K\n")
+	edit("drop.txt", "d\n", "D\n")
+	edit("mixed.txt", "a\nb\nc\n", "A\nb\nC\n")
+
+	kept, err := mgr.ApplyReview(mgr.DrainCompleted(), []FileDecision{
+		{Path: "drop.txt", Keep: false},
+		{Path: "mixed.txt", Hunks: []bool{false, true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"keep.txt": "K\n", "drop.txt": "d\n", "mixed.txt": "a\nb\nC\n"} {
+		data, err := os.ReadFile(filepath.Join(cwd, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if len(kept) != 2 {
+		t.Fatalf("len(kept) = %d, want 2", len(kept))
+	}
+	if kept[1].DisplayPath != "mixed.txt" || kept[1].AfterContent != "a\nb\nC\n" {
+		t.Fatalf("kept[1] = %+v", kept[1])
+	}
+}
+
+func TestApplyReviewMergesAroundLaterEdits(t *testing.T) {
+	cwd := t.TempDir()
+	mgr := NewManager()
+	path := filepath.Join(cwd, "f.txt")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a\nb\nc\nd\ne\nf\ng\n")
+	if _, err := mgr.BeginChange(cwd, "edit_file", "f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	write("A\nb\nc\nd\ne\nf\nG\n")
+	if _, _, err := mgr.CompleteChange("edit_file", "f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	changes := mgr.DrainCompleted()
+
+	// An edit away from the rejected hunk is kept.
+	write("A\nb\nc\nD\ne\nf\nG\n")
+	kept, err := mgr.ApplyReview(changes, []FileDecision{{Path: "f.txt", Hunks: []bool{false, true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a\nb\nc\nD\ne\nf\nG\n" {
+		t.Errorf("merged file = %q", data)
+	}
+	if len(kept) != 1 || kept[0].AfterContent != "a\nb\nc\nd\ne\nf\nG\n" {
+		t.Errorf("kept = %+v, want the batch's own content", kept)
+	}
+
+	// An edit overlapping the rejected hunk is not clobbered.
+	write("X\nb\nc\nd\ne\nf\nG\n")
+	kept, err = mgr.ApplyReview(changes, []FileDecision{{Path: "f.txt", Keep: false}})
+	if err == nil || !strings.Contains(err.Error(), "f.txt: modified since the batch was applied") {
+		t.Errorf("err = %v, want a conflict for f.txt", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "X\nb\nc\nd\ne\nf\nG\n" {
+		t.Errorf("conflicting file = %q, want it left as is", data)
+	}
+	if len(kept) != 1 {
+		t.Errorf("len(kept) = %d, want the unreverted change kept", len(kept))
+	}
+}
+
+func TestApplyReviewRestoresRejectedDeletion(t *testing.T) {
+	cwd := t.TempDir()
+	mgr := NewManager()
+	path := filepath.Join(cwd, "gone.txt")
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.BeginSnapshot(cwd, "run_shell", "rm gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.CompleteSnapshot("run_shell", "rm gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	changes := mgr.DrainCompleted()
+	if len(changes) != 1 || !changes[0].WasDeleted {
+		t.Fatalf("changes = %+v, want one deletion", changes)
+	}
+
+	kept, err := mgr.ApplyReview(changes, []FileDecision{{Path: "gone.txt", Hunks: []bool{false}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "a\nb\n" {
+		t.Fatalf("restored file = %q, %v", data, err)
+	}
+	if len(kept) != 0 {
+		t.Fatalf("kept = %+v, want the rejected deletion dropped", kept)
+	}
+}
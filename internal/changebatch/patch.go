@@ -0,0 +1,34 @@
+package changebatch
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/webforspeed/bono/internal/diff"
+)
+
+// WritePatch writes changes as a git-style patch with paths relative to the
+// workspace, ready for git apply.
+func WritePatch(w io.Writer, changes []FileChange) error {
+	files := make([]diff.File, 0, len(changes))
+	for _, c := range changes {
+		files = append(files, diff.File{
+			Path:    c.DisplayPath,
+			Old:     c.BeforeContent,
+			New:     c.AfterContent,
+			Created: c.WasNewFile && !c.WasDeleted,
+			Deleted: c.WasDeleted && !c.WasNewFile,
+		})
+	}
+	return diff.WritePatch(w, files, diff.DefaultContext)
+}
+
+// SavePatch writes changes as a patch file at path.
+func SavePatch(path string, changes []FileChange) error {
+	var buf bytes.Buffer
+	if err := WritePatch(&buf, changes); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
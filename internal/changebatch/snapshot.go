@@ -0,0 +1,363 @@
+package changebatch
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot limits. Files larger than maxSnapshotFileSize or containing NUL
+// bytes are treated as binary and not tracked. Workspaces with more than
+// maxSnapshotFiles files are not snapshotted at all.
+const (
+	maxSnapshotFileSize = 1 << 20
+	maxSnapshotFiles    = 20000
+)
+
+// ErrWorkspaceTooLarge is returned by TakeSnapshot when the workspace has too
+// many files to snapshot around every shell call.
+var ErrWorkspaceTooLarge = errors.New("workspace too large to track shell changes")
+
+type snapshotEntry struct {
+	size    int64
+	modTime time.Time
+	blob    string // git blob id of the content
+	content string // kept only when git cannot return it by blob
+}
+
+// Snapshot records the text files of a workspace so changes made by opaque
+// tools (shell commands, python scripts) can be turned into FileChanges.
+// Files that match the git index are recorded by size, mtime and blob id
+// only; their content is read back from git if they turn out to change.
+type Snapshot struct {
+	root  string
+	files map[string]snapshotEntry // keyed by slash-separated path relative to root
+}
+
+// TakeSnapshot hashes every non-ignored text file under root. Inside a git
+// work tree the file list comes from git and honours every ignore source;
+// elsewhere the root .gitignore is applied and .git is skipped.
+func TakeSnapshot(root string) (*Snapshot, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := ListWorkspace(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > maxSnapshotFiles {
+		return nil, ErrWorkspaceTooLarge
+	}
+	index := gitIndexBlobs(root)
+	snap := &Snapshot{root: root, files: make(map[string]snapshotEntry, len(paths))}
+	for _, rel := range paths {
+		entry, ok := readSnapshotEntry(filepath.Join(root, filepath.FromSlash(rel)))
+		if !ok {
+			continue
+		}
+		if index[rel] == entry.blob {
+			entry.content = ""
+		}
+		snap.files[rel] = entry
+	}
+	return snap, nil
+}
+
+// Changes compares the snapshot with the workspace as it is now and returns
+// one FileChange per created, modified or deleted file, sorted by path.
+func (s *Snapshot) Changes(toolName string) ([]FileChange, error) {
+	paths, err := ListWorkspace(s.root)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(paths))
+	var changes []FileChange
+	for _, rel := range paths {
+		abs := filepath.Join(s.root, filepath.FromSlash(rel))
+		if _, err := os.Lstat(abs); err != nil {
+			// git still lists tracked files deleted from the work tree.
+			continue
+		}
+		seen[rel] = true
+		before, existed := s.files[rel]
+		if existed && unchanged(abs, before) {
+			continue
+		}
+		after, ok := readSnapshotEntry(abs)
+		if !ok || (existed && after.blob == before.blob) {
+			continue
+		}
+		beforeContent, err := s.content(before)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		changes = append(changes, FileChange{
+			ToolName:      toolName,
+			InputPath:     rel,
+			AbsolutePath:  abs,
+			DisplayPath:   rel,
+			WasNewFile:    !existed,
+			BeforeContent: beforeContent,
+			AfterContent:  after.content,
+		})
+	}
+	for rel, before := range s.files {
+		if seen[rel] {
+			continue
+		}
+		abs := filepath.Join(s.root, filepath.FromSlash(rel))
+		if _, err := os.Lstat(abs); err == nil {
+			// Still on disk but newly ignored; leave it alone.
+			continue
+		}
+		beforeContent, err := s.content(before)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		changes = append(changes, FileChange{
+			ToolName:      toolName,
+			InputPath:     rel,
+			AbsolutePath:  abs,
+			DisplayPath:   rel,
+			WasDeleted:    true,
+			BeforeContent: beforeContent,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].DisplayPath < changes[j].DisplayPath })
+	return changes, nil
+}
+
+// content returns the snapshotted content of entry, reading it from the git
+// object store when it was not kept.
+func (s *Snapshot) content(entry snapshotEntry) (string, error) {
+	if entry.content != "" || entry.size == 0 {
+		return entry.content, nil
+	}
+	cmd := exec.Command("git", "cat-file", "blob", entry.blob)
+	cmd.Dir = s.root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("read snapshot content: %w", err)
+	}
+	return string(out), nil
+}
+
+func unchanged(abs string, before snapshotEntry) bool {
+	info, err := os.Stat(abs)
+	return err == nil && info.Size() == before.size && info.ModTime().Equal(before.modTime)
+}
+
+// readSnapshotEntry reads and hashes a regular text file. It reports false
+// for directories, symlinks, binaries and oversized files.
+func readSnapshotEntry(abs string) (snapshotEntry, bool) {
+	info, err := os.Lstat(abs)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxSnapshotFileSize {
+		return snapshotEntry{}, false
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return snapshotEntry{}, false
+	}
+	return snapshotEntry{size: info.Size(), modTime: info.ModTime(), blob: blobID(data), content: string(data)}, true
+}
+
+// blobID returns the id git gives a blob with this content.
+func blobID(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// gitIndexBlobs maps the stage-0 paths in root's git index to their blob
+// ids. It returns nil outside a git work tree.
+func gitIndexBlobs(root string) map[string]string {
+	cmd := exec.Command("git", "ls-files", "-z", "--stage")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	blobs := make(map[string]string)
+	for _, rec := range strings.Split(string(out), "\x00") {
+		// <mode> SP <object> SP <stage> TAB <path>
+		meta, rel, ok := strings.Cut(rec, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[2] != "0" {
+			continue
+		}
+		blobs[rel] = fields[1]
+	}
+	return blobs
+}
+
+// ListWorkspace returns the slash-separated relative paths of the files under
+// root that are not ignored.
+func ListWorkspace(root string) ([]string, error) {
+	if paths, err := gitListFiles(root); err == nil {
+		return paths, nil
+	}
+	return walkWorkspace(root)
+}
+
+func gitListFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+func walkWorkspace(root string) ([]string, error) {
+	ignore := readIgnoreFile(filepath.Join(root, ".gitignore"))
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if d.Name() == ".git" || ignore.match(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !ignore.match(rel, false) {
+			paths = append(paths, rel)
+		}
+		if len(paths) > maxSnapshotFiles {
+			return ErrWorkspaceTooLarge
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// ignoreRules is the subset of .gitignore syntax used outside git work trees:
+// comments, trailing-slash directory patterns, leading-slash anchoring and
+// shell globs. Negation is not supported.
+type ignoreRules []ignoreRule
+
+type ignoreRule struct {
+	pattern  string
+	dirOnly  bool
+	anchored bool
+}
+
+func readIgnoreFile(path string) ignoreRules {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules ignoreRules
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r ignoreRules) match(rel string, isDir bool) bool {
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := path.Base(rel)
+		if rule.anchored {
+			target = rel
+		}
+		if ok, _ := path.Match(rule.pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// BeginSnapshot snapshots the workspace before an opaque tool call. key
+// identifies the call so CompleteSnapshot can find its snapshot again.
+func (m *Manager) BeginSnapshot(cwd, toolName, key string) error {
+	snap, err := TakeSnapshot(cwd)
+	if err != nil {
+		return fmt.Errorf("snapshot workspace: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := pendingKey(toolName, key)
+	m.snapshots[k] = append(m.snapshots[k], snap)
+	return nil
+}
+
+// CompleteSnapshot diffs the workspace against the snapshot taken by
+// BeginSnapshot and folds the detected changes into the current batch.
+func (m *Manager) CompleteSnapshot(toolName, key string) ([]FileChange, error) {
+	m.mu.Lock()
+	k := pendingKey(toolName, key)
+	queue := m.snapshots[k]
+	if len(queue) == 0 {
+		m.mu.Unlock()
+		return nil, nil
+	}
+	snap := queue[0]
+	if len(queue) == 1 {
+		delete(m.snapshots, k)
+	} else {
+		m.snapshots[k] = queue[1:]
+	}
+	m.mu.Unlock()
+
+	changes, err := snap.Changes(toolName)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, change := range changes {
+		m.recordLocked(change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,170 @@
+package changebatch
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotDetectsCreateModifyDelete(t *testing.T) {
+	cwd := t.TempDir()
+	writeFile(t, filepath.Join(cwd, ".gitignore"), "build/\n*.log\n")
+	writeFile(t, filepath.Join(cwd, "keep.txt"), "same\n")
+	writeFile(t, filepath.Join(cwd, "edit.txt"), "before\n")
+	writeFile(t, filepath.Join(cwd, "gone.txt"), "bye\n")
+
+	mgr := NewManager()
+	if err := mgr.BeginSnapshot(cwd, "run_shell", "make"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cwd, "edit.txt"), "after\n")
+	writeFile(t, filepath.Join(cwd, "src", "new.txt"), "hello\n")
+	writeFile(t, filepath.Join(cwd, "build", "out.txt"), "ignored\n")
+	writeFile(t, filepath.Join(cwd, "run.log"), "ignored\n")
+	if err := os.Remove(filepath.Join(cwd, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := mgr.CompleteSnapshot("run_shell", "make")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v, want 3", changes)
+	}
+	edit, gone, created := changes[0], changes[1], changes[2]
+	if edit.DisplayPath != "edit.txt" || edit.BeforeContent != "before\n" || edit.AfterContent != "after\n" {
+		t.Fatalf("edit change = %+v", edit)
+	}
+	if gone.DisplayPath != "gone.txt" || !gone.WasDeleted || gone.BeforeContent != "bye\n" {
+		t.Fatalf("delete change = %+v", gone)
+	}
+	if created.DisplayPath != "src/new.txt" || !created.WasNewFile || created.AfterContent != "hello\n" {
+		t.Fatalf("create change = %+v", created)
+	}
+
+	if err := mgr.UndoBatch(mgr.DrainCompleted()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cwd, "gone.txt")); string(data) != "bye\n" {
+		t.Fatalf("gone.txt = %q after undo", data)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "src", "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("src/new.txt still exists after undo")
+	}
+}
+
+func TestSnapshotUsesGitIgnoreRules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	cwd := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", cwd).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	writeFile(t, filepath.Join(cwd, ".git", "info", "exclude"), "secret.txt\n")
+
+	mgr := NewManager()
+	if err := mgr.BeginSnapshot(cwd, "python_runtime", "gen()"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(cwd, "secret.txt"), "ignored\n")
+	writeFile(t, filepath.Join(cwd, "gen.go"), "package gen\n")
+
+	changes, err := mgr.CompleteSnapshot("python_runtime", "gen()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].DisplayPath != "gen.go" {
+		t.Fatalf("changes = %+v, want only gen.go", changes)
+	}
+}
+
+func TestSnapshotMergesWithTrackedEdits(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	writeFile(t, path, "v1\n")
+
+	mgr := NewManager()
+	if _, err := mgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "v2\n")
+	if _, _, err := mgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.BeginSnapshot(cwd, "run_shell", "sed -i s/v2/v1/ notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "v1\n")
+	if _, err := mgr.CompleteSnapshot("run_shell", "sed -i s/v2/v1/ notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mgr.DrainCompleted(); len(got) != 0 {
+		t.Fatalf("completed = %+v, want the round trip to cancel out", got)
+	}
+}
+
+func TestSnapshotReadsCleanFilesBackFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	cwd := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = cwd
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	writeFile(t, filepath.Join(cwd, "clean.txt"), "committed\n")
+	writeFile(t, filepath.Join(cwd, "gone.txt"), "committed too\n")
+	writeFile(t, filepath.Join(cwd, "dirty.txt"), "committed\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	writeFile(t, filepath.Join(cwd, "dirty.txt"), "edited\n")
+
+	snap, err := TakeSnapshot(cwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rel, want := range map[string]string{"clean.txt": "", "gone.txt": "", "dirty.txt": "edited\n"} {
+		if got := snap.files[rel].content; got != want {
+			t.Errorf("%s kept %q, want %q", rel, got, want)
+		}
+	}
+
+	writeFile(t, filepath.Join(cwd, "clean.txt"), "changed\n")
+	writeFile(t, filepath.Join(cwd, "dirty.txt"), "edited again\n")
+	if err := os.Remove(filepath.Join(cwd, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := snap.Changes("run_shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"clean.txt": "committed\n", "dirty.txt": "edited\n", "gone.txt": "committed too\n"}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v", changes)
+	}
+	for _, c := range changes {
+		if c.BeforeContent != want[c.DisplayPath] {
+			t.Errorf("%s before = %q, want %q", c.DisplayPath, c.BeforeContent, want[c.DisplayPath])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package changebatch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/webforspeed/bono/internal/diff"
+)
+
+// ConflictError reports files whose edits since a batch was applied overlap
+// the batch's own changes, so reverting them would destroy those edits.
+type ConflictError struct {
+	Paths   []string
+	Changes []FileChange // the conflicting changes, for ForceUndo
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("modified since the batch was applied: %s", strings.Join(e.Paths, ", "))
+}
+
+// revert is the planned undo of one change: write content, or remove the file.
+type revert struct {
+	change  FileChange
+	content string
+	remove  bool
+}
+
+// planUndo works out how to revert each change given what is on disk now.
+// A file still in the state the change left it is restored outright. A file
+// edited since is three-way merged so only the change's own hunks are
+// reverted; when the edits overlap, the change is returned as a conflict.
+// Files already back in their original state need nothing.
+func planUndo(changes []FileChange) ([]revert, []FileChange, error) {
+	var plans []revert
+	var conflicts []FileChange
+	for _, change := range changes {
+		current, missing, err := ReadFileOrEmpty(change.AbsolutePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", change.DisplayPath, err)
+		}
+		switch {
+		case missing == change.WasDeleted && current == change.AfterContent:
+			plans = append(plans, revert{change: change, content: change.BeforeContent, remove: change.WasNewFile})
+		case missing == change.WasNewFile && current == change.BeforeContent:
+		case missing != change.WasDeleted:
+			// Deleted or recreated behind the batch's back: nothing to merge with.
+			conflicts = append(conflicts, change)
+		default:
+			merged, ok := diff.Merge3(change.AfterContent, current, change.BeforeContent)
+			if !ok {
+				conflicts = append(conflicts, change)
+				continue
+			}
+			plans = append(plans, revert{change: change, content: merged, remove: change.WasNewFile && merged == ""})
+		}
+	}
+	return plans, conflicts, nil
+}
+
+func applyReverts(plans []revert) error {
+	var errs []string
+	for _, p := range plans {
+		if err := writeOrRemove(p.change.AbsolutePath, p.content, p.remove); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.change.DisplayPath, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("undo changes: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func conflictError(conflicts []FileChange) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	paths := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		paths[i] = c.DisplayPath
+	}
+	return &ConflictError{Paths: paths, Changes: conflicts}
+}
+
+// UndoBatch reverts changes without discarding edits made to the same files
+// after them (see planUndo). Files it cannot revert safely are left as they
+// are and reported in a *ConflictError; ForceUndo overwrites them.
+func (m *Manager) UndoBatch(changes []FileChange) error {
+	plans, conflicts, err := planUndo(changes)
+	if err != nil {
+		return err
+	}
+	if err := applyReverts(plans); err != nil {
+		return err
+	}
+	return conflictError(conflicts)
+}
+
+// ForceUndo restores each file's original content, discarding any later edits.
+func (m *Manager) ForceUndo(changes []FileChange) error {
+	var errs []string
+	for _, change := range changes {
+		if err := restoreOriginal(change); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", change.DisplayPath, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("undo changes: %s", strings.Join(errs, "; "))
+}
+
+func restoreOriginal(change FileChange) error {
+	return writeOrRemove(change.AbsolutePath, change.BeforeContent, change.WasNewFile)
+}
+
+func writeOrRemove(path, content string, remove bool) error {
+	if remove {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
@@ -0,0 +1,70 @@
+package changebatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUndoBatchKeepsLaterEditsByMerging(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	change := FileChange{
+		AbsolutePath:  path,
+		DisplayPath:   "notes.txt",
+		BeforeContent: "a\nb\nc\nd\ne\n",
+		AfterContent:  "a\nB\nc\nd\ne\n",
+	}
+	// The user appended a line after the agent's edit.
+	writeFile(t, path, "a\nB\nc\nd\ne\nf\n")
+
+	if err := NewManager().UndoBatch([]FileChange{change}); err != nil {
+		t.Fatalf("UndoBatch returned error: %v", err)
+	}
+	assertFile(t, path, "a\nb\nc\nd\ne\nf\n")
+}
+
+func TestUndoBatchReportsOverlappingEdits(t *testing.T) {
+	cwd := t.TempDir()
+	clean := filepath.Join(cwd, "clean.txt")
+	edited := filepath.Join(cwd, "edited.txt")
+	changes := []FileChange{
+		{AbsolutePath: clean, DisplayPath: "clean.txt", BeforeContent: "old\n", AfterContent: "new\n"},
+		{AbsolutePath: edited, DisplayPath: "edited.txt", BeforeContent: "old\n", AfterContent: "new\n"},
+	}
+	writeFile(t, clean, "new\n")
+	writeFile(t, edited, "mine\n")
+
+	mgr := NewManager()
+	err := mgr.UndoBatch(changes)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want *ConflictError", err)
+	}
+	if len(conflict.Changes) != 1 || conflict.Changes[0].DisplayPath != "edited.txt" {
+		t.Fatalf("conflicts = %+v, want edited.txt", conflict.Changes)
+	}
+	assertFile(t, clean, "old\n")
+	assertFile(t, edited, "mine\n")
+
+	if err := mgr.ForceUndo(conflict.Changes); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, edited, "old\n")
+}
+
+func TestUndoBatchTreatsRemovedNewFileAsConflict(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "gone.txt")
+	change := FileChange{AbsolutePath: path, DisplayPath: "gone.txt", BeforeContent: "keep\n", AfterContent: "changed\n"}
+
+	err := NewManager().UndoBatch([]FileChange{change})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("err = %v, want *ConflictError", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("UndoBatch recreated a file the user deleted")
+	}
+}
@@ -0,0 +1,589 @@
+// Package config loads bono's settings from built-in defaults, the user and
+// project config.toml files, environment variables (backed by the project
+// .env file) and command-line flags, in that order, and remembers where each
+// value came from.
+package config
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/dotenv"
+)
+
+// ProjectFile is the project-level config path, relative to the workspace root.
+const ProjectFile = ".bono/config.toml"
+
+// SourceDefault is the source of a value nothing overrode.
+const SourceDefault = "default"
+
+// Config is bono's effective configuration. Each field's toml tag is its key
+// in config.toml (nested structs are tables), and its env tag, if any, is the
+// environment variable that overrides it. Durations are written as seconds
+// or as Go durations such as "2m". A string field with an enum tag only
+// accepts the listed values.
+type Config struct {
+	Profile       string `toml:"profile" env:"BONO_PROFILE"`
+	Model         string `toml:"model" env:"MODEL"` // empty picks a model from the catalog
+	BaseURL       string `toml:"base_url" env:"BASE_URL"`
+	APIKey        string `toml:"api_key" env:"OPENROUTER_API_KEY" secret:"true"`
+	Reasoning     string `toml:"reasoning" enum:",xhigh,high,medium,low,minimal,none"` // empty keeps the model's default
+	PromptVersion string `toml:"prompt_version"`                                       // empty uses the built-in version
+
+	Limits   Limits   `toml:"limits"`
+	Models   Models   `toml:"models"`
+	Local    Local    `toml:"local"`
+	Fallback Fallback `toml:"fallback"`
+	Roles    Roles    `toml:"roles"`
+	Index    Index    `toml:"index"`
+	Web      Web      `toml:"web"`
+	Log      Log      `toml:"log"`
+
+	sources  map[string]string
+	profiles map[string][]profileValue
+}
+
+// profileValue is one setting from a [profiles.<name>] table.
+type profileValue struct {
+	field  field
+	raw    any
+	source string
+}
+
+// Limits bound how long and how far a single run may go. Zero turn and tool
+// call limits mean unlimited.
+type Limits struct {
+	HTTPTimeout         time.Duration `toml:"http_timeout" env:"API_TIMEOUT_SEC"`
+	ShellTimeout        time.Duration `toml:"shell_timeout" env:"SHELL_TIMEOUT_SEC"`
+	MaxToolCallsPerTurn int           `toml:"max_tool_calls_per_turn" env:"MAX_TOOL_CALLS_PER_TURN"`
+	MaxChatTurns        int           `toml:"max_chat_turns"`
+	MaxPreTaskTurns     int           `toml:"max_pre_task_turns"`
+	MaxSubAgentTurns    int           `toml:"max_subagent_turns"`
+}
+
+// Models configures the model catalog.
+type Models struct {
+	// Refresh fills in context length, pricing and supported parameters
+	// from OpenRouter, cached for CacheTTL.
+	Refresh  bool          `toml:"refresh" env:"BONO_MODELS_REFRESH"`
+	CacheTTL time.Duration `toml:"cache_ttl"`
+}
+
+// Local configures discovery of locally hosted models for the catalog.
+type Local struct {
+	// Ollama is the Ollama host in OLLAMA_HOST form; empty disables it.
+	Ollama string `toml:"ollama" env:"OLLAMA_HOST"`
+	// OpenAI lists OpenAI-compatible servers such as llama.cpp, LM Studio
+	// or vLLM as comma-separated base URLs, each optionally name=url.
+	OpenAI string `toml:"openai" env:"BONO_LOCAL_OPENAI"`
+}
+
+// Fallback configures what happens when a request fails with a rate limit,
+// a server error or an unavailable model: the model is retried with
+// exponential backoff, then each fallback model is tried for that request.
+type Fallback struct {
+	// Models are comma-separated model IDs to try in order.
+	Models  string        `toml:"models" env:"BONO_FALLBACK_MODELS"`
+	Retries int           `toml:"retries"`
+	Backoff time.Duration `toml:"backoff"` // delay before the first retry
+}
+
+// Roles route phases of work to their own model and reasoning effort, so
+// that a cheap model can explore while the main model implements. The main
+// loop uses the top-level model and reasoning.
+type Roles struct {
+	Plan       Role `toml:"plan"`       // the plan subagent
+	Explore    Role `toml:"explore"`    // the exploring pre-task
+	Compaction Role `toml:"compaction"` // compact_context
+}
+
+// Role is the model and reasoning effort for one role. Empty fields keep
+// the main loop's.
+type Role struct {
+	Model     string `toml:"model"`
+	Reasoning string `toml:"reasoning" enum:",xhigh,high,medium,low,minimal,none"`
+}
+
+// Index configures the code search index.
+type Index struct {
+	DBPath         string `toml:"db_path"`
+	EmbeddingModel string `toml:"embedding_model" env:"EMBEDDING_MODEL"`
+	EmbeddingDims  int    `toml:"embedding_dims" env:"EMBEDDING_DIMS"` // 0 uses the model's default
+}
+
+// Web configures the web search and answer tools.
+type Web struct {
+	AnswerModel     string `toml:"answer_model" env:"WEB_ANSWER_MODEL"`
+	SearchEngine    string `toml:"search_engine" env:"WEB_SEARCH_ENGINE"`
+	ClassifierModel string `toml:"classifier_model" env:"WEB_CLASSIFIER_MODEL"` // routes WebSearch queries; empty uses bono-core's default
+}
+
+// Log configures the structured event log.
+type Log struct {
+	Path string `toml:"path"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Limits: Limits{
+			HTTPTimeout:         120 * time.Second,
+			ShellTimeout:        30 * time.Second,
+			MaxToolCallsPerTurn: 100,
+			MaxChatTurns:        100,
+			MaxPreTaskTurns:     100,
+			MaxSubAgentTurns:    100,
+		},
+		Models:   Models{CacheTTL: 24 * time.Hour},
+		Local:    Local{Ollama: "127.0.0.1:11434"},
+		Fallback: Fallback{Retries: 2, Backoff: 2 * time.Second},
+		Index:    Index{DBPath: ".bono/index.db"},
+		Web:      Web{AnswerModel: "perplexity/sonar", SearchEngine: "exa"},
+		Log:      Log{Path: "logs/bono.jsonl"},
+	}
+}
+
+// UserFile returns the user-level config path ($XDG_CONFIG_HOME/bono/config.toml,
+// defaulting to ~/.config/bono/config.toml).
+func UserFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bono", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bono", "config.toml"), nil
+}
+
+// Flag is a value given on the command line. Name is the flag as typed, for
+// error messages and Source.
+type Flag struct {
+	Name  string
+	Key   string
+	Value string
+}
+
+// Options say what Load reads besides the defaults, the user config file and
+// the process environment.
+type Options struct {
+	// Project reads the project config file. Leave it off in folders the
+	// user has not trusted.
+	Project bool
+	// Env holds the project .env variables, which back up the process
+	// environment.
+	Env   dotenv.Env
+	Flags []Flag
+}
+
+// Load layers the defaults, the user config file, the project config file
+// (relative to cwd), the environment, the selected profile and flags. Missing
+// files are skipped. Errors name the file, environment variable or flag, and
+// the key.
+//
+// Profiles are [profiles.<name>] tables holding any other keys. The one named
+// by the profile key, which a flag may set, overrides the environment, so
+// choosing a profile beats ambient settings while flags still beat both.
+func Load(cwd string, opts Options) (*Config, error) {
+	c := Default()
+	c.sources = make(map[string]string)
+	c.profiles = make(map[string][]profileValue)
+
+	var files []string
+	if userFile, err := UserFile(); err == nil {
+		files = append(files, userFile)
+	}
+	if opts.Project {
+		files = append(files, filepath.Join(cwd, ProjectFile))
+	}
+	for _, path := range files {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		source := "env " + f.env
+		v := os.Getenv(f.env)
+		if v == "" {
+			v = opts.Env[f.env]
+			source += " (" + dotenv.FileName + ")"
+		}
+		if v != "" {
+			if err := c.set(f, v, source); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	flags := opts.Flags
+	for _, fl := range flags {
+		if _, ok := lookup(fl.Key); !ok {
+			return nil, fmt.Errorf("flag %s: unknown key %q", fl.Name, fl.Key)
+		}
+	}
+	if err := c.applyFlags(flags, func(key string) bool { return key == "profile" }); err != nil {
+		return nil, err
+	}
+	if c.Profile != "" {
+		values, ok := c.profiles[c.Profile]
+		if !ok {
+			return nil, fmt.Errorf("%s: profile: unknown profile %q", c.Source("profile"), c.Profile)
+		}
+		for _, v := range values {
+			if err := c.set(v.field, v.raw, "profile "+c.Profile+" ("+v.source+")"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := c.applyFlags(flags, func(key string) bool { return key != "profile" }); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) applyFlags(flags []Flag, want func(key string) bool) error {
+	for _, fl := range flags {
+		if f, _ := lookup(fl.Key); want(f.key) {
+			if err := c.set(f, fl.Value, "flag "+fl.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var table map[string]any
+	if _, err := toml.Decode(string(data), &table); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	profiles, _ := table["profiles"].(map[string]any)
+	if _, ok := table["profiles"]; ok && profiles == nil {
+		return fmt.Errorf("%s: profiles must be a table of [profiles.<name>] tables", path)
+	}
+	delete(table, "profiles")
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		prefix := "profiles." + name + "."
+		profile, ok := profiles[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %s must be a table", path, strings.TrimSuffix(prefix, "."))
+		}
+		err := walkTable(path, prefix, "", profile, func(f field, raw any) error {
+			if f.key == "profile" {
+				return fmt.Errorf("%s: %sprofile: a profile cannot select another profile", path, prefix)
+			}
+			// Check the value now so the error names the file.
+			scratch := reflect.New(reflect.TypeOf(Config{}).FieldByIndex(f.index).Type).Elem()
+			if err := assign(f, scratch, raw); err != nil {
+				return fmt.Errorf("%s: %s%s: %w", path, prefix, f.key, err)
+			}
+			c.profiles[name] = append(c.profiles[name], profileValue{field: f, raw: raw, source: path})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return walkTable(path, "", "", table, func(f field, raw any) error {
+		return c.set(f, raw, path)
+	})
+}
+
+// walkTable calls fn for every key in a decoded TOML table. shown is prepended
+// to keys in errors; prefix is the key prefix inside Config.
+func walkTable(path, shown, prefix string, table map[string]any, fn func(f field, raw any) error) error {
+	for _, name := range slices.Sorted(maps.Keys(table)) {
+		key, raw := prefix+name, table[name]
+		if sub, ok := raw.(map[string]any); ok {
+			if !isTable(key) {
+				return fmt.Errorf("%s: unknown table %q", path, shown+key)
+			}
+			if err := walkTable(path, shown, key+".", sub, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		f, ok := lookup(key)
+		if !ok {
+			return fmt.Errorf("%s: unknown key %q", path, shown+key)
+		}
+		if err := fn(f, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Profiles returns the names of the profiles defined in the config files.
+func (c *Config) Profiles() []string {
+	return slices.Sorted(maps.Keys(c.profiles))
+}
+
+// Diff returns the keys whose effective values differ between c and other.
+func (c *Config) Diff(other *Config) []string {
+	a, b := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	var keys []string
+	for _, f := range fields {
+		if !a.FieldByIndex(f.index).Equal(b.FieldByIndex(f.index)) {
+			keys = append(keys, f.key)
+		}
+	}
+	return keys
+}
+
+// Source reports where key's value came from: SourceDefault, a file path,
+// "env NAME", "env NAME (.env)", "profile NAME (file)" or "flag --name".
+func (c *Config) Source(key string) string {
+	if src, ok := c.sources[key]; ok {
+		return src
+	}
+	return SourceDefault
+}
+
+// Keys lists every config key in declaration order.
+func Keys() []string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// Show writes every key with its effective value and source. Secrets are
+// masked.
+func (c *Config) Show(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range fields {
+		value := format(v.FieldByIndex(f.index))
+		if f.secret && value != `""` {
+			value = `"********"`
+		}
+		fmt.Fprintf(tw, "%s = %s\t# %s\n", f.key, value, c.Source(f.key))
+	}
+	return tw.Flush()
+}
+
+// Core builds the bono-core agent configuration. model is the resolved model,
+// which may differ from c.Model when that is empty.
+func (c *Config) Core(model, systemPrompt string) core.Config {
+	return core.Config{
+		APIKey:       c.APIKey,
+		BaseURL:      c.BaseURL,
+		Model:        model,
+		SystemPrompt: systemPrompt,
+		HTTPTimeout:  c.Limits.HTTPTimeout,
+		Sandbox: core.SandboxConfig{
+			CommandTimeout: c.Limits.ShellTimeout,
+		},
+		CodeSearch: &core.CodeSearchConfig{
+			DBPath: c.Index.DBPath,
+			Model:  c.Index.EmbeddingModel,
+			Dims:   c.Index.EmbeddingDims,
+		},
+		Web: &core.WebConfig{
+			Model:           c.Web.AnswerModel,
+			SearchEngine:    c.Web.SearchEngine,
+			ClassifierModel: c.Web.ClassifierModel,
+		},
+		ShellPolicy:         core.DefaultShellPolicy(),
+		MaxToolCallsPerTurn: c.Limits.MaxToolCallsPerTurn,
+		MaxChatTurns:        c.Limits.MaxChatTurns,
+		MaxPreTaskTurns:     c.Limits.MaxPreTaskTurns,
+		MaxSubAgentTurns:    c.Limits.MaxSubAgentTurns,
+	}
+}
+
+// field is one settable key and where it lives in Config.
+type field struct {
+	key    string
+	env    string
+	secret bool
+	enum   []string
+	index  []int
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var fields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+func collectFields(t reflect.Type, prefix string, index []int) []field {
+	var out []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name := sf.Tag.Get("toml")
+		if name == "" {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			out = append(out, collectFields(sf.Type, prefix+name+".", idx)...)
+			continue
+		}
+		f := field{
+			key:    prefix + name,
+			env:    sf.Tag.Get("env"),
+			secret: sf.Tag.Get("secret") == "true",
+			index:  idx,
+		}
+		if enum, ok := sf.Tag.Lookup("enum"); ok {
+			f.enum = strings.Split(enum, ",")
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func lookup(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func isTable(key string) bool {
+	for _, f := range fields {
+		if strings.HasPrefix(f.key, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// set stores raw, a decoded TOML value or a string from the environment or a
+// flag, in f and records source.
+func (c *Config) set(f field, raw any, source string) error {
+	v := reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+	if err := assign(f, v, raw); err != nil {
+		return fmt.Errorf("%s: %s: %w", source, f.key, err)
+	}
+	c.sources[f.key] = source
+	return nil
+}
+
+func assign(f field, v reflect.Value, raw any) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("must be positive")
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("want a string, got %v", raw)
+		}
+		if f.enum != nil && !slices.Contains(f.enum, s) {
+			return fmt.Errorf("want one of %s, got %q", strings.Join(slices.DeleteFunc(slices.Clone(f.enum), func(e string) bool { return e == "" }), ", "), s)
+		}
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := parseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := parseInt(raw)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func parseInt(raw any) (int64, error) {
+	switch x := raw.(type) {
+	case int64:
+		return x, nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("want an integer, got %q", x)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("want an integer, got %v", raw)
+}
+
+func parseBool(raw any) (bool, error) {
+	switch x := raw.(type) {
+	case bool:
+		return x, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		if err != nil {
+			return false, fmt.Errorf("want true or false, got %q", x)
+		}
+		return b, nil
+	}
+	return false, fmt.Errorf("want true or false, got %v", raw)
+}
+
+// parseDuration accepts a number of seconds or a Go duration string.
+func parseDuration(raw any) (time.Duration, error) {
+	switch x := raw.(type) {
+	case int64:
+		return time.Duration(x) * time.Second, nil
+	case float64:
+		return time.Duration(x * float64(time.Second)), nil
+	case string:
+		s := strings.TrimSpace(x)
+		if secs, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("want seconds or a duration like \"2m\", got %q", x)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("want seconds or a duration like \"2m\", got %v", raw)
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return strconv.Quote(time.Duration(v.Int()).String())
+	case v.Kind() == reflect.String:
+		return strconv.Quote(v.String())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
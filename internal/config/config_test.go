@@ -0,0 +1,271 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webforspeed/bono/internal/dotenv"
+)
+
+// setup isolates the test from the real user config and environment, and
+// returns the user config dir and a project dir.
+func setup(t *testing.T) (userDir, cwd string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	for _, f := range fields {
+		if f.env != "" {
+			t.Setenv(f.env, "")
+		}
+	}
+	userDir = filepath.Join(home, "bono")
+	cwd = t.TempDir()
+	for _, dir := range []string{userDir, filepath.Join(cwd, ".bono")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return userDir, cwd
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayersInOrder(t *testing.T) {
+	userDir, cwd := setup(t)
+	userFile := filepath.Join(userDir, "config.toml")
+	projectFile := filepath.Join(cwd, ProjectFile)
+	writeFile(t, userFile, `
+model = "user/model"
+base_url = "https://user.example"
+
+[limits]
+http_timeout = 60
+shell_timeout = "2m"
+`)
+	writeFile(t, projectFile, `
+model = "project/model"
+
+[web]
+search_engine = "native"
+`)
+	t.Setenv("API_TIMEOUT_SEC", "90")
+	t.Setenv("MODEL", "env/model")
+
+	c, err := Load(cwd, Options{Project: true, Flags: []Flag{{Name: "--model", Key: "model", Value: "flag/model"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		key, source string
+		got, want   any
+	}{
+		{"model", "flag --model", c.Model, "flag/model"},
+		{"base_url", userFile, c.BaseURL, "https://user.example"},
+		{"limits.http_timeout", "env API_TIMEOUT_SEC", c.Limits.HTTPTimeout, 90 * time.Second},
+		{"limits.shell_timeout", userFile, c.Limits.ShellTimeout, 2 * time.Minute},
+		{"web.search_engine", projectFile, c.Web.SearchEngine, "native"},
+		{"limits.max_chat_turns", SourceDefault, c.Limits.MaxChatTurns, 100},
+	}
+	for _, tc := range checks {
+		if tc.got != tc.want || c.Source(tc.key) != tc.source {
+			t.Errorf("%s = %v from %q, want %v from %q", tc.key, tc.got, c.Source(tc.key), tc.want, tc.source)
+		}
+	}
+}
+
+func TestUntrustedProjectAndDotEnv(t *testing.T) {
+	_, cwd := setup(t)
+	writeFile(t, filepath.Join(cwd, ProjectFile), "model = \"project/model\"\n")
+	t.Setenv("EMBEDDING_MODEL", "env/embed")
+	env := dotenv.Env{"MODEL": "dotenv/model", "EMBEDDING_MODEL": "dotenv/embed"}
+
+	c, err := Load(cwd, Options{Env: env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "dotenv/model" || c.Source("model") != "env MODEL (.env)" {
+		t.Errorf("model = %q from %q, want the .env value", c.Model, c.Source("model"))
+	}
+	if c.Index.EmbeddingModel != "env/embed" || c.Source("index.embedding_model") != "env EMBEDDING_MODEL" {
+		t.Errorf("embedding_model = %q from %q, want the process value", c.Index.EmbeddingModel, c.Source("index.embedding_model"))
+	}
+
+	t.Setenv("MODEL", "")
+	c, err = Load(cwd, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "" {
+		t.Errorf("untrusted project: model = %q, want the project file ignored", c.Model)
+	}
+}
+
+func TestLoadErrorsNameFileAndKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		env     map[string]string
+		flags   []Flag
+		want    []string
+	}{
+		{name: "unknown key", project: "[limits]\nmax_turns = 5\n", want: []string{ProjectFile, `"limits.max_turns"`}},
+		{name: "unknown table", project: "[limit]\nmax_chat_turns = 5\n", want: []string{ProjectFile, `"limit"`}},
+		{name: "wrong type", project: "model = 5\n", want: []string{ProjectFile, "model", "want a string"}},
+		{name: "negative", project: "[limits]\nmax_chat_turns = -1\n", want: []string{ProjectFile, "limits.max_chat_turns"}},
+		{name: "bad duration", project: "[limits]\nhttp_timeout = \"soon\"\n", want: []string{ProjectFile, "limits.http_timeout"}},
+		{name: "syntax", project: "model = \n", want: []string{ProjectFile}},
+		{name: "env", env: map[string]string{"EMBEDDING_DIMS": "many"}, want: []string{"env EMBEDDING_DIMS", "index.embedding_dims"}},
+		{name: "flag", flags: []Flag{{Name: "--set", Key: "nope", Value: "1"}}, want: []string{"flag --set", `"nope"`}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, cwd := setup(t)
+			if tc.project != "" {
+				writeFile(t, filepath.Join(cwd, ProjectFile), tc.project)
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(cwd, Options{Project: true, Flags: tc.flags})
+			if err == nil {
+				t.Fatal("Load returned nil error")
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProfilesLayerBetweenEnvAndFlags(t *testing.T) {
+	userDir, cwd := setup(t)
+	userFile := filepath.Join(userDir, "config.toml")
+	writeFile(t, userFile, `
+model = "user/model"
+
+[profiles.fast]
+model = "fast/model"
+reasoning = "low"
+
+[profiles.fast.limits]
+max_chat_turns = 20
+`)
+	writeFile(t, filepath.Join(cwd, ProjectFile), `
+[profiles.deep]
+reasoning = "xhigh"
+`)
+	t.Setenv("MODEL", "env/model")
+	t.Setenv("BONO_PROFILE", "fast")
+
+	c, err := Load(cwd, Options{Project: true, Flags: []Flag{{Name: "--set", Key: "limits.max_chat_turns", Value: "5"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Profiles(); strings.Join(got, ",") != "deep,fast" {
+		t.Errorf("Profiles() = %v", got)
+	}
+	source := "profile fast (" + userFile + ")"
+	if c.Model != "fast/model" || c.Source("model") != source {
+		t.Errorf("model = %q from %q, want profile value", c.Model, c.Source("model"))
+	}
+	if c.Reasoning != "low" || c.Limits.MaxChatTurns != 5 {
+		t.Errorf("reasoning = %q, max_chat_turns = %d", c.Reasoning, c.Limits.MaxChatTurns)
+	}
+
+	deep, err := Load(cwd, Options{Project: true, Flags: []Flag{{Name: "--profile", Key: "profile", Value: "deep"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deep.Model != "env/model" || deep.Reasoning != "xhigh" {
+		t.Errorf("deep: model = %q, reasoning = %q", deep.Model, deep.Reasoning)
+	}
+	if got := strings.Join(c.Diff(deep), ","); got != "profile,model,reasoning,limits.max_chat_turns" {
+		t.Errorf("Diff = %s", got)
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		flags   []Flag
+		want    []string
+	}{
+		{name: "unknown profile", flags: []Flag{{Name: "--profile", Key: "profile", Value: "nope"}}, want: []string{"flag --profile", `"nope"`}},
+		{name: "bad value", project: "[profiles.x]\nreasoning = \"max\"\n", want: []string{ProjectFile, "profiles.x.reasoning", "want one of"}},
+		{name: "unknown key", project: "[profiles.x]\nturns = 1\n", want: []string{ProjectFile, `"profiles.x.turns"`}},
+		{name: "nested profile", project: "[profiles.x]\nprofile = \"y\"\n", want: []string{ProjectFile, "profiles.x.profile"}},
+		{name: "top-level enum", project: "reasoning = \"max\"\n", want: []string{ProjectFile, "reasoning", "xhigh"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, cwd := setup(t)
+			if tc.project != "" {
+				writeFile(t, filepath.Join(cwd, ProjectFile), tc.project)
+			}
+			_, err := Load(cwd, Options{Project: true, Flags: tc.flags})
+			if err == nil {
+				t.Fatal("Load returned nil error")
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShowMasksSecretsAndNamesSources(t *testing.T) {
+	_, cwd := setup(t)
+	t.Setenv("OPENROUTER_API_KEY", "sk-secret")
+	c, err := Load(cwd, Options{Project: true, Flags: []Flag{{Name: "--set", Key: "limits.max_chat_turns", Value: "7"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := c.Show(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	if strings.Contains(text, "sk-secret") {
+		t.Fatalf("Show leaked the API key:\n%s", text)
+	}
+	for _, want := range []string{
+		`api_key = "********"`,
+		"# env OPENROUTER_API_KEY",
+		"limits.max_chat_turns = 7",
+		"# flag --set",
+		`limits.http_timeout = "2m0s"`,
+		"# default",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Show output missing %q:\n%s", want, text)
+		}
+	}
+	if lines := strings.Count(text, "\n"); lines != len(Keys()) {
+		t.Errorf("Show printed %d lines, want one per key (%d)", lines, len(Keys()))
+	}
+}
+
+func TestCoreUsesConfiguredValues(t *testing.T) {
+	c := Default()
+	c.Index.EmbeddingDims = 512
+	cc := c.Core("some/model", "prompt")
+	if cc.Model != "some/model" || cc.HTTPTimeout != 120*time.Second || cc.Sandbox.CommandTimeout != 30*time.Second {
+		t.Fatalf("core config = %+v", cc)
+	}
+	if cc.MaxChatTurns != 100 || cc.CodeSearch.DBPath != ".bono/index.db" || cc.CodeSearch.Dims != 512 {
+		t.Fatalf("core config = %+v", cc)
+	}
+}
@@ -0,0 +1,130 @@
+// Package diff computes line diffs and the change hunks used for review,
+// partial undo and rendering.
+package diff
+
+import "strings"
+
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of an edit script. Text keeps its line terminator.
+type Op struct {
+	Kind OpKind
+	Text string
+}
+
+// SplitLines splits s into lines, keeping each line's "\n" so that joining
+// the result reproduces s exactly.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Compute returns a minimal line-level edit script turning a into b, using
+// Myers' O(ND) algorithm in its linear-space (middle snake) form.
+func Compute(a, b []string) []Op {
+	ops := make([]Op, 0, len(a)+len(b))
+	return myers(a, b, ops)
+}
+
+func myers(a, b []string, ops []Op) []Op {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for _, line := range a[:pre] {
+		ops = append(ops, Op{Kind: Equal, Text: line})
+	}
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	switch {
+	case len(midA) == 0:
+		for _, line := range midB {
+			ops = append(ops, Op{Kind: Insert, Text: line})
+		}
+	case len(midB) == 0:
+		for _, line := range midA {
+			ops = append(ops, Op{Kind: Delete, Text: line})
+		}
+	default:
+		x, y, u, v := middleSnake(midA, midB)
+		ops = myers(midA[:x], midB[:y], ops)
+		for _, line := range midA[x:u] {
+			ops = append(ops, Op{Kind: Equal, Text: line})
+		}
+		ops = myers(midA[u:], midB[v:], ops)
+	}
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, Op{Kind: Equal, Text: line})
+	}
+	return ops
+}
+
+// middleSnake finds the middle snake of an optimal edit path between a and
+// b: the diagonal run from (x, y) to (u, v) where the forward and backward
+// searches meet. Both inputs must be non-empty.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	off := maxD + 1
+	// vf[off+k] is the furthest x reached on forward diagonal k = x-y; vb is
+	// the same for the backward search, measured from the end of both inputs.
+	vf := make([]int, 2*maxD+3)
+	vb := make([]int, 2*maxD+3)
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && x+vb[off+kb] >= n {
+				return x0, y0, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if kf := delta - k; !odd && kf >= -d && kf <= d && x+vf[off+kf] >= n {
+				return n - x, m - y, n - x0, m - y0
+			}
+		}
+	}
+	// Unreachable: an edit path of length at most n+m always exists.
+	return 0, 0, 0, 0
+}
@@ -0,0 +1,138 @@
+package diff
+
+import "testing"
+
+func TestSplitLinesRoundTrips(t *testing.T) {
+	for _, s := range []string{"", "a", "a\n", "a\nb", "a\nb\n", "\n\n"} {
+		var joined string
+		for _, l := range SplitLines(s) {
+			joined += l
+		}
+		if joined != s {
+			t.Errorf("SplitLines(%q) joined = %q", s, joined)
+		}
+	}
+}
+
+func TestHunksGroupsAdjacentChanges(t *testing.T) {
+	old := "a\nb\nc\nd\ne\n"
+	new := "a\nB\nc\nd\ne\nf\n"
+	hunks := Hunks(old, new)
+	if len(hunks) != 2 {
+		t.Fatalf("len(hunks) = %d, want 2", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -2 +2 @@" {
+		t.Fatalf("hunk 0 header = %q", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -5,0 +6 @@" {
+		t.Fatalf("hunk 1 header = %q", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	old := "a\nb\nc\nd\ne\n"
+	new := "a\nB\nc\nd\ne\nf\n"
+	tests := []struct {
+		name string
+		keep []bool
+		want string
+	}{
+		{"keep all", []bool{true, true}, new},
+		{"revert all", []bool{false, false}, old},
+		{"keep first", []bool{true, false}, "a\nB\nc\nd\ne\n"},
+		{"keep second", []bool{false, true}, "a\nb\nc\nd\ne\nf\n"},
+	}
+	for _, tt := range tests {
+		got := Select(old, new, func(i int) bool { return tt.keep[i] })
+		if got != tt.want {
+			t.Errorf("%s: Select = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectPreservesMissingFinalNewline(t *testing.T) {
+	got := Select("a\nb", "a\nB", func(int) bool { return false })
+	if got != "a\nb" {
+		t.Fatalf("Select = %q, want %q", got, "a\nb")
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+		ok           bool
+	}{
+		{"disjoint", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", true},
+		{"one side", base, "a\nb\nC\nd\ne\n", "a\nb\nC\nd\ne\n", true},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", true},
+		{"insert and delete", "a\nb\nx\nc\nd\ne\n", "a\nb\nc\nd\n", "a\nb\nx\nc\nd\n", true},
+		{"overlap", "a\nB\nc\nd\ne\n", "a\nX\nc\nd\ne\n", "", false},
+		{"adjacent", "a\nB\nc\nd\ne\n", "a\nb\nC\nd\ne\n", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Merge3(base, tt.ours, tt.theirs)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: Merge3 = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestComputeIsMinimal(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"a\nb\nc\n", "x\na\nb\nc\n", 1},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+		{"a\nb\nc\nd\n", "d\nc\nb\na\n", 6},
+		{"", "a\nb\n", 2},
+		{"a\nb\n", "", 2},
+	}
+	for _, tt := range tests {
+		a, b := SplitLines(tt.a), SplitLines(tt.b)
+		ops := Compute(a, b)
+		edits := 0
+		var gotA, gotB string
+		for _, op := range ops {
+			switch op.Kind {
+			case Equal:
+				gotA += op.Text
+				gotB += op.Text
+			case Delete:
+				edits++
+				gotA += op.Text
+			case Insert:
+				edits++
+				gotB += op.Text
+			}
+		}
+		if gotA != tt.a || gotB != tt.b {
+			t.Errorf("Compute(%q, %q) does not reproduce its inputs", tt.a, tt.b)
+		}
+		if edits != tt.edits {
+			t.Errorf("Compute(%q, %q) edits = %d, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}
+
+func TestContextHunksMergesNearbyChanges(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\nTWELVE\n"
+	ops := Compute(SplitLines(old), SplitLines(new))
+
+	if hunks := ContextHunks(ops, 3); len(hunks) != 2 {
+		t.Fatalf("context 3: len(hunks) = %d, want 2", len(hunks))
+	} else if got := hunks[0].Header(); got != "@@ -1,5 +1,5 @@" {
+		t.Fatalf("context 3: hunk 0 header = %q", got)
+	}
+	hunks := ContextHunks(ops, 5)
+	if len(hunks) != 1 {
+		t.Fatalf("context 5: len(hunks) = %d, want 1", len(hunks))
+	}
+	if got := hunks[0].Header(); got != "@@ -1,12 +1,12 @@" {
+		t.Fatalf("context 5: header = %q", got)
+	}
+}
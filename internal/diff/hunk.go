@@ -0,0 +1,113 @@
+package diff
+
+import "fmt"
+
+// DefaultContext is the number of unchanged lines shown around each change,
+// as in diff -u and git.
+const DefaultContext = 3
+
+// Hunk is one run of changed lines, optionally padded with unchanged context
+// lines. Starts are 0-based line indexes into the old and new files; counts
+// include context.
+type Hunk struct {
+	OldStart, OldCount int
+	NewStart, NewCount int
+	Ops                []Op
+}
+
+// Header renders the hunk's unified-diff range line (1-based).
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldCount), hunkRange(h.NewStart, h.NewCount))
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Hunks groups the changes between oldContent and newContent into hunks
+// without context, so each hunk holds only Delete and Insert ops. These are
+// the units of per-hunk review.
+func Hunks(oldContent, newContent string) []Hunk {
+	return ContextHunks(Compute(SplitLines(oldContent), SplitLines(newContent)), 0)
+}
+
+// ContextHunks groups an edit script into hunks with up to context unchanged
+// lines on either side. Changes separated by at most 2*context unchanged
+// lines share a hunk, as in unified diffs.
+func ContextHunks(ops []Op, context int) []Hunk {
+	// oldAt[i] and newAt[i] are the line indexes in front of ops[i].
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if op.Kind != Insert {
+			oldAt[i+1]++
+		}
+		if op.Kind != Delete {
+			newAt[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			i++
+			continue
+		}
+		end := i
+		for {
+			for end < len(ops) && ops[end].Kind != Equal {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].Kind == Equal {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		lo, hi := max(i-context, 0), min(end+context, len(ops))
+		hunks = append(hunks, Hunk{
+			OldStart: oldAt[lo],
+			OldCount: oldAt[hi] - oldAt[lo],
+			NewStart: newAt[lo],
+			NewCount: newAt[hi] - newAt[lo],
+			Ops:      append([]Op(nil), ops[lo:hi]...),
+		})
+		i = hi
+	}
+	return hunks
+}
+
+// Select rebuilds newContent with only some hunks applied: hunks for which
+// keep returns false are restored to their oldContent lines.
+func Select(oldContent, newContent string, keep func(hunk int) bool) string {
+	ops := Compute(SplitLines(oldContent), SplitLines(newContent))
+	var out []byte
+	hunk := -1
+	inHunk := false
+	for _, op := range ops {
+		if op.Kind == Equal {
+			inHunk = false
+			out = append(out, op.Text...)
+			continue
+		}
+		if !inHunk {
+			inHunk = true
+			hunk++
+		}
+		kept := keep(hunk)
+		if (op.Kind == Insert && kept) || (op.Kind == Delete && !kept) {
+			out = append(out, op.Text...)
+		}
+	}
+	return string(out)
+}
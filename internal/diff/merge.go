@@ -0,0 +1,77 @@
+package diff
+
+import "strings"
+
+// Merge3 combines two independent edits of base, line by line. Changes that
+// touch disjoint regions of base are both applied; where the two sides change
+// the same or adjacent lines, their results must be identical, otherwise
+// Merge3 reports a conflict by returning false.
+func Merge3(base, ours, theirs string) (string, bool) {
+	lines := SplitLines(base)
+	a, b := Hunks(base, ours), Hunks(base, theirs)
+
+	var out strings.Builder
+	pos, i, j := 0, 0, 0
+	for i < len(a) || j < len(b) {
+		start := len(lines)
+		if i < len(a) {
+			start = a[i].OldStart
+		}
+		if j < len(b) && b[j].OldStart < start {
+			start = b[j].OldStart
+		}
+
+		// Grow a cluster of overlapping or touching hunks from both sides.
+		end := start
+		var ca, cb []Hunk
+		for grown := true; grown; {
+			grown = false
+			if i < len(a) && a[i].OldStart <= end {
+				ca = append(ca, a[i])
+				end = max(end, a[i].OldStart+a[i].OldCount)
+				i++
+				grown = true
+			}
+			if j < len(b) && b[j].OldStart <= end {
+				cb = append(cb, b[j])
+				end = max(end, b[j].OldStart+b[j].OldCount)
+				j++
+				grown = true
+			}
+		}
+
+		out.WriteString(strings.Join(lines[pos:start], ""))
+		switch {
+		case len(cb) == 0:
+			out.WriteString(applyHunks(lines, start, end, ca))
+		case len(ca) == 0:
+			out.WriteString(applyHunks(lines, start, end, cb))
+		default:
+			ours, theirs := applyHunks(lines, start, end, ca), applyHunks(lines, start, end, cb)
+			if ours != theirs {
+				return "", false
+			}
+			out.WriteString(ours)
+		}
+		pos = end
+	}
+	out.WriteString(strings.Join(lines[pos:], ""))
+	return out.String(), true
+}
+
+// applyHunks returns lines[start:end] with hunks, all inside that range, applied.
+func applyHunks(lines []string, start, end int, hunks []Hunk) string {
+	var sb strings.Builder
+	pos := start
+	for _, h := range hunks {
+		sb.WriteString(strings.Join(lines[pos:h.OldStart], ""))
+		for _, op := range h.Ops {
+			if op.Kind == Insert {
+				sb.WriteString(op.Text)
+			}
+		}
+		pos = h.OldStart + h.OldCount
+	}
+	sb.WriteString(strings.Join(lines[pos:end], ""))
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// File is one file of a patch. Path is slash-separated and relative to the
+// directory the patch will be applied in.
+type File struct {
+	Path    string
+	Old     string
+	New     string
+	Created bool
+	Deleted bool
+}
+
+// WritePatch writes files as a unified diff in git's format, so the result
+// applies with git apply or patch -p1. Files without changes are skipped.
+func WritePatch(w io.Writer, files []File, context int) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		hunks := ContextHunks(Compute(SplitLines(f.Old), SplitLines(f.New)), context)
+		if len(hunks) == 0 && !f.Created && !f.Deleted {
+			continue
+		}
+		fmt.Fprintf(bw, "diff --git a/%s b/%s\n", f.Path, f.Path)
+		oldName, newName := "a/"+f.Path, "b/"+f.Path
+		switch {
+		case f.Created:
+			bw.WriteString("new file mode 100644\n")
+			oldName = "/dev/null"
+		case f.Deleted:
+			bw.WriteString("deleted file mode 100644\n")
+			newName = "/dev/null"
+		}
+		if len(hunks) == 0 {
+			// Empty file created or deleted: the header says it all.
+			continue
+		}
+		fmt.Fprintf(bw, "--- %s\n+++ %s\n", oldName, newName)
+		for _, h := range hunks {
+			bw.WriteString(h.Header() + "\n")
+			for _, op := range h.Ops {
+				bw.WriteString(opPrefix(op.Kind) + op.Text)
+				if !strings.HasSuffix(op.Text, "\n") {
+					bw.WriteString("\n\\ No newline at end of file\n")
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func opPrefix(kind OpKind) string {
+	switch kind {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWritePatchFormat(t *testing.T) {
+	var sb strings.Builder
+	err := WritePatch(&sb, []File{
+		{Path: "a.txt", Old: "one\ntwo\n", New: "one\nTWO"},
+		{Path: "new.txt", New: "hi\n", Created: true},
+	}, DefaultContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "diff --git a/a.txt b/a.txt\n" +
+		"--- a/a.txt\n" +
+		"+++ b/a.txt\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"+TWO\n" +
+		"\\ No newline at end of file\n" +
+		"diff --git a/new.txt b/new.txt\n" +
+		"new file mode 100644\n" +
+		"--- /dev/null\n" +
+		"+++ b/new.txt\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+hi\n"
+	if sb.String() != want {
+		t.Fatalf("patch =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+func TestWritePatchAppliesWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("edit.txt", "a\nb\nc\nd\ne\nf\ng\nh\n")
+	write("gone.txt", "bye\n")
+
+	var sb strings.Builder
+	err := WritePatch(&sb, []File{
+		{Path: "edit.txt", Old: "a\nb\nc\nd\ne\nf\ng\nh\n", New: "x\na\nb\nc\nd\ne\nf\nG\nh"},
+		{Path: "gone.txt", Old: "bye\n", Deleted: true},
+		{Path: "sub/new.txt", New: "hello\n", Created: true},
+	}, DefaultContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := filepath.Join(dir, "change.patch")
+	write("change.patch", sb.String())
+
+	cmd := exec.Command("git", "apply", patch)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply: %v: %s\npatch:\n%s", err, out, sb.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "edit.txt")); string(data) != "x\na\nb\nc\nd\ne\nf\nG\nh" {
+		t.Fatalf("edit.txt = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !os.IsNotExist(err) {
+		t.Fatalf("gone.txt still exists")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "sub", "new.txt")); string(data) != "hello\n" {
+		t.Fatalf("sub/new.txt = %q", data)
+	}
+}
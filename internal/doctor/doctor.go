@@ -0,0 +1,180 @@
+// Package doctor runs environment checks and reports them as a pass/warn/fail
+// list for people or as JSON for scripts.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn" // bono works, with a feature missing or degraded
+	Fail Status = "fail" // bono or a core feature will not work
+)
+
+// DefaultTimeout bounds each check.
+const DefaultTimeout = 10 * time.Second
+
+// Result is one line of the report.
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Check is a named diagnostic. Run should respect ctx's deadline.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Run runs checks in order, each with DefaultTimeout. A check that panics
+// fails; one that overruns its deadline fails as timed out.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, len(checks))
+	for i, c := range checks {
+		results[i] = run(ctx, c)
+	}
+	return results
+}
+
+func run(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	done := make(chan Result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- Result{Name: c.Name, Status: Fail, Detail: fmt.Sprintf("check panicked: %v", r)}
+			}
+		}()
+		status, detail := c.Run(ctx)
+		done <- Result{Name: c.Name, Status: status, Detail: detail}
+	}()
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return Result{Name: c.Name, Status: Fail, Detail: "timed out"}
+	}
+}
+
+// Failed reports whether any result failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints one aligned line per result and a summary.
+func Write(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		fmt.Fprintf(tw, "[%s]\t%s\t%s\n", r.Status, r.Name, r.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", counts[Pass], counts[Warn], counts[Fail])
+	return err
+}
+
+// WriteJSON prints the report as {"ok": bool, "checks": [...]}.
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		OK     bool     `json:"ok"`
+		Checks []Result `json:"checks"`
+	}{!Failed(results), results})
+}
+
+// Reachable checks that an HTTP GET of url gets any response below 500.
+// Unreachable endpoints get the status missing.
+func Reachable(name, url string, missing Status) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (Status, string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return missing, fmt.Sprintf("%s unreachable: %v", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return Warn, fmt.Sprintf("%s answered %s", url, resp.Status)
+		}
+		return Pass, url
+	}}
+}
+
+// Authorized checks that an HTTP GET of url with key as a bearer token is
+// accepted. A 401 or 403 fails; other errors warn, since reachability is
+// left to Reachable.
+func Authorized(name, url, key string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (Status, string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return Fail, err.Error()
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return Warn, fmt.Sprintf("could not verify: %v", err)
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return Fail, fmt.Sprintf("rejected by %s (%s)", url, resp.Status)
+		case resp.StatusCode >= 400:
+			return Warn, fmt.Sprintf("could not verify: %s answered %s", url, resp.Status)
+		}
+		return Pass, "accepted by " + url
+	}}
+}
+
+// Executable checks that file is on PATH.
+func Executable(name, file string, missing Status) Check {
+	return Check{Name: name, Run: func(context.Context) (Status, string) {
+		path, err := exec.LookPath(file)
+		if err != nil {
+			return missing, file + " not found on PATH"
+		}
+		return Pass, path
+	}}
+}
+
+// Writable checks that a file can be created in dir, creating dir if needed.
+func Writable(name, dir string) Check {
+	return Check{Name: name, Run: func(context.Context) (Status, string) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return Fail, err.Error()
+		}
+		f, err := os.CreateTemp(dir, ".bono-doctor-*")
+		if err != nil {
+			return Fail, err.Error()
+		}
+		f.Close()
+		os.Remove(f.Name())
+		abs, _ := filepath.Abs(dir)
+		return Pass, abs
+	}}
+}
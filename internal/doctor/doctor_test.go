@@ -0,0 +1,125 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRecoversPanicsAndTimeouts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results := Run(ctx, []Check{
+		{Name: "ok", Run: func(context.Context) (Status, string) { return Pass, "fine" }},
+		{Name: "panics", Run: func(context.Context) (Status, string) { panic("boom") }},
+		{Name: "hangs", Run: func(context.Context) (Status, string) { select {} }},
+	})
+	want := []Result{
+		{Name: "ok", Status: Pass, Detail: "fine"},
+		{Name: "panics", Status: Fail, Detail: "check panicked: boom"},
+		{Name: "hangs", Status: Fail, Detail: "timed out"},
+	}
+	for i, r := range results {
+		if r != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, r, want[i])
+		}
+	}
+	if !Failed(results) {
+		t.Fatal("Failed = false")
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	results := []Result{
+		{Name: "git", Status: Pass, Detail: "/usr/bin/git"},
+		{Name: "sandbox", Status: Warn, Detail: "unavailable"},
+	}
+	var text strings.Builder
+	if err := Write(&text, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[pass]  git", "[warn]  sandbox", "1 passed, 1 warnings, 0 failed"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Write output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var buf strings.Builder
+	if err := WriteJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		OK     bool     `json:"ok"`
+		Checks []Result `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK || len(report.Checks) != 2 || report.Checks[1].Status != Warn {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if status, _ := Reachable("up", srv.URL+"/up", Fail).Run(ctx); status != Pass {
+		t.Errorf("reachable endpoint: %s", status)
+	}
+	if status, _ := Reachable("down", srv.URL+"/down", Fail).Run(ctx); status != Warn {
+		t.Errorf("5xx endpoint: %s", status)
+	}
+	srv.Close()
+	if status, detail := Reachable("gone", srv.URL, Warn).Run(ctx); status != Warn || !strings.Contains(detail, "unreachable") {
+		t.Errorf("closed endpoint: %s %q", status, detail)
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+		case "Bearer flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if status, detail := Authorized("key", srv.URL, "good").Run(ctx); status != Pass {
+		t.Errorf("valid key: %s %q", status, detail)
+	}
+	if status, detail := Authorized("key", srv.URL, "revoked").Run(ctx); status != Fail || !strings.Contains(detail, "401") {
+		t.Errorf("rejected key: %s %q", status, detail)
+	}
+	if status, _ := Authorized("key", srv.URL, "flaky").Run(ctx); status != Warn {
+		t.Errorf("5xx while verifying: %s", status)
+	}
+	srv.Close()
+	if status, _ := Authorized("key", srv.URL, "good").Run(ctx); status != Warn {
+		t.Errorf("unreachable endpoint: %s", status)
+	}
+}
+
+func TestWritableAndExecutable(t *testing.T) {
+	ctx := context.Background()
+	if status, detail := Writable("logs", filepath.Join(t.TempDir(), "logs")).Run(ctx); status != Pass {
+		t.Errorf("Writable: %s %q", status, detail)
+	}
+	if status, _ := Executable("missing", "bono-doctor-no-such-binary", Warn).Run(ctx); status != Warn {
+		t.Errorf("Executable missing binary: %s", status)
+	}
+}
@@ -0,0 +1,240 @@
+// Package dotenv reads .env files:
+//
+//	# comment
+//	export NAME=value             # trailing comment
+//	GREETING="hello\n${NAME}"
+//	LITERAL='no $expansion here'
+//
+// Double-quoted values understand the escapes \n, \r, \t, \", \\ and \$ and
+// may span lines. Unquoted and double-quoted values expand ${VAR} and $VAR,
+// looking in the process environment first and then at earlier entries in
+// the file. Single-quoted values are taken literally.
+//
+// Variables are never exported to the process environment, so they do not
+// reach shell commands or hooks; callers look them up through Env.
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// FileName is the project .env path relative to the workspace root.
+const FileName = ".env"
+
+// Env holds the variables from a .env file.
+type Env map[string]string
+
+// Lookup returns key from the process environment, falling back to e, so
+// variables that are already set win over the file. A nil Env only consults
+// the process environment.
+func (e Env) Lookup(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	v, ok := e[key]
+	return v, ok
+}
+
+// Getenv is Lookup without the presence flag.
+func (e Env) Getenv(key string) string {
+	v, _ := e.Lookup(key)
+	return v
+}
+
+// Expand replaces ${VAR} and $VAR in s using Getenv.
+func (e Env) Expand(s string) string {
+	return os.Expand(s, e.Getenv)
+}
+
+// Read parses the file at path. A missing file is an empty Env; errors name
+// the file and line.
+func Read(path string) (Env, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Env{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	env, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return env, nil
+}
+
+// Parse parses .env content. Errors name the line.
+func Parse(text string) (Env, error) {
+	p := parser{text: strings.ReplaceAll(text, "\r\n", "\n"), line: 1, env: Env{}}
+	for {
+		p.skipSpace()
+		if p.done() {
+			return p.env, nil
+		}
+		switch p.peek() {
+		case '\n':
+			p.next()
+			continue
+		case '#':
+			p.skipLine()
+			continue
+		}
+		if err := p.entry(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+	}
+}
+
+type parser struct {
+	text string
+	pos  int
+	line int
+	env  Env
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.text) }
+func (p *parser) peek() byte { return p.text[p.pos] }
+
+func (p *parser) next() byte {
+	c := p.text[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) skipLine() {
+	for !p.done() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// entry parses one NAME=value line.
+func (p *parser) entry() error {
+	name := p.name()
+	if name == "export" && !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpace()
+		name = p.name()
+	}
+	if name == "" {
+		return errors.New("expected a variable name")
+	}
+	p.skipSpace()
+	if p.done() || p.peek() != '=' {
+		return fmt.Errorf("%s: expected '='", name)
+	}
+	p.pos++
+	p.skipSpace()
+
+	var value string
+	var err error
+	switch {
+	case p.done():
+	case p.peek() == '\'':
+		value, err = p.quoted('\'')
+	case p.peek() == '"':
+		value, err = p.quoted('"')
+	default:
+		value = p.unquoted()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	// Only a comment may follow the value.
+	p.skipSpace()
+	if !p.done() && p.peek() != '\n' && p.peek() != '#' {
+		return fmt.Errorf("%s: unexpected text after the closing quote", name)
+	}
+	p.skipLine()
+	p.env[name] = value
+	return nil
+}
+
+func (p *parser) name() string {
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if c == '_' || c == '.' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || p.pos > start && '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.text[start:p.pos]
+}
+
+// unquoted reads up to the end of the line or a comment, which must follow
+// whitespace so values like URL fragments keep their '#'.
+func (p *parser) unquoted() string {
+	start := p.pos
+	for !p.done() && p.peek() != '\n' {
+		if p.peek() == '#' && (p.text[p.pos-1] == ' ' || p.text[p.pos-1] == '\t') {
+			break
+		}
+		p.pos++
+	}
+	return p.expand(strings.TrimRight(p.text[start:p.pos], " \t"))
+}
+
+// quoted reads a quoted value that may span lines.
+func (p *parser) quoted(quote byte) (string, error) {
+	startLine := p.line
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.done() {
+			p.line = startLine
+			return "", fmt.Errorf("unterminated %c quote", quote)
+		}
+		c := p.next()
+		switch {
+		case c == quote:
+			if quote == '"' {
+				return p.expand(b.String()), nil
+			}
+			return b.String(), nil
+		case c == '\\' && quote == '"' && !p.done():
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '$':
+				// Kept escaped so expand leaves it alone.
+				b.WriteString("$$")
+			case '"', '\\':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// expand replaces ${VAR} and $VAR, looking in the process environment and
+// then at earlier entries. "$$" is a literal '$'.
+func (p *parser) expand(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return p.env.Getenv(name)
+	})
+}
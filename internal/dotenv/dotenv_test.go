@@ -0,0 +1,85 @@
+package dotenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("DOTENV_TEST_HOME", "/home/me")
+	env, err := Parse(`
+# comment
+PLAIN=value
+export EXPORTED = spaced out   # trailing comment
+FRAGMENT=https://example.com/#top
+EMPTY=
+SINGLE='literal $PLAIN # not a comment'
+DOUBLE="tab\tquote\" dollar\$PLAIN ${PLAIN}"
+MULTI="line one
+line two"
+REF=${DOTENV_TEST_HOME}/$PLAIN
+WIN=line\r
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "spaced out",
+		"FRAGMENT": "https://example.com/#top",
+		"EMPTY":    "",
+		"SINGLE":   "literal $PLAIN # not a comment",
+		"DOUBLE":   "tab\tquote\" dollar$PLAIN value",
+		"MULTI":    "line one\nline two",
+		"REF":      "/home/me/value",
+		"WIN":      `line\r`,
+	}
+	for k, v := range want {
+		if got, ok := env[k]; !ok || got != v {
+			t.Errorf("%s = %q (set %v), want %q", k, got, ok, v)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("got %d variables, want %d: %v", len(env), len(want), env)
+	}
+}
+
+func TestParseErrorsNameLine(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"A=1\nnot a line\n", "line 2"},
+		{"A=1\nB=\"open\n\n", "line 2: B: unterminated \" quote"},
+		{"A='x' y\n", "line 1: A: unexpected text"},
+		{"=1\n", "line 1: expected a variable name"},
+	}
+	for _, tc := range tests {
+		if _, err := Parse(tc.text); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tc.text, err, tc.want)
+		}
+	}
+}
+
+func TestProcessEnvironmentWins(t *testing.T) {
+	t.Setenv("DOTENV_TEST_SET", "from-env")
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("DOTENV_TEST_SET=from-file\nDOTENV_TEST_ONLY=file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := env.Getenv("DOTENV_TEST_SET"); got != "from-env" {
+		t.Errorf("DOTENV_TEST_SET = %q, want the process value", got)
+	}
+	if got := env.Expand("${DOTENV_TEST_ONLY}-x"); got != "file-x" {
+		t.Errorf("Expand = %q", got)
+	}
+	if _, ok := os.LookupEnv("DOTENV_TEST_ONLY"); ok {
+		t.Error("Read exported a variable to the process environment")
+	}
+	if env, err := Read(filepath.Join(t.TempDir(), FileName)); err != nil || len(env) != 0 {
+		t.Errorf("missing file: env = %v, err = %v", env, err)
+	}
+}
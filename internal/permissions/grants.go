@@ -0,0 +1,237 @@
+package permissions
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GrantScope says how far a remembered approval reaches.
+type GrantScope string
+
+const (
+	// GrantExact allows this exact call again for the rest of the session.
+	GrantExact GrantScope = "exact"
+	// GrantPrefix allows calls sharing this call's prefix for the rest of the session.
+	GrantPrefix GrantScope = "prefix"
+	// GrantProject appends an allow rule to the project's policy file.
+	GrantProject GrantScope = "project"
+)
+
+// Grant is a standing approval the user can choose instead of approving once.
+type Grant struct {
+	Scope GrantScope
+	Rule  Rule
+}
+
+// Describe renders the grant as an approval choice.
+func (g Grant) Describe() string {
+	target := "this exact call"
+	if g.Rule.Pattern == "" {
+		target = "every " + g.Rule.Tool + " call"
+	} else if g.Scope != GrantExact {
+		target = fmt.Sprintf("%q", g.Rule.Pattern)
+	}
+	if g.Scope == GrantProject {
+		return "always allow " + target + " in this project"
+	}
+	return "allow " + target + " for this session"
+}
+
+// Grants lists the standing approvals offered for a tool call. Tools without
+// a matchable argument get a tool-wide grant; a prefix grant is only offered
+// when the call has a meaningful prefix. The project grant persists the
+// broadest session grant, unless the call spans several lines, which a
+// policy file line cannot hold.
+func Grants(tool string, args map[string]any, cwd string) []Grant {
+	subject := Subject(tool, args, cwd)
+	exact := Rule{Action: Allow, Tool: tool}
+	if subject != "" {
+		exact.Pattern = Escape(subject)
+	}
+	grants := []Grant{{Scope: GrantExact, Rule: exact}}
+	broadest := exact
+	if prefix := PrefixPattern(tool, subject); prefix != "" {
+		broadest = Rule{Action: Allow, Tool: tool, Pattern: prefix}
+		grants = append(grants, Grant{Scope: GrantPrefix, Rule: broadest})
+	}
+	if strings.ContainsAny(subject, "\n\r") {
+		return grants
+	}
+	return append(grants, Grant{Scope: GrantProject, Rule: broadest})
+}
+
+// PrefixPattern returns a pattern covering calls that share subject's prefix:
+// the command and subcommand of a shell command, the directory of a file, or
+// the host of a URL. It returns "" when there is no useful prefix, and for
+// shell commands that chain, pipe, redirect or substitute, where a wildcard
+// would reach past the command the user approved.
+func PrefixPattern(tool, subject string) string {
+	if subject == "" {
+		return ""
+	}
+	switch tool {
+	case "run_shell":
+		if hasShellControl(subject) {
+			return ""
+		}
+		prefix := CommandPrefix(subject)
+		if prefix == "" || prefix == subject {
+			return ""
+		}
+		return Escape(prefix) + " *"
+	case "read_file", "write_file", "edit_file":
+		dir := path.Dir(subject)
+		if dir == "." || dir == "/" || strings.HasPrefix(dir, "..") {
+			return ""
+		}
+		return Escape(dir) + "/*"
+	case "WebFetch":
+		u, err := url.Parse(subject)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return ""
+		}
+		return Escape(u.Scheme+"://"+u.Host) + "/*"
+	default:
+		return ""
+	}
+}
+
+// CommandPrefix returns the program name of a shell command plus its
+// subcommand, if the second word looks like one ("go test", "npm run").
+func CommandPrefix(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || strings.ContainsAny(fields[0], "|&;<>()$`") {
+		return ""
+	}
+	if len(fields) > 1 && isSubcommand(fields[1]) {
+		return fields[0] + " " + fields[1]
+	}
+	return fields[0]
+}
+
+// hasShellControl reports whether a shell command does more than run one
+// program: command separators, pipes, background jobs, redirections,
+// subshells, command substitution or more than one line.
+func hasShellControl(command string) bool {
+	return strings.ContainsAny(command, ";&|<>()`\n\r")
+}
+
+func isSubcommand(word string) bool {
+	for _, r := range word {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
+		}
+	}
+	return word != "" && word[0] != '-'
+}
+
+// Grant records a standing approval. Session grants live in memory; project
+// grants are appended to the project policy file so later sessions keep them.
+func (p *Policy) Grant(g Grant) error {
+	rule := g.Rule
+	rule.Action = Allow
+	rule.File, rule.Line = "", 0
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if g.Scope == GrantProject {
+		if !p.Project {
+			return fmt.Errorf("save permission: %s is ignored until this folder is trusted (run bono trust)", FileName)
+		}
+		if strings.ContainsAny(rule.Text(), "\n\r") {
+			return fmt.Errorf("save permission: a multi-line rule cannot be written to %s", FileName)
+		}
+		file := filepath.Join(p.CWD, FileName)
+		line, err := appendRule(file, rule)
+		if err != nil {
+			return fmt.Errorf("save permission: %w", err)
+		}
+		rule.File, rule.Line = file, line
+		if !slices.Contains(p.files, file) {
+			p.files = append(p.files, file)
+		}
+		// Keep file rules ahead of session grants, matching load order.
+		i := len(p.Rules)
+		for i > 0 && p.Rules[i-1].File == "" {
+			i--
+		}
+		p.Rules = slices.Insert(p.Rules, i, rule)
+		return nil
+	}
+	p.Rules = append(p.Rules, rule)
+	return nil
+}
+
+// Revoke removes a rule previously returned by List. File-backed rules are
+// deleted from their file, which is then re-read.
+func (p *Policy) Revoke(rule Rule) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rule.File == "" {
+		if i := slices.Index(p.Rules, rule); i >= 0 {
+			p.Rules = slices.Delete(p.Rules, i, i+1)
+			return nil
+		}
+		return fmt.Errorf("no such rule: %s", rule)
+	}
+
+	if err := removeLine(rule.File, rule.Line, rule.Text()); err != nil {
+		return err
+	}
+	rules, err := readFiles(p.files)
+	if err != nil {
+		return err
+	}
+	for _, r := range p.Rules {
+		if r.File == "" {
+			rules = append(rules, r)
+		}
+	}
+	p.Rules = rules
+	return nil
+}
+
+func appendRule(file string, rule Rule) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	text := string(data)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	text += rule.Text() + "\n"
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+		return 0, err
+	}
+	return strings.Count(text, "\n"), nil
+}
+
+// removeLine deletes a rule's line from file, refusing if the file changed
+// underneath us and the line no longer holds the same rule.
+func removeLine(file string, lineNo int, want string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lineNo < 1 || lineNo > len(lines) {
+		return fmt.Errorf("%s:%d: rule not found", file, lineNo)
+	}
+	action, rest := cutField(lines[lineNo-1])
+	tool, pattern := cutField(rest)
+	got := Rule{Action: Action(strings.ToLower(action)), Tool: tool, Pattern: pattern}
+	if got.Text() != want {
+		return fmt.Errorf("%s:%d: file changed, expected %q", file, lineNo, want)
+	}
+	lines = append(lines[:lineNo-1], lines[lineNo:]...)
+	return os.WriteFile(file, []byte(strings.Join(lines, "")), 0o644)
+}
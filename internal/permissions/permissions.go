@@ -0,0 +1,361 @@
+// Package permissions evaluates declarative allow/ask/deny rules for tool calls.
+//
+// Rules live in plain-text files, one per line:
+//
+//	# action  tool        pattern (optional, rest of line)
+//	allow     run_shell   go test ./...
+//	deny      write_file  migrations/*
+//	ask       WebFetch
+//
+// The pattern is matched against the tool's primary argument (the shell
+// command, the file path, the URL, ...). '*' matches any run of characters
+// and '?' matches one character; a backslash makes the next character
+// literal. An omitted pattern matches every call. The tool name '*' matches
+// every tool.
+//
+// A run_shell allow rule whose pattern has a wildcard never matches a
+// command that chains, pipes, redirects, opens a subshell or substitutes,
+// so "allow run_shell go test *" cannot approve "go test ./...; rm -rf ~".
+// Such commands are only allowed by a rule without wildcards or without a
+// pattern. Deny and ask rules work the other way: they match a chained
+// command when they match any command in the chain, so "deny run_shell
+// git push*" also stops "cd . && git push origin main".
+package permissions
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Action is the outcome of a matching rule.
+type Action string
+
+const (
+	// None means no rule matched; the session's built-in routing applies.
+	None  Action = ""
+	Allow Action = "allow"
+	Ask   Action = "ask"
+	Deny  Action = "deny"
+)
+
+// FileName is the project-level policy path relative to the workspace root.
+const FileName = ".bono/permissions"
+
+// Rule is one parsed policy line.
+type Rule struct {
+	Action  Action
+	Tool    string
+	Pattern string
+	File    string // policy file the rule was loaded from; empty for session grants
+	Line    int
+}
+
+// Source names where the rule came from: file:line, or "session" for grants
+// that only live as long as the process.
+func (r Rule) Source() string {
+	if r.File == "" {
+		return "session"
+	}
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// Text renders the rule in policy file syntax.
+func (r Rule) Text() string {
+	s := string(r.Action) + " " + r.Tool
+	if r.Pattern != "" {
+		s += " " + r.Pattern
+	}
+	return s
+}
+
+// String renders the rule with its source, for user and model feedback.
+func (r Rule) String() string {
+	return r.Text() + " (" + r.Source() + ")"
+}
+
+// Matches reports whether the rule applies to a tool call.
+func (r Rule) Matches(tool, subject string) bool {
+	if r.Tool != "*" && r.Tool != tool {
+		return false
+	}
+	if r.Pattern == "" {
+		return true
+	}
+	if tool == "run_shell" && hasShellControl(subject) {
+		if r.Action == Allow {
+			return !hasWildcard(r.Pattern) && Glob(r.Pattern, subject)
+		}
+		// A deny or ask rule also applies to each command in a chain, so
+		// "deny run_shell git push*" catches "cd . && git push".
+		if slices.ContainsFunc(shellSegments(subject), func(seg string) bool { return Glob(r.Pattern, seg) }) {
+			return true
+		}
+	}
+	return Glob(r.Pattern, subject)
+}
+
+// shellSegments splits a shell command into the commands it runs: the parts
+// between separators, pipes, background jobs, subshell parentheses and
+// command substitutions. Quoting is ignored, so a quoted ';' also splits;
+// that only makes deny and ask rules match more often.
+func shellSegments(command string) []string {
+	command = strings.ReplaceAll(command, "$(", "(")
+	segments := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(";&|()`\n\r", r)
+	})
+	for i, seg := range segments {
+		segments[i] = strings.TrimSpace(seg)
+	}
+	return slices.DeleteFunc(segments, func(seg string) bool { return seg == "" })
+}
+
+// hasWildcard reports whether pattern has an unescaped '*' or '?'.
+func hasWildcard(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+// Decision is the result of evaluating a policy.
+type Decision struct {
+	Action Action
+	Rule   Rule
+}
+
+// Policy is an ordered rule set. The zero value allows nothing and denies nothing.
+// It is safe for concurrent use once constructed.
+type Policy struct {
+	// Rules holds file-backed rules, followed by session grants.
+	Rules []Rule
+	// CWD is used to express absolute file paths relative to the workspace.
+	CWD string
+	// Project reports whether the project policy file is loaded. It is not
+	// in an untrusted folder, and project grants are refused there.
+	Project bool
+
+	mu    sync.RWMutex
+	files []string // policy files in load order, re-read after a revoke
+}
+
+// Evaluate returns the strongest matching decision: deny beats ask beats allow,
+// regardless of which file a rule came from.
+func (p *Policy) Evaluate(tool string, args map[string]any) Decision {
+	if p == nil {
+		return Decision{}
+	}
+	subject := Subject(tool, args, p.CWD)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var best Decision
+	for _, r := range p.Rules {
+		if !r.Matches(tool, subject) {
+			continue
+		}
+		if rank(r.Action) > rank(best.Action) {
+			best = Decision{Action: r.Action, Rule: r}
+		}
+	}
+	return best
+}
+
+// List returns a snapshot of every rule, in evaluation order.
+func (p *Policy) List() []Rule {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Rule(nil), p.Rules...)
+}
+
+func rank(a Action) int {
+	switch a {
+	case Deny:
+		return 3
+	case Ask:
+		return 2
+	case Allow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Subject extracts the argument a rule pattern is matched against.
+func Subject(tool string, args map[string]any, cwd string) string {
+	str := func(key string) string {
+		v, _ := args[key].(string)
+		return strings.TrimSpace(v)
+	}
+	switch tool {
+	case "run_shell":
+		return str("command")
+	case "python_runtime":
+		return str("code")
+	case "read_file", "write_file", "edit_file":
+		return workspacePath(str("path"), cwd)
+	case "WebFetch":
+		return str("url")
+	case "WebSearch", "code_search":
+		return str("query")
+	case "enter_plan_mode":
+		return str("project_description")
+	default:
+		return ""
+	}
+}
+
+func workspacePath(path, cwd string) string {
+	if path == "" {
+		return ""
+	}
+	if filepath.IsAbs(path) && cwd != "" {
+		if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// Glob matches s against a pattern where '*' matches any run of characters
+// (including '/' and spaces), '?' matches exactly one character and '\'
+// makes the next character literal.
+func Glob(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case pi+1 < len(p) && p[pi] == '\\' && p[pi+1] == str[si]:
+			pi += 2
+			si++
+		case pi < len(p) && p[pi] != '\\' && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case star != -1:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// Escape quotes the glob metacharacters in s so the result matches s literally.
+func Escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace(s)
+}
+
+// Parse reads rules from policy text. source names the file in errors and Rule.File.
+func Parse(source, text string) ([]Rule, error) {
+	var rules []Rule
+	sc := bufio.NewScanner(strings.NewReader(text))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action, rest := cutField(line)
+		tool, pattern := cutField(rest)
+		rule := Rule{
+			Action:  Action(strings.ToLower(action)),
+			Tool:    tool,
+			Pattern: pattern,
+			File:    source,
+			Line:    lineNo,
+		}
+		switch rule.Action {
+		case Allow, Ask, Deny:
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown action %q (want allow, ask, or deny)", source, lineNo, action)
+		}
+		if rule.Tool == "" {
+			return nil, fmt.Errorf("%s:%d: missing tool name", source, lineNo)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, sc.Err()
+}
+
+// cutField splits off the first whitespace-separated field and returns it
+// with the trimmed remainder.
+func cutField(s string) (field, rest string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// UserFile returns the user-level policy path ($XDG_CONFIG_HOME/bono/permissions,
+// defaulting to ~/.config/bono/permissions).
+func UserFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bono", "permissions"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bono", "permissions"), nil
+}
+
+// Load reads the user-level policy file and, when project is set, the
+// project-level one. Missing files are not an error; malformed ones are, and
+// the error names the file and line.
+func Load(cwd string, project bool) (*Policy, error) {
+	policy := &Policy{CWD: cwd, Project: project}
+	if userFile, err := UserFile(); err == nil {
+		policy.files = append(policy.files, userFile)
+	}
+	if project {
+		policy.files = append(policy.files, filepath.Join(cwd, FileName))
+	}
+
+	rules, err := readFiles(policy.files)
+	if err != nil {
+		return nil, err
+	}
+	policy.Rules = rules
+	return policy, nil
+}
+
+func readFiles(paths []string) ([]Rule, error) {
+	var all []Rule
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		rules, err := Parse(path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rules...)
+	}
+	return all, nil
+}
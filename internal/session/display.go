@@ -4,6 +4,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+
+	"github.com/webforspeed/bono/internal/changebatch"
 )
 
 // FormatTool returns the shared one-line tool label used by TUI and headless frontends.
@@ -87,6 +89,76 @@ func BatchReviewPrompt(count int) string {
 	return fmt.Sprintf("Approve %d %s or Undo", count, label)
 }
 
+// UndoConflictPrompt describes files that could not be undone without
+// overwriting edits made after the agent changed them.
+func UndoConflictPrompt(changes []changebatch.FileChange) string {
+	return "Undo conflicts with later edits to " + strings.Join(displayPaths(changes), ", ")
+}
+
+// SubAgentPlanPrompt is the Notification message for subagent plan reviews.
+const SubAgentPlanPrompt = "Review the proposed plan"
+
+// ApprovalPrompt is a one-line description of what an approval request is
+// waiting for, used for Notification hooks.
+func ApprovalPrompt(req ApprovalRequest) string {
+	switch req.Kind {
+	case ApprovalTool:
+		return "Approve " + FormatTool(req.ToolName, req.ToolArgs)
+	case ApprovalSandboxFallback:
+		return "Run outside the sandbox: " + DisplaySandboxCommand(req.Command)
+	case ApprovalChangeBatch:
+		return BatchReviewPrompt(req.ChangeCount)
+	case ApprovalUndoConflict:
+		return UndoConflictPrompt(req.Changes)
+	default:
+		return "Waiting for approval"
+	}
+}
+
+// BatchReviewStatus summarizes a partial review for the batch status line.
+func BatchReviewStatus(decisions []changebatch.FileDecision) string {
+	files, hunks := 0, 0
+	for _, d := range decisions {
+		if d.Hunks == nil {
+			if !d.Keep {
+				files++
+			}
+			continue
+		}
+		for _, keep := range d.Hunks {
+			if !keep {
+				hunks++
+			}
+		}
+	}
+	var parts []string
+	if files > 0 {
+		parts = append(parts, plural(files, "file"))
+	}
+	if hunks > 0 {
+		parts = append(parts, plural(hunks, "hunk"))
+	}
+	if len(parts) == 0 {
+		return "approved"
+	}
+	return "approved, reverted " + strings.Join(parts, " and ")
+}
+
+// RetryNotice describes a retry or a switch to a fallback model.
+func RetryNotice(event RetryEvent) string {
+	if event.Next != "" {
+		return fmt.Sprintf("%s failed: %v; switching to %s for this request", event.Model, event.Err, event.Next)
+	}
+	return fmt.Sprintf("%s failed: %v; retrying in %s (attempt %d)", event.Model, event.Err, event.Delay, event.Attempt+1)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 func DisplaySandboxCommand(command string) string {
 	if code, ok := PythonCodeFromCommand(command); ok {
 		return fmt.Sprintf("Python(%s)", code)
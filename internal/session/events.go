@@ -1,5 +1,7 @@
 package session
 
+import "time"
+
 // Event is a transport-neutral session event emitted by the agent session.
 type Event interface {
 	isSessionEvent()
@@ -35,6 +37,16 @@ type ToolDoneEvent struct {
 
 func (ToolDoneEvent) isSessionEvent() {}
 
+// ToolDeniedEvent reports a tool call rejected by a permission rule or a
+// PreToolUse hook.
+type ToolDeniedEvent struct {
+	Name string
+	Args map[string]any
+	Rule string
+}
+
+func (ToolDeniedEvent) isSessionEvent() {}
+
 type DiffPreviewEvent struct {
 	RelPath    string
 	OldContent string
@@ -61,6 +73,14 @@ type ErrorEvent struct {
 
 func (ErrorEvent) isSessionEvent() {}
 
+// HookErrorEvent reports a hook handler that failed, panicked or timed out.
+type HookErrorEvent struct {
+	Event string
+	Err   error
+}
+
+func (HookErrorEvent) isSessionEvent() {}
+
 type ContextUsageEvent struct {
 	Pct       float64
 	TotalCost float64
@@ -86,6 +106,28 @@ type ResponseModelEvent struct {
 
 func (ResponseModelEvent) isSessionEvent() {}
 
+// RetryEvent reports a failed request that is being retried: on the same
+// model after Delay when Next is empty, otherwise on Next.
+type RetryEvent struct {
+	Model   string
+	Next    string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+func (RetryEvent) isSessionEvent() {}
+
+// RoleEvent reports that the agent moved to Role's model and reasoning
+// effort, on entering a role or on returning to the previous one.
+type RoleEvent struct {
+	Role      Role
+	Model     string
+	Reasoning string
+}
+
+func (RoleEvent) isSessionEvent() {}
+
 type SubAgentStartEvent struct {
 	Name string
 }
@@ -0,0 +1,160 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxFallbackBackoff caps the delay between retries.
+const maxFallbackBackoff = time.Minute
+
+// Fallback says how a request that fails with a rate limit, a server error
+// or an unavailable model is retried. The zero value makes one attempt.
+type Fallback struct {
+	// Models are tried in order, one after another, once the current
+	// model has failed Retries times.
+	Models []string
+	// Retries is how many times each model is retried after its first
+	// failure. An unavailable model is not retried.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles after each.
+	Backoff time.Duration
+	// BaseURL returns the endpoint for a model. When set, it is applied
+	// with each switch and when switching back.
+	BaseURL func(model string) string
+}
+
+// chatAgent is the part of core.Agent that Fallback drives.
+type chatAgent interface {
+	Chat(ctx context.Context, prompt string) (string, error)
+	ModelName() string
+	SetModel(model string)
+	SetBaseURL(url string)
+}
+
+// run sends prompt, retrying with backoff and then moving down the fallback
+// models. Each retry is reported with a RetryEvent, and each switch also
+// with a ResponseModelEvent. The switch lasts for this request only: the
+// agent is put back on its model before run returns.
+//
+// progress counts the tool calls and assistant output seen so far. An
+// attempt that failed after either is not retried, since sending the
+// prompt again would repeat the turn and run its tools twice; the error is
+// returned instead. An attempt that failed before either has still left
+// the prompt in the agent's history, so it is retried only when the agent
+// is a historyRewinder and the prompt can be dropped first.
+func (f Fallback) run(ctx context.Context, agent chatAgent, prompt string, progress func() int64, emit func(Event)) (string, error) {
+	primary := agent.ModelName()
+	chain := []string{primary}
+	for _, m := range f.Models {
+		if m != "" && !slices.Contains(chain, m) {
+			chain = append(chain, m)
+		}
+	}
+
+	defer func() {
+		if agent.ModelName() != primary {
+			f.use(agent, primary)
+		}
+	}()
+
+	rewinder, canRewind := agent.(historyRewinder)
+	var lastErr error
+	for i, model := range chain {
+		if i > 0 {
+			f.use(agent, model)
+			emit(ResponseModelEvent{ModelID: model})
+		}
+		backoff := f.Backoff
+		for attempt := 1; ; attempt++ {
+			before := progress()
+			var turns int
+			if canRewind {
+				turns = rewinder.HistoryLen()
+			}
+			response, err := agent.Chat(ctx, prompt)
+			if err == nil || !canRewind || ctx.Err() != nil || !retryable(err) || progress() != before {
+				return response, err
+			}
+			rewinder.TruncateHistory(turns)
+			lastErr = err
+			if modelUnavailable(err) || attempt > f.Retries {
+				break
+			}
+			emit(RetryEvent{Model: model, Attempt: attempt, Delay: backoff, Err: err})
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			backoff = min(backoff*2, maxFallbackBackoff)
+		}
+		if i+1 < len(chain) {
+			emit(RetryEvent{Model: model, Next: chain[i+1], Err: lastErr})
+		}
+	}
+	return "", lastErr
+}
+
+func (f Fallback) use(agent chatAgent, model string) {
+	agent.SetModel(model)
+	if f.BaseURL != nil {
+		agent.SetBaseURL(f.BaseURL(model))
+	}
+}
+
+// historyRewinder is implemented by agents that can drop the turns a
+// failed request left in their history.
+type historyRewinder interface {
+	HistoryLen() int
+	TruncateHistory(n int)
+}
+
+// statusCoder is implemented by API errors that carry the HTTP status of
+// the failed response.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// retryable reports whether err looks transient or model-specific: a
+// network failure, a timeout, a rate limit, a server error or an
+// unavailable model. The status comes from the error when it has one;
+// otherwise only unambiguous phrases in the message count.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || modelUnavailable(err) {
+		return true
+	}
+	var status statusCoder
+	if errors.As(err, &status) {
+		code := status.StatusCode()
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "rate limit") ||
+		strings.Contains(msg, "too many requests") ||
+		strings.Contains(msg, "overloaded") ||
+		strings.Contains(msg, "timeout") ||
+		strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "connection refused")
+}
+
+// modelUnavailable reports whether err says the model itself cannot be
+// used, so retrying it is pointless but another model may work.
+func modelUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"no endpoints found", "model not found", "model is not available", "model unavailable", "is not a valid model"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeChatAgent fails with the queued errors for each model, then answers
+// with the model's name. Like core.Agent, it keeps the prompt in its
+// history even when the request fails. With midTurn set, each call makes
+// progress (a tool call, say) before it fails.
+type fakeChatAgent struct {
+	model    string
+	baseURL  string
+	errs     map[string][]error
+	calls    []string
+	history  []string
+	midTurn  bool
+	progress int64
+}
+
+func (a *fakeChatAgent) Chat(ctx context.Context, prompt string) (string, error) {
+	a.calls = append(a.calls, a.model+"@"+a.baseURL)
+	a.history = append(a.history, prompt)
+	if a.midTurn {
+		a.progress++
+	}
+	if errs := a.errs[a.model]; len(errs) > 0 {
+		a.errs[a.model] = errs[1:]
+		return "", errs[0]
+	}
+	return "answer from " + a.model, nil
+}
+
+func (a *fakeChatAgent) ModelName() string     { return a.model }
+func (a *fakeChatAgent) SetModel(model string) { a.model = model }
+func (a *fakeChatAgent) SetBaseURL(url string) { a.baseURL = url }
+
+func (a *fakeChatAgent) HistoryLen() int       { return len(a.history) }
+func (a *fakeChatAgent) TruncateHistory(n int) { a.history = a.history[:n] }
+
+func (a *fakeChatAgent) steps() int64 { return a.progress }
+
+// fixedHistoryAgent is a fakeChatAgent that cannot rewind its history.
+type fixedHistoryAgent struct{ fake *fakeChatAgent }
+
+func (a fixedHistoryAgent) Chat(ctx context.Context, prompt string) (string, error) {
+	return a.fake.Chat(ctx, prompt)
+}
+func (a fixedHistoryAgent) ModelName() string     { return a.fake.ModelName() }
+func (a fixedHistoryAgent) SetModel(model string) { a.fake.SetModel(model) }
+func (a fixedHistoryAgent) SetBaseURL(url string) { a.fake.SetBaseURL(url) }
+
+// statusError is an API error carrying its HTTP status.
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("API error (status %d)", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestFallbackRetriesThenSwitchesModel(t *testing.T) {
+	rateLimited := fmt.Errorf("chat: %w", statusError(429))
+	agent := &fakeChatAgent{
+		model:   "anthropic/claude-sonnet-4",
+		baseURL: "https://openrouter.ai/api/v1",
+		errs: map[string][]error{
+			"anthropic/claude-sonnet-4": {rateLimited, rateLimited, rateLimited},
+			"openai/gpt-5":              {errors.New("No endpoints found for openai/gpt-5")},
+		},
+	}
+	f := Fallback{
+		Models:  []string{"openai/gpt-5", "qwen3:8b"},
+		Retries: 2,
+		Backoff: time.Millisecond,
+		BaseURL: func(model string) string {
+			if model == "qwen3:8b" {
+				return "http://127.0.0.1:11434/v1"
+			}
+			return "https://openrouter.ai/api/v1"
+		},
+	}
+	var events []Event
+	response, err := f.run(context.Background(), agent, "hi", agent.steps, func(e Event) { events = append(events, e) })
+	if err != nil || response != "answer from qwen3:8b" {
+		t.Fatalf("run = %q, %v", response, err)
+	}
+
+	wantCalls := []string{
+		"anthropic/claude-sonnet-4@https://openrouter.ai/api/v1",
+		"anthropic/claude-sonnet-4@https://openrouter.ai/api/v1",
+		"anthropic/claude-sonnet-4@https://openrouter.ai/api/v1",
+		"openai/gpt-5@https://openrouter.ai/api/v1", // unavailable: not retried
+		"qwen3:8b@http://127.0.0.1:11434/v1",
+	}
+	if strings.Join(agent.calls, "\n") != strings.Join(wantCalls, "\n") {
+		t.Errorf("calls = %q\nwant    %q", agent.calls, wantCalls)
+	}
+
+	var notices, models []string
+	for _, e := range events {
+		switch e := e.(type) {
+		case RetryEvent:
+			notices = append(notices, RetryNotice(e))
+		case ResponseModelEvent:
+			models = append(models, e.ModelID)
+		}
+	}
+	if len(notices) != 4 ||
+		!strings.HasSuffix(notices[0], "retrying in 1ms (attempt 2)") ||
+		!strings.HasSuffix(notices[1], "retrying in 2ms (attempt 3)") ||
+		!strings.HasSuffix(notices[2], "switching to openai/gpt-5 for this request") ||
+		!strings.HasSuffix(notices[3], "switching to qwen3:8b for this request") {
+		t.Errorf("notices = %q", notices)
+	}
+	if strings.Join(models, ",") != "openai/gpt-5,qwen3:8b" {
+		t.Errorf("response models = %v", models)
+	}
+
+	if agent.model != "anthropic/claude-sonnet-4" || agent.baseURL != "https://openrouter.ai/api/v1" {
+		t.Errorf("agent left on %s at %s, want the primary model restored", agent.model, agent.baseURL)
+	}
+	if len(agent.history) != 1 {
+		t.Errorf("history = %q, want the prompt once", agent.history)
+	}
+}
+
+func TestFallbackDoesNotRetryWithoutRewindingHistory(t *testing.T) {
+	fake := &fakeChatAgent{model: "a", errs: map[string][]error{"a": {statusError(429)}}}
+	f := Fallback{Models: []string{"b"}, Retries: 2, Backoff: time.Millisecond}
+	var events []Event
+	_, err := f.run(context.Background(), fixedHistoryAgent{fake}, "hi", fake.steps, func(e Event) { events = append(events, e) })
+	if err == nil || err.Error() != "API error (status 429)" {
+		t.Fatalf("err = %v, want the first failure", err)
+	}
+	if len(fake.calls) != 1 || len(events) != 0 {
+		t.Errorf("calls = %v, events = %v; a prompt left in history must not be sent again", fake.calls, events)
+	}
+}
+
+func TestFallbackStopsOnPermanentErrors(t *testing.T) {
+	denied := statusError(401)
+	agent := &fakeChatAgent{model: "a", errs: map[string][]error{"a": {denied}}}
+	f := Fallback{Models: []string{"b"}, Retries: 3, Backoff: time.Millisecond}
+	var events []Event
+	if _, err := f.run(context.Background(), agent, "hi", agent.steps, func(e Event) { events = append(events, e) }); err != denied {
+		t.Fatalf("err = %v, want %v", err, denied)
+	}
+	if len(agent.calls) != 1 || len(events) != 0 {
+		t.Errorf("calls = %v, events = %v; want a single attempt", agent.calls, events)
+	}
+}
+
+func TestFallbackReturnsLastErrorWhenChainIsExhausted(t *testing.T) {
+	agent := &fakeChatAgent{model: "a", errs: map[string][]error{
+		"a": {statusError(502)},
+		"b": {errors.New("provider overloaded")},
+	}}
+	f := Fallback{Models: []string{"b", "a"}}
+	_, err := f.run(context.Background(), agent, "hi", agent.steps, func(Event) {})
+	if err == nil || err.Error() != "provider overloaded" {
+		t.Errorf("err = %v, want the last model's error", err)
+	}
+	if len(agent.calls) != 2 || agent.model != "a" {
+		t.Errorf("calls = %v, model = %s", agent.calls, agent.model)
+	}
+}
+
+func TestFallbackDoesNotRepeatAStartedTurn(t *testing.T) {
+	agent := &fakeChatAgent{model: "a", midTurn: true, errs: map[string][]error{"a": {statusError(503)}}}
+	f := Fallback{Models: []string{"b"}, Retries: 2, Backoff: time.Millisecond}
+	var events []Event
+	_, err := f.run(context.Background(), agent, "hi", agent.steps, func(e Event) { events = append(events, e) })
+	if err == nil || err.Error() != "API error (status 503)" {
+		t.Fatalf("err = %v, want the mid-turn failure", err)
+	}
+	if len(agent.calls) != 1 || len(events) != 0 {
+		t.Errorf("calls = %v, events = %v; a turn that ran tools must not be sent again", agent.calls, events)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := map[string]bool{
+		"Rate limit exceeded":                   true,
+		"dial tcp: connection refused":          true,
+		"No endpoints found for x/y":            true,
+		"the model x/y is not a valid model ID": true,
+		"upstream returned 503":                 false, // no status to go on
+		"request id 5029 failed validation":     false,
+		"tool call arguments were not valid":    false,
+	}
+	for msg, want := range cases {
+		if got := retryable(errors.New(msg)); got != want {
+			t.Errorf("retryable(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	for code, want := range map[int]bool{408: true, 429: true, 500: true, 503: true, 400: false, 401: false, 402: false, 404: false} {
+		if got := retryable(fmt.Errorf("chat: %w", statusError(code))); got != want {
+			t.Errorf("retryable(status %d) = %v, want %v", code, got, want)
+		}
+	}
+	if retryable(context.Canceled) {
+		t.Error("a cancelled request should not be retried")
+	}
+}
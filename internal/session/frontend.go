@@ -2,8 +2,11 @@ package session
 
 import (
 	"context"
+	"strings"
 
 	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/permissions"
 )
 
 type ApprovalKind string
@@ -12,6 +15,11 @@ const (
 	ApprovalTool            ApprovalKind = "tool"
 	ApprovalSandboxFallback ApprovalKind = "sandbox_fallback"
 	ApprovalChangeBatch     ApprovalKind = "change_batch"
+	ApprovalSubAgentPlan    ApprovalKind = "subagent_plan"
+	// ApprovalUndoConflict asks whether to overwrite files, listed in
+	// Changes, that were edited after the agent changed them and cannot be
+	// reverted by merging. Rejecting leaves them as they are.
+	ApprovalUndoConflict ApprovalKind = "undo_conflict"
 )
 
 // ApprovalRequest describes a user decision the frontend must resolve.
@@ -23,6 +31,63 @@ type ApprovalRequest struct {
 	Command         string
 	Reason          string
 	ChangeCount     int
+
+	// Changes are the files under review for ApprovalChangeBatch. A frontend
+	// that lets the user keep some files or hunks and revert others reports
+	// that through Decide before returning true; returning true without
+	// calling Decide keeps everything, returning false reverts everything.
+	Changes []changebatch.FileChange
+	Decide  func([]changebatch.FileDecision)
+
+	// Grants lists standing approvals the user may pick instead of approving
+	// once. Remember records the pick; frontends call it before approving.
+	// Both are set by PolicyMiddleware for tool approvals.
+	Grants   []permissions.Grant
+	Remember func(permissions.Grant)
+}
+
+// GrantKey is the answer that picks a grant scope in approval prompts.
+func GrantKey(scope permissions.GrantScope) string {
+	switch scope {
+	case permissions.GrantExact:
+		return "s"
+	case permissions.GrantPrefix:
+		return "p"
+	case permissions.GrantProject:
+		return "a"
+	default:
+		return ""
+	}
+}
+
+// Choose approves req, remembering the grant for scope if one was offered.
+// An empty or unoffered scope approves once.
+func (req ApprovalRequest) Choose(scope permissions.GrantScope) {
+	if scope == "" || req.Remember == nil {
+		return
+	}
+	for _, g := range req.Grants {
+		if g.Scope == scope {
+			req.Remember(g)
+			return
+		}
+	}
+}
+
+// parseApprovalAnswer reads a typed approval answer: y/yes/true approve once,
+// a grant key or scope name approves and remembers. Anything else rejects.
+func parseApprovalAnswer(answer string) (bool, permissions.GrantScope) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch answer {
+	case "y", "yes", "true":
+		return true, ""
+	}
+	for _, scope := range []permissions.GrantScope{permissions.GrantExact, permissions.GrantPrefix, permissions.GrantProject} {
+		if answer == GrantKey(scope) || answer == string(scope) {
+			return true, scope
+		}
+	}
+	return false, ""
 }
 
 // SessionFrontend is the narrow interface implemented by each transport.
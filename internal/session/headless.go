@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/diff"
+	"github.com/webforspeed/bono/internal/permissions"
 )
 
 // HeadlessFrontend renders Bono session events as an append-only terminal transcript.
@@ -65,6 +68,9 @@ func (f *HeadlessFrontend) HandleEvent(_ context.Context, event Event) {
 		line += " => " + event.Status
 		fmt.Fprintln(f.out, line)
 		fmt.Fprintln(f.out)
+	case ToolDeniedEvent:
+		f.finishStreaming()
+		fmt.Fprintf(f.out, "● %s => denied by policy: %s\n\n", FormatTool(event.Name, event.Args), event.Rule)
 	case DiffPreviewEvent:
 		f.finishStreaming()
 		fmt.Fprintln(f.out, RenderDiffPreview(event))
@@ -85,8 +91,15 @@ func (f *HeadlessFrontend) HandleEvent(_ context.Context, event Event) {
 		if event.Err != nil {
 			fmt.Fprintf(f.out, "Error: %v\n", event.Err)
 		}
+	case HookErrorEvent:
+		f.finishStreaming()
+		fmt.Fprintf(f.out, "Warning: %v\n", event.Err)
+	case RetryEvent:
+		f.finishStreaming()
+		fmt.Fprintf(f.out, "  ↳ %s\n", RetryNotice(event))
 	case ContextUsageEvent:
 	case ResponseModelEvent:
+	case RoleEvent:
 	case RefreshGitStatusEvent:
 	default:
 		f.finishStreaming()
@@ -103,11 +116,19 @@ func (f *HeadlessFrontend) RequestApproval(ctx context.Context, req ApprovalRequ
 			line += " [Outside sandbox: " + req.ExecutionReason + "]"
 		}
 		fmt.Fprintln(f.out, line)
-		ok := f.readApproval(ctx, "  ↳ Approve? [y/N]: ")
+		keys := "y/N"
+		for _, g := range req.Grants {
+			key := GrantKey(g.Scope)
+			fmt.Fprintf(f.out, "  ↳ %s = %s\n", key, g.Describe())
+			keys += "/" + key
+		}
+		ok, scope := f.readAnswer(ctx, "  ↳ Approve? ["+keys+"]: ")
 		if !ok {
 			fmt.Fprintf(f.out, "● %s => cancelled\n\n", FormatTool(req.ToolName, req.ToolArgs))
+			return false
 		}
-		return ok
+		req.Choose(scope)
+		return true
 	case ApprovalSandboxFallback:
 		fmt.Fprintf(f.out, "  ↳ %s [Sandbox blocked: %s]\n", DisplaySandboxCommand(req.Command), fallbackReason(req.Reason))
 		ok := f.readApproval(ctx, "  ↳ Run outside sandbox? [y/N]: ")
@@ -118,10 +139,39 @@ func (f *HeadlessFrontend) RequestApproval(ctx context.Context, req ApprovalRequ
 	case ApprovalChangeBatch:
 		prompt := BatchReviewPrompt(req.ChangeCount)
 		fmt.Fprintln(f.out, "● "+prompt)
-		ok := f.readApproval(ctx, "  ↳ Approve? [y/N]: ")
-		status := "approved"
-		if !ok {
-			status = "undone"
+		if req.Decide == nil || len(req.Changes) == 0 {
+			ok := f.readApproval(ctx, "  ↳ Approve? [y/N]: ")
+			status := "approved"
+			if !ok {
+				status = "undone"
+			}
+			fmt.Fprintf(f.out, "● %s => %s\n\n", prompt, status)
+			return ok
+		}
+
+		answer, ok := f.ask(ctx, "  ↳ Approve? [y/N/r] (r = review file by file): ")
+		status := "undone"
+		switch {
+		case ok && (answer == "y" || answer == "yes"):
+			status = "approved"
+		case ok && answer == "r":
+			var decisions []changebatch.FileDecision
+			if decisions, ok = f.reviewChanges(ctx, req.Changes); ok {
+				req.Decide(decisions)
+				status = BatchReviewStatus(decisions)
+			}
+		default:
+			ok = false
+		}
+		fmt.Fprintf(f.out, "● %s => %s\n\n", prompt, status)
+		return ok
+	case ApprovalUndoConflict:
+		prompt := UndoConflictPrompt(req.Changes)
+		fmt.Fprintln(f.out, "● "+prompt)
+		ok := f.readApproval(ctx, "  ↳ Overwrite them with the original content? [y/N]: ")
+		status := "kept"
+		if ok {
+			status = "overwritten"
 		}
 		fmt.Fprintf(f.out, "● %s => %s\n\n", prompt, status)
 		return ok
@@ -170,12 +220,79 @@ func (f *HeadlessFrontend) finishStreaming() {
 }
 
 func (f *HeadlessFrontend) readApproval(ctx context.Context, prompt string) bool {
+	answer, ok := f.ask(ctx, prompt)
+	return ok && (answer == "y" || answer == "yes")
+}
+
+// readAnswer is readApproval for prompts that also accept grant keys.
+func (f *HeadlessFrontend) readAnswer(ctx context.Context, prompt string) (bool, permissions.GrantScope) {
+	answer, ok := f.ask(ctx, prompt)
+	if !ok {
+		return false, ""
+	}
+	return parseApprovalAnswer(answer)
+}
+
+// ask prints prompt and returns the trimmed, lower-cased answer. ok is false
+// when input ends or ctx is cancelled.
+func (f *HeadlessFrontend) ask(ctx context.Context, prompt string) (answer string, ok bool) {
 	fmt.Fprint(f.out, prompt)
+	line, err := readLine(ctx, f.in)
+	if err != nil {
+		fmt.Fprintln(f.out)
+		return "", false
+	}
+	return strings.TrimSpace(strings.ToLower(line)), true
+}
+
+// reviewChanges asks which files, or which hunks of a file, to keep. Enter
+// keeps; input ending mid-review rejects the whole batch.
+func (f *HeadlessFrontend) reviewChanges(ctx context.Context, changes []changebatch.FileChange) ([]changebatch.FileDecision, bool) {
+	decisions := make([]changebatch.FileDecision, 0, len(changes))
+	for _, change := range changes {
+		hunks := diff.Hunks(change.BeforeContent, change.AfterContent)
+		keys := "Y/n"
+		if len(hunks) > 1 {
+			keys += "/h"
+		}
+		answer, ok := f.ask(ctx, fmt.Sprintf("  ↳ Keep %s? [%s]: ", change.DisplayPath, keys))
+		if !ok {
+			return nil, false
+		}
+		decision := changebatch.FileDecision{Path: change.DisplayPath, Keep: true}
+		switch {
+		case answer == "n" || answer == "no":
+			decision.Keep = false
+		case answer == "h" && len(hunks) > 1:
+			decision.Hunks = make([]bool, len(hunks))
+			for i, h := range hunks {
+				fmt.Fprintf(f.out, "    %s\n", h.Header())
+				for _, op := range h.Ops {
+					sign := "+ "
+					if op.Kind == diff.Delete {
+						sign = "- "
+					}
+					fmt.Fprintf(f.out, "    %s%s\n", sign, strings.TrimRight(op.Text, "\r\n"))
+				}
+				answer, ok := f.ask(ctx, fmt.Sprintf("  ↳ Keep hunk %d/%d? [Y/n]: ", i+1, len(hunks)))
+				if !ok {
+					return nil, false
+				}
+				decision.Hunks[i] = answer != "n" && answer != "no"
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, true
+}
+
+// readLine reads one answer line from in, giving up when ctx is cancelled.
+func readLine(ctx context.Context, in *bufio.Reader) (string, error) {
 	answerCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
 	go func() {
-		line, err := f.in.ReadString('\n')
+		line, err := in.ReadString('\n')
 		if err != nil {
 			errCh <- err
 			return
@@ -185,18 +302,11 @@ func (f *HeadlessFrontend) readApproval(ctx context.Context, prompt string) bool
 
 	select {
 	case <-ctx.Done():
-		fmt.Fprintln(f.out)
-		return false
+		return "", ctx.Err()
 	case err := <-errCh:
-		if err == io.EOF {
-			fmt.Fprintln(f.out)
-			return false
-		}
-		fmt.Fprintln(f.out)
-		return false
+		return "", err
 	case line := <-answerCh:
-		answer := strings.TrimSpace(strings.ToLower(line))
-		return answer == "y" || answer == "yes"
+		return line, nil
 	}
 }
 
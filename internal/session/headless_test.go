@@ -78,3 +78,17 @@ func TestHeadlessFrontendFinalizesStreamingBeforeToolLines(t *testing.T) {
 		t.Fatalf("expected content before tool call before tool done, got %q", output)
 	}
 }
+
+func TestRenderDiffPreviewShowsHunks(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	got := RenderDiffPreview(DiffPreviewEvent{RelPath: "x.txt", OldContent: old, NewContent: "new\n" + old})
+	want := "📄 x.txt (before) → x.txt (after)\n" +
+		"@@ -1,3 +1,4 @@\n" +
+		"+ new\n" +
+		"  a\n" +
+		"  b\n" +
+		"  c"
+	if got != want {
+		t.Fatalf("RenderDiffPreview =\n%s\nwant\n%s", got, want)
+	}
+}
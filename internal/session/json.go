@@ -0,0 +1,354 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/diff"
+	"github.com/webforspeed/bono/internal/permissions"
+)
+
+// JSON event type tags. These are part of the public --output-format contract;
+// add new tags rather than renaming existing ones.
+const (
+	JSONUserPrompt       = "user_prompt"
+	JSONMessage          = "message"
+	JSONContentDelta     = "content_delta"
+	JSONReasoningDelta   = "reasoning_delta"
+	JSONToolCall         = "tool_call"
+	JSONToolDone         = "tool_done"
+	JSONToolDenied       = "tool_denied"
+	JSONDiffPreview      = "diff_preview"
+	JSONPreTaskStart     = "pretask_start"
+	JSONPreTaskEnd       = "pretask_end"
+	JSONSubAgentStart    = "subagent_start"
+	JSONSubAgentEnd      = "subagent_end"
+	JSONError            = "error"
+	JSONHookError        = "hook_error"
+	JSONContextUsage     = "context_usage"
+	JSONResponseModel    = "response_model"
+	JSONRetry            = "retry"
+	JSONRole             = "role"
+	JSONApprovalRequest  = "approval_request"
+	JSONApprovalDecision = "approval_decision"
+	JSONResult           = "result"
+)
+
+// JSONEvent is the wire form of a session event. Every object carries a
+// type tag; the remaining fields are populated according to that tag.
+type JSONEvent struct {
+	Type string `json:"type"`
+
+	Prompt  string `json:"prompt,omitempty"`
+	Content string `json:"content,omitempty"`
+	Delta   string `json:"delta,omitempty"`
+
+	Name            string         `json:"name,omitempty"`
+	Label           string         `json:"label,omitempty"`
+	Args            map[string]any `json:"args,omitempty"`
+	Status          string         `json:"status,omitempty"`
+	Sandboxed       bool           `json:"sandboxed,omitempty"`
+	ExecutionReason string         `json:"execution_reason,omitempty"`
+
+	Path       string `json:"path,omitempty"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+
+	ContextPct *float64 `json:"context_pct,omitempty"`
+	TotalCost  *float64 `json:"total_cost,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	NextModel  string   `json:"next_model,omitempty"`
+	Attempt    int      `json:"attempt,omitempty"`
+	DelayMS    int64    `json:"delay_ms,omitempty"`
+	Reasoning  string   `json:"reasoning,omitempty"`
+
+	Kind        ApprovalKind `json:"kind,omitempty"`
+	Command     string       `json:"command,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	ChangeCount int          `json:"change_count,omitempty"`
+	OutputPath  string       `json:"output_path,omitempty"`
+	Grants      []JSONGrant  `json:"grants,omitempty"`
+	Files       []JSONFile   `json:"files,omitempty"`
+	Approved    *bool        `json:"approved,omitempty"`
+	Grant       string       `json:"grant,omitempty"`
+
+	Decisions []changebatch.FileDecision `json:"decisions,omitempty"`
+
+	SessionID string      `json:"session_id,omitempty"`
+	Response  string      `json:"response,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	IsError   bool        `json:"is_error,omitempty"`
+	Events    []JSONEvent `json:"events,omitempty"`
+}
+
+// JSONGrant is a standing approval offered in an approval_request. Answer
+// with {"approved": true, "grant": "<scope>"} to pick it.
+type JSONGrant struct {
+	Scope       string `json:"scope"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+}
+
+// JSONFile is one file of a change batch under review, with the headers of
+// its hunks in order. Keep or revert individual files or hunks by answering
+// {"approved": true, "decisions": [{"path": "a.go", "keep": false},
+// {"path": "b.go", "hunks": [true, false]}]}.
+type JSONFile struct {
+	Path  string   `json:"path"`
+	Hunks []string `json:"hunks"`
+}
+
+// EncodeEvent converts a session event to its JSON wire form.
+// It returns false for events that have no wire representation.
+func EncodeEvent(event Event) (JSONEvent, bool) {
+	switch event := event.(type) {
+	case UserPromptEvent:
+		return JSONEvent{Type: JSONUserPrompt, Prompt: event.Prompt}, true
+	case MessageEvent:
+		return JSONEvent{Type: JSONMessage, Content: event.Content}, true
+	case ContentDeltaEvent:
+		return JSONEvent{Type: JSONContentDelta, Delta: event.Delta}, true
+	case ReasoningDeltaEvent:
+		return JSONEvent{Type: JSONReasoningDelta, Delta: event.Delta}, true
+	case ToolCallEvent:
+		return JSONEvent{
+			Type:            JSONToolCall,
+			Name:            event.Name,
+			Label:           FormatTool(event.Name, event.Args),
+			Args:            event.Args,
+			Sandboxed:       event.Sandboxed,
+			ExecutionReason: event.ExecutionReason,
+		}, true
+	case ToolDoneEvent:
+		return JSONEvent{
+			Type:      JSONToolDone,
+			Name:      event.Name,
+			Label:     FormatTool(event.Name, event.Args),
+			Args:      event.Args,
+			Status:    event.Status,
+			Sandboxed: event.Sandboxed,
+		}, true
+	case ToolDeniedEvent:
+		return JSONEvent{
+			Type:   JSONToolDenied,
+			Name:   event.Name,
+			Label:  FormatTool(event.Name, event.Args),
+			Args:   event.Args,
+			Reason: event.Rule,
+		}, true
+	case DiffPreviewEvent:
+		return JSONEvent{
+			Type:       JSONDiffPreview,
+			Path:       event.RelPath,
+			OldContent: event.OldContent,
+			NewContent: event.NewContent,
+		}, true
+	case PreTaskStartEvent:
+		return JSONEvent{Type: JSONPreTaskStart, Name: event.Name}, true
+	case PreTaskEndEvent:
+		return JSONEvent{Type: JSONPreTaskEnd, Name: event.Name}, true
+	case SubAgentStartEvent:
+		return JSONEvent{Type: JSONSubAgentStart, Name: event.Name}, true
+	case SubAgentEndEvent:
+		return JSONEvent{Type: JSONSubAgentEnd, Name: event.Name}, true
+	case ErrorEvent:
+		if event.Err == nil {
+			return JSONEvent{}, false
+		}
+		return JSONEvent{Type: JSONError, Error: event.Err.Error()}, true
+	case HookErrorEvent:
+		return JSONEvent{Type: JSONHookError, Name: event.Event, Error: event.Err.Error()}, true
+	case ContextUsageEvent:
+		pct, cost := event.Pct, event.TotalCost
+		return JSONEvent{Type: JSONContextUsage, ContextPct: &pct, TotalCost: &cost}, true
+	case ResponseModelEvent:
+		return JSONEvent{Type: JSONResponseModel, Model: event.ModelID}, true
+	case RoleEvent:
+		return JSONEvent{Type: JSONRole, Name: string(event.Role), Model: event.Model, Reasoning: event.Reasoning}, true
+	case RetryEvent:
+		return JSONEvent{
+			Type:      JSONRetry,
+			Model:     event.Model,
+			NextModel: event.Next,
+			Attempt:   event.Attempt,
+			DelayMS:   event.Delay.Milliseconds(),
+			Error:     event.Err.Error(),
+		}, true
+	default:
+		return JSONEvent{}, false
+	}
+}
+
+// EncodeApprovalRequest converts an approval request to its JSON wire form.
+func EncodeApprovalRequest(req ApprovalRequest) JSONEvent {
+	ev := JSONEvent{
+		Type:            JSONApprovalRequest,
+		Kind:            req.Kind,
+		Name:            req.ToolName,
+		Args:            req.ToolArgs,
+		ExecutionReason: req.ExecutionReason,
+		Command:         req.Command,
+		Reason:          req.Reason,
+		ChangeCount:     req.ChangeCount,
+	}
+	if req.ToolName != "" {
+		ev.Label = FormatTool(req.ToolName, req.ToolArgs)
+	}
+	for _, g := range req.Grants {
+		ev.Grants = append(ev.Grants, JSONGrant{Scope: string(g.Scope), Rule: g.Rule.Text(), Description: g.Describe()})
+	}
+	for _, c := range req.Changes {
+		file := JSONFile{Path: c.DisplayPath, Hunks: []string{}}
+		for _, h := range diff.Hunks(c.BeforeContent, c.AfterContent) {
+			file.Hunks = append(file.Hunks, h.Header())
+		}
+		ev.Files = append(ev.Files, file)
+	}
+	return ev
+}
+
+// JSONFrontend serializes session events as JSON for scripted consumers.
+// In streaming mode every event is written as one line as soon as it happens.
+// Otherwise events are buffered and written as a single result object by
+// Finish, except approval requests, which are also written at once so the
+// caller can answer them.
+type JSONFrontend struct {
+	in        *bufio.Reader
+	streaming bool
+
+	mu        sync.Mutex
+	enc       *json.Encoder
+	events    []JSONEvent
+	sessionID string
+}
+
+func NewJSONFrontend(out io.Writer, in io.Reader, streaming bool) *JSONFrontend {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return &JSONFrontend{
+		in:        bufio.NewReader(in),
+		streaming: streaming,
+		enc:       enc,
+	}
+}
+
+func (f *JSONFrontend) HandleEvent(_ context.Context, event Event) {
+	if ev, ok := EncodeEvent(event); ok {
+		f.emit(ev)
+	}
+}
+
+// RequestApproval emits an approval_request, reads the answer from the input
+// stream and emits the matching approval_decision. Answers may be "y"/"yes",
+// "true", a grant key, or a JSON object like {"approved": true, "grant":
+// "prefix"}. EOF rejects.
+func (f *JSONFrontend) RequestApproval(ctx context.Context, req ApprovalRequest) bool {
+	f.emitNow(EncodeApprovalRequest(req))
+	answer := f.readDecision(ctx)
+	ok := answer.approved
+	if ok {
+		req.Choose(answer.scope)
+		if answer.decisions != nil && req.Decide != nil {
+			req.Decide(answer.decisions)
+		}
+	}
+	f.emit(JSONEvent{
+		Type:      JSONApprovalDecision,
+		Kind:      req.Kind,
+		Name:      req.ToolName,
+		Approved:  &ok,
+		Grant:     string(answer.scope),
+		Decisions: answer.decisions,
+	})
+	return ok
+}
+
+func (f *JSONFrontend) RequestSubAgentApproval(_ context.Context, result core.SubAgentResult) core.SubAgentApprovalResponse {
+	// Like the text transcript, JSON output auto-approves plans but still records the decision.
+	approved := true
+	path := result.Meta["output_path"]
+	f.emit(JSONEvent{Type: JSONApprovalRequest, Kind: ApprovalSubAgentPlan, OutputPath: path})
+	f.emit(JSONEvent{Type: JSONApprovalDecision, Kind: ApprovalSubAgentPlan, OutputPath: path, Approved: &approved})
+	return core.SubAgentApprovalResponse{Action: core.SubAgentApprove}
+}
+
+// SetSessionID sets the transcript ID reported in the result object.
+func (f *JSONFrontend) SetSessionID(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessionID = id
+}
+
+// Finish writes the final result object. In non-streaming mode the result
+// carries every buffered event.
+func (f *JSONFrontend) Finish(response string, err error) {
+	result := JSONEvent{Type: JSONResult, Response: response}
+	if err != nil {
+		result.IsError = true
+		result.Error = err.Error()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result.SessionID = f.sessionID
+	if !f.streaming {
+		result.Events = f.events
+		f.events = nil
+	}
+	_ = f.enc.Encode(result)
+}
+
+func (f *JSONFrontend) emit(ev JSONEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.streaming {
+		f.events = append(f.events, ev)
+		return
+	}
+	_ = f.enc.Encode(ev)
+}
+
+// emitNow writes ev immediately, in either mode, and keeps it among the
+// buffered events in non-streaming mode.
+func (f *JSONFrontend) emitNow(ev JSONEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.streaming {
+		f.events = append(f.events, ev)
+	}
+	_ = f.enc.Encode(ev)
+}
+
+type jsonAnswer struct {
+	approved  bool
+	scope     permissions.GrantScope
+	decisions []changebatch.FileDecision
+}
+
+func (f *JSONFrontend) readDecision(ctx context.Context) jsonAnswer {
+	line, err := readLine(ctx, f.in)
+	if err != nil {
+		return jsonAnswer{}
+	}
+	answer := strings.TrimSpace(line)
+	if strings.HasPrefix(answer, "{") {
+		var decision struct {
+			Approved  bool                       `json:"approved"`
+			Grant     string                     `json:"grant"`
+			Decisions []changebatch.FileDecision `json:"decisions"`
+		}
+		if err := json.Unmarshal([]byte(answer), &decision); err != nil || !decision.Approved {
+			return jsonAnswer{}
+		}
+		_, scope := parseApprovalAnswer(decision.Grant)
+		return jsonAnswer{approved: true, scope: scope, decisions: decision.Decisions}
+	}
+	ok, scope := parseApprovalAnswer(answer)
+	return jsonAnswer{approved: ok, scope: scope}
+}
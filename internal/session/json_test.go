@@ -0,0 +1,161 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func decodeJSONLines(t *testing.T, data string) []JSONEvent {
+	t.Helper()
+	var events []JSONEvent
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		var ev JSONEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("line %q is not valid JSON: %v", line, err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestJSONFrontendStreamsOneObjectPerEvent(t *testing.T) {
+	var out bytes.Buffer
+	frontend := NewJSONFrontend(&out, strings.NewReader(""), true)
+	ctx := context.Background()
+
+	frontend.HandleEvent(ctx, ToolCallEvent{Name: "read_file", Args: map[string]any{"path": "auth.py"}})
+	frontend.HandleEvent(ctx, ToolDoneEvent{Name: "read_file", Args: map[string]any{"path": "auth.py"}, Status: "success"})
+	frontend.HandleEvent(ctx, ContextUsageEvent{Pct: 0, TotalCost: 0.25})
+	frontend.HandleEvent(ctx, RefreshGitStatusEvent{})
+	frontend.Finish("done", nil)
+
+	events := decodeJSONLines(t, out.String())
+	wantTypes := []string{JSONToolCall, JSONToolDone, JSONContextUsage, JSONResult}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("len(events) = %d, want %d (%s)", len(events), len(wantTypes), out.String())
+	}
+	for i, want := range wantTypes {
+		if events[i].Type != want {
+			t.Fatalf("events[%d].Type = %q, want %q", i, events[i].Type, want)
+		}
+	}
+	if events[0].Label != "Read('auth.py')" {
+		t.Fatalf("Label = %q, want %q", events[0].Label, "Read('auth.py')")
+	}
+	if events[2].ContextPct == nil || *events[2].ContextPct != 0 {
+		t.Fatalf("ContextPct = %v, want explicit 0", events[2].ContextPct)
+	}
+	if events[3].Response != "done" || events[3].IsError {
+		t.Fatalf("result = %+v, want successful response %q", events[3], "done")
+	}
+}
+
+func TestJSONFrontendEmitsApprovalRequestAndDecision(t *testing.T) {
+	var out bytes.Buffer
+	frontend := NewJSONFrontend(&out, strings.NewReader("{\"approved\": true}\n"), true)
+
+	ok := frontend.RequestApproval(context.Background(), ApprovalRequest{
+		Kind:     ApprovalTool,
+		ToolName: "run_shell",
+		ToolArgs: map[string]any{"command": "go test ./..."},
+	})
+	if !ok {
+		t.Fatalf("RequestApproval returned false, want true")
+	}
+
+	events := decodeJSONLines(t, out.String())
+	if len(events) != 2 {
+		t.Fatalf("len(events) = %d, want 2 (%s)", len(events), out.String())
+	}
+	if events[0].Type != JSONApprovalRequest || events[0].Kind != ApprovalTool || events[0].Name != "run_shell" {
+		t.Fatalf("request event = %+v", events[0])
+	}
+	if events[1].Type != JSONApprovalDecision || events[1].Approved == nil || !*events[1].Approved {
+		t.Fatalf("decision event = %+v, want approved", events[1])
+	}
+}
+
+func TestJSONFrontendRejectsOnEOF(t *testing.T) {
+	var out bytes.Buffer
+	frontend := NewJSONFrontend(&out, strings.NewReader(""), true)
+
+	if ok := frontend.RequestApproval(context.Background(), ApprovalRequest{Kind: ApprovalChangeBatch, ChangeCount: 2}); ok {
+		t.Fatalf("RequestApproval returned true, want false")
+	}
+	events := decodeJSONLines(t, out.String())
+	if got := events[len(events)-1]; got.Approved == nil || *got.Approved {
+		t.Fatalf("decision event = %+v, want approved=false", got)
+	}
+}
+
+func TestJSONFrontendSingleObjectBuffersEvents(t *testing.T) {
+	var out bytes.Buffer
+	frontend := NewJSONFrontend(&out, strings.NewReader(""), false)
+	ctx := context.Background()
+
+	frontend.HandleEvent(ctx, UserPromptEvent{Prompt: "fix it"})
+	frontend.HandleEvent(ctx, ErrorEvent{Err: errors.New("boom")})
+	if out.Len() != 0 {
+		t.Fatalf("output before Finish = %q, want empty", out.String())
+	}
+	frontend.Finish("", errors.New("boom"))
+
+	events := decodeJSONLines(t, out.String())
+	if len(events) != 1 {
+		t.Fatalf("len(objects) = %d, want 1 (%s)", len(events), out.String())
+	}
+	result := events[0]
+	if result.Type != JSONResult || !result.IsError || result.Error != "boom" {
+		t.Fatalf("result = %+v, want error result", result)
+	}
+	if len(result.Events) != 2 || result.Events[0].Type != JSONUserPrompt || result.Events[1].Type != JSONError {
+		t.Fatalf("result.Events = %+v", result.Events)
+	}
+}
+
+// answerAfter is stdin for a JSON-mode caller that answers an approval
+// only once the request has been written to out.
+type answerAfter struct {
+	out      *bytes.Buffer
+	answer   string
+	sawAsked bool
+}
+
+func (r *answerAfter) Read(p []byte) (int, error) {
+	if r.answer == "" {
+		return 0, io.EOF
+	}
+	r.sawAsked = strings.Contains(r.out.String(), `"type":"approval_request"`)
+	n := copy(p, r.answer)
+	r.answer = r.answer[n:]
+	return n, nil
+}
+
+func TestJSONFrontendSingleObjectWritesApprovalRequestBeforeReading(t *testing.T) {
+	var out bytes.Buffer
+	in := &answerAfter{out: &out, answer: "y\n"}
+	frontend := NewJSONFrontend(&out, in, false)
+
+	ok := frontend.RequestApproval(context.Background(), ApprovalRequest{
+		Kind:     ApprovalTool,
+		ToolName: "run_shell",
+		ToolArgs: map[string]any{"command": "go test ./..."},
+	})
+	if !ok || !in.sawAsked {
+		t.Fatalf("approved = %v, request written before reading = %v (%s)", ok, in.sawAsked, out.String())
+	}
+	frontend.Finish("done", nil)
+
+	events := decodeJSONLines(t, out.String())
+	if len(events) != 2 || events[0].Type != JSONApprovalRequest || events[1].Type != JSONResult {
+		t.Fatalf("objects = %+v", events)
+	}
+	if got := events[1].Events; len(got) != 2 || got[0].Type != JSONApprovalRequest || got[1].Type != JSONApprovalDecision {
+		t.Errorf("result.Events = %+v", got)
+	}
+}
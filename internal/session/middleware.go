@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/permissions"
 )
 
 // Middleware decorates a frontend with cross-cutting behavior.
@@ -47,3 +48,7 @@ func (f *synchronizedFrontend) RequestSubAgentApproval(ctx context.Context, resu
 	defer f.mu.Unlock()
 	return f.next.RequestSubAgentApproval(ctx, result)
 }
+
+func (f *synchronizedFrontend) AuthorizeTool(ctx context.Context, name string, args map[string]any) permissions.Decision {
+	return authorizeTool(ctx, f.next, name, args)
+}
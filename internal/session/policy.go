@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"fmt"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/permissions"
+)
+
+// ToolAuthorizer is an optional frontend extension consulted before the
+// session's built-in approval routing. Policy middleware implements it;
+// pass-through middleware forwards it to the frontend it wraps.
+type ToolAuthorizer interface {
+	AuthorizeTool(ctx context.Context, name string, args map[string]any) permissions.Decision
+}
+
+// authorizeTool asks f for a policy decision, returning no decision when f
+// does not take part in authorization.
+func authorizeTool(ctx context.Context, f SessionFrontend, name string, args map[string]any) permissions.Decision {
+	if a, ok := f.(ToolAuthorizer); ok {
+		return a.AuthorizeTool(ctx, name, args)
+	}
+	return permissions.Decision{}
+}
+
+// PolicyMiddleware applies allow/ask/deny rules to every tool call, including
+// tools the session would otherwise auto-approve. Calls no rule matches fall
+// through to the wrapped frontend, which may answer with a grant that adds a
+// rule to the policy.
+func PolicyMiddleware(policy *permissions.Policy) Middleware {
+	return func(next SessionFrontend) SessionFrontend {
+		return &policyFrontend{next: next, policy: policy}
+	}
+}
+
+type policyFrontend struct {
+	next   SessionFrontend
+	policy *permissions.Policy
+}
+
+func (f *policyFrontend) AuthorizeTool(ctx context.Context, name string, args map[string]any) permissions.Decision {
+	if decision := f.policy.Evaluate(name, args); decision.Action != permissions.None {
+		return decision
+	}
+	return authorizeTool(ctx, f.next, name, args)
+}
+
+func (f *policyFrontend) HandleEvent(ctx context.Context, event Event) {
+	f.next.HandleEvent(ctx, event)
+}
+
+// RequestApproval offers "always allow" grants alongside one-off tool approvals.
+func (f *policyFrontend) RequestApproval(ctx context.Context, req ApprovalRequest) bool {
+	if req.Kind == ApprovalTool && f.policy != nil {
+		for _, g := range permissions.Grants(req.ToolName, req.ToolArgs, f.policy.CWD) {
+			if g.Scope != permissions.GrantProject || f.policy.Project {
+				req.Grants = append(req.Grants, g)
+			}
+		}
+		req.Remember = func(g permissions.Grant) {
+			if err := f.policy.Grant(g); err != nil {
+				f.next.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("remember approval: %w", err)})
+			}
+		}
+	}
+	return f.next.RequestApproval(ctx, req)
+}
+
+func (f *policyFrontend) RequestSubAgentApproval(ctx context.Context, result core.SubAgentResult) core.SubAgentApprovalResponse {
+	return f.next.RequestSubAgentApproval(ctx, result)
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/permissions"
+)
+
+func newPolicySession(t *testing.T, frontend *mockFrontend, rules string, skipApprovals bool) *Session {
+	t.Helper()
+	parsed, err := permissions.Parse("test", rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd := t.TempDir()
+	sess := &Session{
+		agent:      &core.Agent{},
+		dispatcher: hooks.NewDispatcher(),
+		frontend: Chain(frontend,
+			SynchronizedMiddleware(),
+			PolicyMiddleware(&permissions.Policy{Rules: parsed, CWD: cwd}),
+		),
+		config:         Config{CWD: cwd, SkipApprovals: skipApprovals},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	sess.Bind(context.Background())
+	return sess
+}
+
+func TestPolicyDenyBlocksToolAndReportsRule(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: true}
+	sess := newPolicySession(t, frontend, "deny write_file migrations/*\n", true)
+
+	if sess.agent.OnToolCall("write_file", map[string]any{"path": "migrations/001.sql"}) {
+		t.Fatalf("OnToolCall returned true, want false")
+	}
+	if frontend.requestApprovalCount != 0 {
+		t.Fatalf("RequestApproval called %d times, want 0", frontend.requestApprovalCount)
+	}
+	if len(frontend.events) != 1 {
+		t.Fatalf("events = %v, want one ToolDeniedEvent", frontend.events)
+	}
+	denied, ok := frontend.events[0].(ToolDeniedEvent)
+	if !ok {
+		t.Fatalf("event type = %T, want ToolDeniedEvent", frontend.events[0])
+	}
+	if denied.Rule != "deny write_file migrations/* (test:1)" {
+		t.Fatalf("Rule = %q", denied.Rule)
+	}
+
+	if !sess.agent.OnToolCall("write_file", map[string]any{"path": "main.go"}) {
+		t.Fatalf("write outside migrations/ was blocked")
+	}
+}
+
+func TestPolicyAllowSkipsApproval(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: false}
+	sess := newPolicySession(t, frontend, "allow danger_tool\n", false)
+
+	if !sess.agent.OnToolCall("danger_tool", map[string]any{"k": "v"}) {
+		t.Fatalf("OnToolCall returned false, want true")
+	}
+	if frontend.requestApprovalCount != 0 {
+		t.Fatalf("RequestApproval called %d times, want 0", frontend.requestApprovalCount)
+	}
+	if _, ok := frontend.events[0].(ToolCallEvent); !ok {
+		t.Fatalf("first event type = %T, want ToolCallEvent", frontend.events[0])
+	}
+}
+
+func TestPolicyAskOverridesAutoApproval(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: false}
+	sess := newPolicySession(t, frontend, "ask read_file\n", false)
+
+	if sess.agent.OnToolCall("read_file", map[string]any{"path": "go.mod"}) {
+		t.Fatalf("OnToolCall returned true, want false after rejected approval")
+	}
+	if frontend.requestApprovalCount != 1 {
+		t.Fatalf("RequestApproval called %d times, want 1", frontend.requestApprovalCount)
+	}
+}
+
+func TestPolicyUnmatchedCallUsesDefaultRouting(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: false}
+	sess := newPolicySession(t, frontend, "deny WebFetch\n", false)
+
+	if !sess.agent.OnToolCall("read_file", map[string]any{"path": "go.mod"}) {
+		t.Fatalf("read-only tool was not auto-approved")
+	}
+	if sess.agent.OnToolCall("danger_tool", nil) {
+		t.Fatalf("unmatched tool bypassed approval")
+	}
+	if frontend.requestApprovalCount != 1 {
+		t.Fatalf("RequestApproval called %d times, want 1", frontend.requestApprovalCount)
+	}
+}
+
+func TestHeadlessGrantAnswerRemembersApproval(t *testing.T) {
+	var out bytes.Buffer
+	policy := &permissions.Policy{CWD: t.TempDir(), Project: true}
+	sess := &Session{
+		agent:      &core.Agent{},
+		dispatcher: hooks.NewDispatcher(),
+		frontend: Chain(NewHeadlessFrontend(&out, strings.NewReader("s\n")),
+			PolicyMiddleware(policy),
+		),
+		config:         Config{CWD: policy.CWD},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	sess.Bind(context.Background())
+
+	if !sess.agent.OnToolCall("danger_tool", nil) {
+		t.Fatalf("first call rejected; output %q", out.String())
+	}
+	if !strings.Contains(out.String(), "a = always allow every danger_tool call in this project") {
+		t.Fatalf("output %q missing grant choices", out.String())
+	}
+	// Input is exhausted, so a second prompt would reject.
+	if !sess.agent.OnToolCall("danger_tool", nil) {
+		t.Fatalf("second call was not allowed by the session grant")
+	}
+}
+
+func TestJSONGrantAnswerRemembersApproval(t *testing.T) {
+	var out bytes.Buffer
+	policy := &permissions.Policy{CWD: t.TempDir()}
+	frontend := Chain(NewJSONFrontend(&out, strings.NewReader(`{"approved":true,"grant":"prefix"}`+"\n"), true),
+		PolicyMiddleware(policy),
+	)
+	args := map[string]any{"command": "go test ./pkg"}
+	ok := frontend.RequestApproval(context.Background(), ApprovalRequest{Kind: ApprovalTool, ToolName: "run_shell", ToolArgs: args})
+	if !ok {
+		t.Fatalf("approval rejected; output %q", out.String())
+	}
+	if !strings.Contains(out.String(), `"grants":[`) {
+		t.Fatalf("approval_request %q missing grants", out.String())
+	}
+	if got := policy.Evaluate("run_shell", map[string]any{"command": "go test ./other"}); got.Action != permissions.Allow {
+		t.Fatalf("action = %q, want allow from prefix grant", got.Action)
+	}
+}
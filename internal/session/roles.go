@@ -0,0 +1,129 @@
+package session
+
+import (
+	"context"
+
+	"github.com/webforspeed/bono/hooks"
+)
+
+// Role is a phase of work that can run on its own model.
+type Role string
+
+const (
+	RoleMain       Role = "main"
+	RolePlan       Role = "plan"
+	RoleExplore    Role = "explore"
+	RoleCompaction Role = "compaction"
+)
+
+// Route is the model and reasoning effort a role runs on. An empty Model
+// keeps the current model and an empty Reasoning the current effort;
+// Reasoning "none" disables reasoning.
+type Route struct {
+	Model     string
+	Reasoning string
+}
+
+// Roles routes roles other than RoleMain to their own model.
+type Roles struct {
+	Routes map[Role]Route
+	// BaseURL returns the endpoint for a model. When set, it is applied
+	// with each switch and when switching back.
+	BaseURL func(model string) string
+}
+
+// roleAgent is the part of core.Agent that Roles drives.
+type roleAgent interface {
+	ModelName() string
+	SetModel(model string)
+	SetBaseURL(url string)
+	ReasoningEffort() string
+	SetReasoningEffort(effort string)
+}
+
+// use moves agent onto role's route and returns a func that moves it back
+// and reports the role that was active before. A role without a route
+// leaves the agent as it is.
+func (r Roles) use(agent roleAgent, role, prev Role, emit func(Event)) (restore func()) {
+	route, ok := r.Routes[role]
+	if !ok || (route.Model == "" && route.Reasoning == "") {
+		return func() {}
+	}
+	model, effort := agent.ModelName(), agent.ReasoningEffort()
+	if route.Model != "" {
+		r.setModel(agent, route.Model)
+	}
+	switch route.Reasoning {
+	case "":
+	case "none":
+		agent.SetReasoningEffort("")
+	default:
+		agent.SetReasoningEffort(route.Reasoning)
+	}
+	emit(RoleEvent{Role: role, Model: agent.ModelName(), Reasoning: agent.ReasoningEffort()})
+	return func() {
+		r.setModel(agent, model)
+		agent.SetReasoningEffort(effort)
+		emit(RoleEvent{Role: prev, Model: model, Reasoning: effort})
+	}
+}
+
+func (r Roles) setModel(agent roleAgent, model string) {
+	if agent.ModelName() == model {
+		return
+	}
+	agent.SetModel(model)
+	if r.BaseURL != nil {
+		agent.SetBaseURL(r.BaseURL(model))
+	}
+}
+
+// useRole moves the agent onto role's model and reasoning effort until the
+// returned func is called. The frontend is told of both switches with a
+// RoleEvent. Roles nest: compaction during the plan subagent returns to
+// the plan role.
+func (s *Session) useRole(ctx context.Context, role Role) (restore func()) {
+	s.roleMu.Lock()
+	prev := s.role
+	if prev == "" {
+		prev = RoleMain
+	}
+	s.role = role
+	roles := s.config.Roles
+	s.roleMu.Unlock()
+
+	agent := modelHooks{s.agent, ctx, s.dispatcher, hooks.ModelChangeRole}
+	back := roles.use(agent, role, prev, func(event Event) {
+		s.frontend.HandleEvent(ctx, event)
+	})
+	return func() {
+		back()
+		s.roleMu.Lock()
+		s.role = prev
+		s.roleMu.Unlock()
+	}
+}
+
+// beginRole moves onto role for the phase named key, such as a subagent
+// run, until endRole is called with the same key. It is driven by the
+// agent's start and end callbacks, so the phase is routed however it was
+// started: by a slash command, by the model or in a headless run.
+func (s *Session) beginRole(ctx context.Context, key string, role Role) {
+	restore := s.useRole(ctx, role)
+	s.roleMu.Lock()
+	if s.roleEnds == nil {
+		s.roleEnds = make(map[string]func())
+	}
+	s.roleEnds[key] = restore
+	s.roleMu.Unlock()
+}
+
+func (s *Session) endRole(key string) {
+	s.roleMu.Lock()
+	restore := s.roleEnds[key]
+	delete(s.roleEnds, key)
+	s.roleMu.Unlock()
+	if restore != nil {
+		restore()
+	}
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/changebatch"
+)
+
+type fakeRoleAgent struct {
+	fakeChatAgent
+	effort string
+}
+
+func (a *fakeRoleAgent) ReasoningEffort() string          { return a.effort }
+func (a *fakeRoleAgent) SetReasoningEffort(effort string) { a.effort = effort }
+
+func TestRolesSwitchAndRestore(t *testing.T) {
+	agent := &fakeRoleAgent{fakeChatAgent: fakeChatAgent{model: "anthropic/claude-opus-4", baseURL: "https://openrouter.ai/api/v1"}, effort: "high"}
+	roles := Roles{
+		Routes: map[Role]Route{
+			RoleExplore:    {Model: "qwen3:8b", Reasoning: "none"},
+			RoleCompaction: {Model: "google/gemini-2.5-flash"},
+			RolePlan:       {Reasoning: "xhigh"},
+		},
+		BaseURL: func(model string) string {
+			if model == "qwen3:8b" {
+				return "http://127.0.0.1:11434/v1"
+			}
+			return "https://openrouter.ai/api/v1"
+		},
+	}
+	var events []RoleEvent
+	emit := func(e Event) { events = append(events, e.(RoleEvent)) }
+
+	restore := roles.use(agent, RoleExplore, RoleMain, emit)
+	if agent.model != "qwen3:8b" || agent.baseURL != "http://127.0.0.1:11434/v1" || agent.effort != "" {
+		t.Errorf("explore: %s at %s, effort %q", agent.model, agent.baseURL, agent.effort)
+	}
+	// Compaction during exploring returns to the explore role.
+	restoreCompact := roles.use(agent, RoleCompaction, RoleExplore, emit)
+	if agent.model != "google/gemini-2.5-flash" || agent.baseURL != "https://openrouter.ai/api/v1" || agent.effort != "" {
+		t.Errorf("compaction: %s at %s, effort %q", agent.model, agent.baseURL, agent.effort)
+	}
+	restoreCompact()
+	if agent.model != "qwen3:8b" || agent.baseURL != "http://127.0.0.1:11434/v1" {
+		t.Errorf("after compaction: %s at %s", agent.model, agent.baseURL)
+	}
+	restore()
+	if agent.model != "anthropic/claude-opus-4" || agent.baseURL != "https://openrouter.ai/api/v1" || agent.effort != "high" {
+		t.Errorf("restored: %s at %s, effort %q", agent.model, agent.baseURL, agent.effort)
+	}
+
+	want := []RoleEvent{
+		{Role: RoleExplore, Model: "qwen3:8b"},
+		{Role: RoleCompaction, Model: "google/gemini-2.5-flash"},
+		{Role: RoleExplore, Model: "qwen3:8b"},
+		{Role: RoleMain, Model: "anthropic/claude-opus-4", Reasoning: "high"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	// A reasoning-only route keeps the model.
+	events = nil
+	roles.use(agent, RolePlan, RoleMain, emit)()
+	if len(events) != 2 || events[0] != (RoleEvent{Role: RolePlan, Model: "anthropic/claude-opus-4", Reasoning: "xhigh"}) || agent.effort != "high" {
+		t.Errorf("plan events = %+v, effort after = %q", events, agent.effort)
+	}
+}
+
+func TestRolesWithoutRouteLeaveAgentAlone(t *testing.T) {
+	agent := &fakeRoleAgent{fakeChatAgent: fakeChatAgent{model: "a"}, effort: "low"}
+	var events []Event
+	Roles{}.use(agent, RolePlan, RoleMain, func(e Event) { events = append(events, e) })()
+	if agent.model != "a" || agent.effort != "low" || len(events) != 0 {
+		t.Errorf("model %s, effort %s, events %v", agent.model, agent.effort, events)
+	}
+}
+
+func TestBindRoutesRolesFromAgentCallbacks(t *testing.T) {
+	frontend := &mockFrontend{}
+	sess := &Session{
+		agent:      &core.Agent{},
+		dispatcher: hooks.NewDispatcher(),
+		frontend:   frontend,
+		config: Config{SkipApprovals: true, Roles: Roles{Routes: map[Role]Route{
+			RolePlan:       {Reasoning: "xhigh"},
+			RoleExplore:    {Reasoning: "low"},
+			RoleCompaction: {Reasoning: "none"},
+		}}},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	sess.Bind(context.Background())
+
+	// The model enters plan mode on its own, explores and compacts inside it.
+	sess.agent.OnSubAgentStart("plan")
+	sess.agent.OnPreTaskStart("exploring")
+	sess.agent.OnPreTaskEnd("exploring")
+	sess.agent.OnToolCall("compact_context", nil)
+	sess.agent.OnToolDone("compact_context", nil, core.ToolResult{Success: true})
+	sess.agent.OnSubAgentEnd("plan")
+
+	var roles []Role
+	for _, e := range frontend.events {
+		if e, ok := e.(RoleEvent); ok {
+			roles = append(roles, e.Role)
+		}
+	}
+	want := []Role{RolePlan, RoleExplore, RolePlan, RoleCompaction, RolePlan, RoleMain}
+	if len(roles) != len(want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Fatalf("roles = %v, want %v", roles, want)
+		}
+	}
+}
+
+func TestRoleSwitchFiresModelChanged(t *testing.T) {
+	var changes []hooks.ModelChangedPayload
+	dispatcher := hooks.NewDispatcher()
+	dispatcher.On(hooks.ModelChanged, hooks.HandlerFunc(func(_ context.Context, _ hooks.Event, payload any) {
+		changes = append(changes, payload.(hooks.ModelChangedPayload))
+	}))
+	sess := &Session{
+		agent:      &core.Agent{},
+		dispatcher: dispatcher,
+		frontend:   &mockFrontend{},
+		config:     Config{Roles: Roles{Routes: map[Role]Route{RolePlan: {Model: "qwen3:8b"}}}},
+	}
+	sess.useRole(context.Background(), RolePlan)()
+	if len(changes) == 0 || changes[0].To != "qwen3:8b" || changes[0].Reason != hooks.ModelChangeRole {
+		t.Errorf("ModelChanged = %+v, want a switch to qwen3:8b for the role", changes)
+	}
+}
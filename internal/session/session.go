@@ -2,17 +2,34 @@ package session
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	core "github.com/webforspeed/bono-core"
 	"github.com/webforspeed/bono/hooks"
 	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/permissions"
 )
 
 type Config struct {
 	CWD           string
 	ShellPolicy   core.ShellPolicy
 	SkipApprovals bool
+	// ResumeContext is prepended to the first prompt of a resumed session.
+	ResumeContext string
+	// ChangeLog receives every approved change batch; nil keeps no history.
+	ChangeLog changebatch.ChangeLog
+	// PatchOut, when set, receives each change batch as proposed, written as
+	// a git-style patch before the user reviews it.
+	PatchOut string
+	// Fallback retries failed requests and switches to other models.
+	Fallback Fallback
+	// Roles runs the plan subagent, the exploring pre-task and compaction
+	// on their own models.
+	Roles Roles
 }
 
 // Session owns frontend-neutral agent callback wiring and per-session change tracking.
@@ -23,6 +40,20 @@ type Session struct {
 	config     Config
 
 	changeBatchMgr changebatch.BatchTracker
+
+	promptMu   sync.Mutex
+	lastPrompt string
+
+	snapshotWarning sync.Once
+
+	progress atomic.Int64 // tool calls and assistant output, for Fallback
+
+	roleMu   sync.Mutex
+	role     Role              // "" is RoleMain
+	roleEnds map[string]func() // restores the role active before each phase, by beginRole key
+
+	toolCallMu  sync.Mutex
+	toolCallIDs map[string][]string // IDs of calls awaiting OnToolDone, keyed by name and args
 }
 
 func New(agent *core.Agent, dispatcher *hooks.Dispatcher, config Config, frontend SessionFrontend) *Session {
@@ -37,74 +68,24 @@ func New(agent *core.Agent, dispatcher *hooks.Dispatcher, config Config, fronten
 
 func (s *Session) Bind(ctx context.Context) {
 	s.agent.OnToolCall = func(name string, args map[string]any) bool {
-		s.dispatcher.Fire(ctx, hooks.PreToolUse, hooks.ToolPayload{ToolName: name, Args: args})
-
-		if isReadOnlyTool(name) {
-			s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
-			return true
-		}
-
-		if isChangeTool(name) {
-			originalPath, _ := args["path"].(string)
-			if _, err := s.changeBatchMgr.BeginChange(s.config.CWD, name, originalPath); err != nil {
-				s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("track %s change: %w", originalPath, err)})
-				return false
-			}
-			s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
-			return true
-		}
-
-		if name == "run_shell" || name == "python_runtime" {
-			req := core.ShellRequestFromToolArgs(name, args)
-			decision := core.DecideShellRequest(s.config.ShellPolicy, req)
-			if decision.Route == core.ShellRouteHostDirect {
-				if s.config.SkipApprovals {
-					s.frontend.HandleEvent(ctx, ToolCallEvent{
-						Name:            name,
-						Args:            args,
-						ExecutionReason: decision.Reason,
-					})
-					return true
-				}
-				s.dispatcher.Fire(ctx, hooks.PermissionRequest, hooks.PermissionPayload{ToolName: name, Args: args})
-				return s.frontend.RequestApproval(ctx, ApprovalRequest{
-					Kind:            ApprovalTool,
-					ToolName:        name,
-					ToolArgs:        args,
-					ExecutionReason: decision.Reason,
-				})
-			}
-
-			if core.IsSandboxEnabled() {
-				s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args, Sandboxed: true})
-				return true
-			}
-
-			if s.config.SkipApprovals {
-				s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
-				return true
-			}
-			s.dispatcher.Fire(ctx, hooks.PermissionRequest, hooks.PermissionPayload{ToolName: name, Args: args})
-			return s.frontend.RequestApproval(ctx, ApprovalRequest{
-				Kind:     ApprovalTool,
-				ToolName: name,
-				ToolArgs: args,
-			})
+		s.progress.Add(1)
+		callCtx := hooks.WithToolCallID(ctx, s.beginToolCall(name, args))
+		if !s.allowToolCall(callCtx, name, args) {
+			s.endToolCall(name, args)
+			return false
 		}
-
-		if s.config.SkipApprovals {
-			s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
-			return true
+		if name == "compact_context" {
+			s.dispatcher.Fire(callCtx, hooks.PreCompact, hooks.PreCompactPayload{Trigger: hooks.CompactTriggerAgent})
+			s.beginRole(ctx, "compact_context", RoleCompaction)
 		}
-		s.dispatcher.Fire(ctx, hooks.PermissionRequest, hooks.PermissionPayload{ToolName: name, Args: args})
-		return s.frontend.RequestApproval(ctx, ApprovalRequest{
-			Kind:     ApprovalTool,
-			ToolName: name,
-			ToolArgs: args,
-		})
+		return true
 	}
 
 	s.agent.OnToolDone = func(name string, args map[string]any, result core.ToolResult) {
+		if name == "compact_context" {
+			s.endRole("compact_context")
+		}
+		ctx := hooks.WithToolCallID(ctx, s.endToolCall(name, args))
 		payload := hooks.ToolResultPayload{ToolName: name, Args: args, Status: result.Status, Success: result.Success}
 		if result.Success {
 			s.dispatcher.Fire(ctx, hooks.PostToolUse, payload)
@@ -124,6 +105,14 @@ func (s *Session) Bind(ctx context.Context) {
 		})
 		s.frontend.HandleEvent(ctx, RefreshGitStatusEvent{})
 
+		if isShellTool(name) {
+			// Shell commands can change files even when they fail, so the
+			// snapshot is compared either way.
+			if _, err := s.changeBatchMgr.CompleteSnapshot(name, shellInput(args)); err != nil {
+				s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("detect %s changes: %w", name, err)})
+			}
+			return
+		}
 		if !isChangeTool(name) {
 			return
 		}
@@ -140,25 +129,35 @@ func (s *Session) Bind(ctx context.Context) {
 	}
 
 	s.agent.OnMessage = func(content string) {
+		s.progress.Add(1)
 		s.frontend.HandleEvent(ctx, MessageEvent{Content: content})
 	}
 	s.agent.OnContentDelta = func(delta string) {
+		s.progress.Add(1)
 		s.frontend.HandleEvent(ctx, ContentDeltaEvent{Delta: delta})
 	}
 	s.agent.OnReasoningDelta = func(delta string) {
 		s.frontend.HandleEvent(ctx, ReasoningDeltaEvent{Delta: delta})
 	}
+	// Pre-tasks explore the workspace, so they run on the explore role;
+	// a subagent runs on the role of the same name, such as plan.
 	s.agent.OnPreTaskStart = func(name string) {
 		s.frontend.HandleEvent(ctx, PreTaskStartEvent{Name: name})
+		s.beginRole(ctx, "pretask:"+name, RoleExplore)
 	}
 	s.agent.OnPreTaskEnd = func(name string) {
+		s.endRole("pretask:" + name)
 		s.frontend.HandleEvent(ctx, PreTaskEndEvent{Name: name})
 	}
 	s.agent.OnSubAgentStart = func(name string) {
+		s.dispatcher.Fire(ctx, hooks.SubAgentStart, hooks.SubAgentPayload{Name: name})
 		s.frontend.HandleEvent(ctx, SubAgentStartEvent{Name: name})
+		s.beginRole(ctx, "subagent:"+name, Role(name))
 	}
 	s.agent.OnSubAgentEnd = func(name string) {
+		s.endRole("subagent:" + name)
 		s.frontend.HandleEvent(ctx, SubAgentEndEvent{Name: name})
+		s.dispatcher.Fire(ctx, hooks.SubAgentStop, hooks.SubAgentPayload{Name: name})
 	}
 	s.agent.OnContextUsage = func(pct float64, totalCost float64) {
 		s.frontend.HandleEvent(ctx, ContextUsageEvent{Pct: pct, TotalCost: totalCost})
@@ -167,23 +166,172 @@ func (s *Session) Bind(ctx context.Context) {
 		s.frontend.HandleEvent(ctx, ResponseModelEvent{ModelID: model})
 	}
 	s.agent.OnSandboxFallback = func(command string, reason string) bool {
-		if s.config.SkipApprovals {
-			return true
-		}
-		return s.frontend.RequestApproval(ctx, ApprovalRequest{
+		approved := s.config.SkipApprovals || s.requestApproval(ctx, ApprovalRequest{
 			Kind:    ApprovalSandboxFallback,
 			Command: command,
 			Reason:  reason,
 		})
+		s.dispatcher.Fire(ctx, hooks.SandboxFallback, hooks.SandboxFallbackPayload{Command: command, Reason: reason, Approved: approved})
+		return approved
 	}
 	s.agent.OnSubAgentApproval = func(result core.SubAgentResult) core.SubAgentApprovalResponse {
 		if s.config.SkipApprovals {
 			return core.SubAgentApprovalResponse{Action: core.SubAgentApprove}
 		}
+		s.dispatcher.Fire(ctx, hooks.Notification, hooks.NotificationPayload{Kind: string(ApprovalSubAgentPlan), Message: SubAgentPlanPrompt})
 		return s.frontend.RequestSubAgentApproval(ctx, result)
 	}
 }
 
+// beginToolCall assigns an ID to a tool call so its hook events can be
+// correlated. Calls are matched to OnToolDone by name and arguments, oldest
+// first.
+func (s *Session) beginToolCall(name string, args map[string]any) string {
+	id := hooks.NewToolCallID()
+	key := toolCallKey(name, args)
+	s.toolCallMu.Lock()
+	defer s.toolCallMu.Unlock()
+	if s.toolCallIDs == nil {
+		s.toolCallIDs = make(map[string][]string)
+	}
+	s.toolCallIDs[key] = append(s.toolCallIDs[key], id)
+	return id
+}
+
+// endToolCall returns and forgets the ID of the oldest matching call.
+func (s *Session) endToolCall(name string, args map[string]any) string {
+	key := toolCallKey(name, args)
+	s.toolCallMu.Lock()
+	defer s.toolCallMu.Unlock()
+	ids := s.toolCallIDs[key]
+	if len(ids) == 0 {
+		return ""
+	}
+	if len(ids) == 1 {
+		delete(s.toolCallIDs, key)
+	} else {
+		s.toolCallIDs[key] = ids[1:]
+	}
+	return ids[0]
+}
+
+func toolCallKey(name string, args map[string]any) string {
+	data, _ := json.Marshal(args)
+	return name + "\x00" + string(data)
+}
+
+// allowToolCall runs PreToolUse hooks, permission rules and approvals for a
+// tool call, and starts change tracking for tools that edit files.
+func (s *Session) allowToolCall(ctx context.Context, name string, args map[string]any) bool {
+	if decision := s.dispatcher.Fire(ctx, hooks.PreToolUse, hooks.ToolPayload{ToolName: name, Args: args}); decision.Block {
+		s.frontend.HandleEvent(ctx, ToolDeniedEvent{Name: name, Args: args, Rule: "PreToolUse hook: " + decision.Reason})
+		return false
+	}
+
+	policy := authorizeTool(ctx, s.frontend, name, args)
+	if policy.Action == permissions.Deny {
+		s.frontend.HandleEvent(ctx, ToolDeniedEvent{Name: name, Args: args, Rule: policy.Rule.String()})
+		return false
+	}
+	req := ApprovalRequest{Kind: ApprovalTool, ToolName: name, ToolArgs: args}
+
+	if isReadOnlyTool(name) {
+		if policy.Action == permissions.Ask {
+			return s.requestToolApproval(ctx, req)
+		}
+		s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
+		return true
+	}
+
+	if isChangeTool(name) {
+		if policy.Action == permissions.Ask && !s.requestToolApproval(ctx, req) {
+			return false
+		}
+		originalPath, _ := args["path"].(string)
+		if _, err := s.changeBatchMgr.BeginChange(s.config.CWD, name, originalPath); err != nil {
+			s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("track %s change: %w", originalPath, err)})
+			return false
+		}
+		if policy.Action != permissions.Ask {
+			s.frontend.HandleEvent(ctx, ToolCallEvent{Name: name, Args: args})
+		}
+		return true
+	}
+
+	if isShellTool(name) {
+		if !s.authorizeShell(ctx, policy, req) {
+			return false
+		}
+		s.beginSnapshot(ctx, name, args)
+		return true
+	}
+
+	return s.approveTool(ctx, policy, req)
+}
+
+// authorizeShell routes a shell or python call: host-direct runs need
+// approval, sandboxed runs are allowed unless the policy asks.
+func (s *Session) authorizeShell(ctx context.Context, policy permissions.Decision, req ApprovalRequest) bool {
+	shellReq := core.ShellRequestFromToolArgs(req.ToolName, req.ToolArgs)
+	decision := core.DecideShellRequest(s.config.ShellPolicy, shellReq)
+	if decision.Route == core.ShellRouteHostDirect {
+		req.ExecutionReason = decision.Reason
+		return s.approveTool(ctx, policy, req)
+	}
+
+	if core.IsSandboxEnabled() {
+		if policy.Action == permissions.Ask {
+			return s.requestToolApproval(ctx, req)
+		}
+		s.frontend.HandleEvent(ctx, ToolCallEvent{Name: req.ToolName, Args: req.ToolArgs, Sandboxed: true})
+		return true
+	}
+	return s.approveTool(ctx, policy, req)
+}
+
+// beginSnapshot records the workspace before a shell or python call so the
+// files it touches join the change batch. A workspace too large to snapshot
+// is reported once and then left untracked.
+func (s *Session) beginSnapshot(ctx context.Context, name string, args map[string]any) {
+	err := s.changeBatchMgr.BeginSnapshot(s.config.CWD, name, shellInput(args))
+	switch {
+	case err == nil:
+	case errors.Is(err, changebatch.ErrWorkspaceTooLarge):
+		s.snapshotWarning.Do(func() {
+			s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("%w; shell and python edits will not be reviewable or undoable", err)})
+		})
+	default:
+		s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("track %s changes: %w", name, err)})
+	}
+}
+
+// approveTool resolves a call that needs approval by default: a matching allow
+// rule runs it directly, anything else goes through requestToolApproval.
+func (s *Session) approveTool(ctx context.Context, policy permissions.Decision, req ApprovalRequest) bool {
+	if policy.Action == permissions.Allow {
+		s.frontend.HandleEvent(ctx, ToolCallEvent{Name: req.ToolName, Args: req.ToolArgs, ExecutionReason: req.ExecutionReason})
+		return true
+	}
+	return s.requestToolApproval(ctx, req)
+}
+
+// requestToolApproval asks the frontend to approve a tool call unless approvals are skipped.
+func (s *Session) requestToolApproval(ctx context.Context, req ApprovalRequest) bool {
+	if s.config.SkipApprovals {
+		s.frontend.HandleEvent(ctx, ToolCallEvent{Name: req.ToolName, Args: req.ToolArgs, ExecutionReason: req.ExecutionReason})
+		return true
+	}
+	s.dispatcher.Fire(ctx, hooks.PermissionRequest, hooks.PermissionPayload{ToolName: req.ToolName, Args: req.ToolArgs})
+	return s.requestApproval(ctx, req)
+}
+
+// requestApproval tells Notification hooks that bono is waiting on the user,
+// then asks the frontend.
+func (s *Session) requestApproval(ctx context.Context, req ApprovalRequest) bool {
+	s.dispatcher.Fire(ctx, hooks.Notification, hooks.NotificationPayload{Kind: string(req.Kind), Message: ApprovalPrompt(req)})
+	return s.frontend.RequestApproval(ctx, req)
+}
+
 func (s *Session) Reset() {
 	s.changeBatchMgr.Reset()
 }
@@ -201,32 +349,163 @@ func (s *Session) StopHandler() hooks.Handler {
 				NewContent: change.AfterContent,
 			})
 		}
+		if s.config.PatchOut != "" {
+			if err := changebatch.SavePatch(s.config.PatchOut, completed); err != nil {
+				s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("write patch: %w", err)})
+			}
+		}
 		if s.config.SkipApprovals {
+			s.recordBatch(ctx, completed)
+			s.fireBatchDecision(ctx, hooks.BatchAutoApproved, completed, completed)
 			s.frontend.HandleEvent(ctx, RefreshGitStatusEvent{})
 			return
 		}
 
-		ok := s.frontend.RequestApproval(ctx, ApprovalRequest{
+		var decisions []changebatch.FileDecision
+		ok := s.requestApproval(ctx, ApprovalRequest{
 			Kind:        ApprovalChangeBatch,
 			ChangeCount: len(completed),
+			Changes:     completed,
+			Decide:      func(d []changebatch.FileDecision) { decisions = d },
 		})
-		if !ok {
-			if err := s.changeBatchMgr.UndoBatch(completed); err != nil {
+		switch {
+		case !ok:
+			kept := s.undoBatch(ctx, completed)
+			s.fireBatchDecision(ctx, hooks.BatchRejected, completed, kept)
+		case decisions != nil:
+			kept, err := s.changeBatchMgr.ApplyReview(completed, decisions)
+			if err != nil {
 				s.frontend.HandleEvent(ctx, ErrorEvent{Err: err})
 			}
+			s.recordBatch(ctx, kept)
+			s.fireBatchDecision(ctx, hooks.BatchPartial, completed, kept)
+		default:
+			s.recordBatch(ctx, completed)
+			s.fireBatchDecision(ctx, hooks.BatchApproved, completed, completed)
 		}
 		s.frontend.HandleEvent(ctx, RefreshGitStatusEvent{})
 	})
 }
 
+// undoBatch reverts a rejected batch. Files edited since the agent changed
+// them are merged; where the edits overlap, the user decides whether to
+// overwrite them. Files left alone keep the agent's changes, which are then
+// recorded so they can still be undone from the history, and returned.
+func (s *Session) undoBatch(ctx context.Context, changes []changebatch.FileChange) []changebatch.FileChange {
+	err := s.changeBatchMgr.UndoBatch(changes)
+	var conflict *changebatch.ConflictError
+	if !errors.As(err, &conflict) {
+		if err != nil {
+			s.frontend.HandleEvent(ctx, ErrorEvent{Err: err})
+		}
+		return nil
+	}
+	overwrite := s.requestApproval(ctx, ApprovalRequest{
+		Kind:        ApprovalUndoConflict,
+		ChangeCount: len(conflict.Changes),
+		Changes:     conflict.Changes,
+	})
+	if !overwrite {
+		s.recordBatch(ctx, conflict.Changes)
+		return conflict.Changes
+	}
+	if err := s.changeBatchMgr.ForceUndo(conflict.Changes); err != nil {
+		s.frontend.HandleEvent(ctx, ErrorEvent{Err: err})
+	}
+	return nil
+}
+
+func (s *Session) fireBatchDecision(ctx context.Context, decision string, changes, kept []changebatch.FileChange) {
+	s.dispatcher.Fire(ctx, hooks.ChangeBatchDecision, hooks.ChangeBatchDecisionPayload{
+		Decision: decision,
+		Files:    displayPaths(changes),
+		Kept:     displayPaths(kept),
+	})
+}
+
+func displayPaths(changes []changebatch.FileChange) []string {
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = c.DisplayPath
+	}
+	return paths
+}
+
+// PromptHandler remembers the latest user prompt so approved batches in the
+// change history can say what caused them.
+func (s *Session) PromptHandler() hooks.Handler {
+	return hooks.HandlerFunc(func(_ context.Context, _ hooks.Event, payload any) {
+		if p, ok := payload.(hooks.UserPromptSubmitPayload); ok {
+			s.promptMu.Lock()
+			s.lastPrompt = p.Input
+			s.promptMu.Unlock()
+		}
+	})
+}
+
+func (s *Session) recordBatch(ctx context.Context, changes []changebatch.FileChange) {
+	if s.config.ChangeLog == nil {
+		return
+	}
+	s.promptMu.Lock()
+	prompt := s.lastPrompt
+	s.promptMu.Unlock()
+	if err := s.config.ChangeLog.RecordApprovedBatch(prompt, changes); err != nil {
+		s.frontend.HandleEvent(ctx, ErrorEvent{Err: fmt.Errorf("record change history: %w", err)})
+	}
+}
+
+// ReportHookErrors forwards hook handler failures to the frontend as
+// HookErrorEvents until ctx ends.
+func (s *Session) ReportHookErrors(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-s.dispatcher.Errors():
+			s.reportHookError(ctx, err)
+		}
+	}
+}
+
+// FlushHookErrors forwards hook errors that are already waiting, such as
+// those from async handlers drained at SessionEnd.
+func (s *Session) FlushHookErrors(ctx context.Context) {
+	for {
+		select {
+		case err := <-s.dispatcher.Errors():
+			s.reportHookError(ctx, err)
+		default:
+			return
+		}
+	}
+}
+
+func (s *Session) reportHookError(ctx context.Context, err error) {
+	event := HookErrorEvent{Err: err}
+	var handlerErr *hooks.HandlerError
+	if errors.As(err, &handlerErr) {
+		event.Event = string(handlerErr.Event)
+	}
+	s.frontend.HandleEvent(ctx, event)
+}
+
 func (s *Session) RunPrompt(ctx context.Context, prompt string) (string, error) {
 	s.dispatcher.Fire(ctx, hooks.SessionStart, hooks.SessionStartPayload{})
 	defer s.dispatcher.Fire(ctx, hooks.SessionEnd, hooks.SessionEndPayload{})
 
-	s.dispatcher.Fire(ctx, hooks.UserPromptSubmit, hooks.UserPromptSubmitPayload{Input: prompt})
+	decision := s.dispatcher.Fire(ctx, hooks.UserPromptSubmit, hooks.UserPromptSubmitPayload{Input: prompt})
+	if decision.Block {
+		err := fmt.Errorf("prompt blocked by hook: %s", decision.Reason)
+		s.frontend.HandleEvent(ctx, ErrorEvent{Err: err})
+		return "", err
+	}
 	s.frontend.HandleEvent(ctx, UserPromptEvent{Prompt: prompt})
 
-	response, err := s.agent.Chat(ctx, prompt)
+	agentPrompt := AgentPrompt(s.config.ResumeContext, prompt, decision.Context)
+	s.config.ResumeContext = ""
+
+	response, err := s.Chat(ctx, agentPrompt)
 	if err != nil {
 		s.frontend.HandleEvent(ctx, ErrorEvent{Err: err})
 	}
@@ -234,6 +513,49 @@ func (s *Session) RunPrompt(ctx context.Context, prompt string) (string, error)
 	return response, err
 }
 
+// SetRouting replaces the fallback chain and role routes, as when /profile
+// switches profiles. A request or role already running keeps the old ones.
+func (s *Session) SetRouting(fallback Fallback, roles Roles) {
+	s.roleMu.Lock()
+	defer s.roleMu.Unlock()
+	s.config.Fallback, s.config.Roles = fallback, roles
+}
+
+// AgentPrompt assembles what the agent is sent for a user prompt: the
+// resume preamble, if any, then context from UserPromptSubmit hooks, then
+// the prompt itself. Every frontend builds prompts with it so they match.
+func AgentPrompt(resume, prompt, hookContext string) string {
+	return resume + hooks.WithContext(prompt, hookContext)
+}
+
+// Chat sends prompt to the agent under the session's fallback policy.
+func (s *Session) Chat(ctx context.Context, prompt string) (string, error) {
+	s.roleMu.Lock()
+	fallback := s.config.Fallback
+	s.roleMu.Unlock()
+	agent := modelHooks{s.agent, ctx, s.dispatcher, hooks.ModelChangeFallback}
+	return fallback.run(ctx, agent, prompt, s.progress.Load, func(event Event) {
+		s.frontend.HandleEvent(ctx, event)
+	})
+}
+
+// modelHooks is the agent as Fallback and Roles drive it: every model they
+// switch to, or back from, fires ModelChanged with reason.
+type modelHooks struct {
+	*core.Agent
+	ctx        context.Context
+	dispatcher *hooks.Dispatcher
+	reason     string
+}
+
+func (a modelHooks) SetModel(model string) {
+	from := a.Agent.ModelName()
+	a.Agent.SetModel(model)
+	if from != model {
+		a.dispatcher.Fire(a.ctx, hooks.ModelChanged, hooks.ModelChangedPayload{From: from, To: model, Reason: a.reason})
+	}
+}
+
 func isReadOnlyTool(name string) bool {
 	switch name {
 	case "read_file", "compact_context", "code_search", "WebSearch", "WebFetch", "enter_plan_mode":
@@ -246,3 +568,19 @@ func isReadOnlyTool(name string) bool {
 func isChangeTool(name string) bool {
 	return name == "write_file" || name == "edit_file"
 }
+
+// isShellTool reports whether a tool runs arbitrary code whose file changes
+// are found by snapshotting the workspace.
+func isShellTool(name string) bool {
+	return name == "run_shell" || name == "python_runtime"
+}
+
+// shellInput returns the command or code of a shell tool call; it pairs the
+// call's snapshot with its completion.
+func shellInput(args map[string]any) string {
+	if cmd, ok := args["command"].(string); ok {
+		return cmd
+	}
+	code, _ := args["code"].(string)
+	return code
+}
@@ -142,3 +142,295 @@ func (m *mockFrontend) RequestApproval(_ context.Context, _ ApprovalRequest) boo
 func (m *mockFrontend) RequestSubAgentApproval(_ context.Context, _ core.SubAgentResult) core.SubAgentApprovalResponse {
 	return core.SubAgentApprovalResponse{Action: core.SubAgentApprove}
 }
+
+func TestStopHandlerRecordsApprovedBatchWithPrompt(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := &recordingChangeLog{}
+	sess := &Session{
+		dispatcher:     hooks.NewDispatcher(),
+		frontend:       &mockFrontend{approvalResult: true},
+		config:         Config{CWD: cwd, ChangeLog: log},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	sess.PromptHandler().Handle(context.Background(), hooks.UserPromptSubmit, hooks.UserPromptSubmitPayload{Input: "tidy notes"})
+	if _, err := sess.changeBatchMgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sess.changeBatchMgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	sess.StopHandler().Handle(context.Background(), hooks.Stop, hooks.StopPayload{})
+
+	if log.prompt != "tidy notes" {
+		t.Fatalf("prompt = %q, want %q", log.prompt, "tidy notes")
+	}
+	if len(log.changes) != 1 || log.changes[0].AfterContent != "after\n" {
+		t.Fatalf("changes = %+v", log.changes)
+	}
+}
+
+type recordingChangeLog struct {
+	prompt  string
+	changes []changebatch.FileChange
+}
+
+func (l *recordingChangeLog) RecordApprovedBatch(prompt string, changes []changebatch.FileChange) error {
+	l.prompt, l.changes = prompt, changes
+	return nil
+}
+
+func TestStopHandlerAppliesHunkReview(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	if err := os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	log := &recordingChangeLog{}
+	sess := &Session{
+		dispatcher:     hooks.NewDispatcher(),
+		frontend:       NewHeadlessFrontend(&out, strings.NewReader("r\nh\ny\nn\n")),
+		config:         Config{CWD: cwd, ChangeLog: log},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	if _, err := sess.changeBatchMgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("A\nb\nc\nD\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sess.changeBatchMgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	sess.StopHandler().Handle(context.Background(), hooks.Stop, hooks.StopPayload{})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "A\nb\nc\nd\n" {
+		t.Fatalf("file contents = %q, want second hunk reverted", string(data))
+	}
+	if !strings.Contains(out.String(), "approved, reverted 1 hunk") {
+		t.Fatalf("output %q missing review status", out.String())
+	}
+	if len(log.changes) != 1 || log.changes[0].AfterContent != "A\nb\nc\nd\n" {
+		t.Fatalf("recorded changes = %+v", log.changes)
+	}
+}
+
+func TestShellToolChangesJoinBatch(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: true}
+	sess := newPolicySession(t, frontend, "", true)
+	path := filepath.Join(sess.config.CWD, "gen.txt")
+	args := map[string]any{"command": "echo hi > gen.txt"}
+
+	if !sess.agent.OnToolCall("run_shell", args) {
+		t.Fatalf("OnToolCall returned false")
+	}
+	if err := os.WriteFile(path, []byte("hi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sess.agent.OnToolDone("run_shell", args, core.ToolResult{Success: true})
+
+	changes := sess.changeBatchMgr.DrainCompleted()
+	if len(changes) != 1 || changes[0].DisplayPath != "gen.txt" || !changes[0].WasNewFile {
+		t.Fatalf("changes = %+v, want new gen.txt", changes)
+	}
+}
+
+func TestStopHandlerAsksBeforeOverwritingLaterEdits(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	log := &recordingChangeLog{}
+	sess := &Session{
+		dispatcher:     hooks.NewDispatcher(),
+		frontend:       NewHeadlessFrontend(&out, strings.NewReader("n\nn\n")),
+		config:         Config{CWD: cwd, ChangeLog: log},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	if _, err := sess.changeBatchMgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sess.changeBatchMgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	// The user edits the same line before answering the review prompt.
+	if err := os.WriteFile(path, []byte("mine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess.StopHandler().Handle(context.Background(), hooks.Stop, hooks.StopPayload{})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "mine\n" {
+		t.Fatalf("file contents = %q, want the user's edit kept", string(data))
+	}
+	if !strings.Contains(out.String(), "● Undo conflicts with later edits to notes.txt => kept") {
+		t.Fatalf("output %q missing conflict status", out.String())
+	}
+	if len(log.changes) != 1 {
+		t.Fatalf("recorded changes = %+v, want the kept change", log.changes)
+	}
+}
+
+func TestStopHandlerWritesPatchBeforeReview(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patchPath := filepath.Join(t.TempDir(), "batch.patch")
+
+	sess := &Session{
+		dispatcher:     hooks.NewDispatcher(),
+		frontend:       &mockFrontend{approvalResult: false},
+		config:         Config{CWD: cwd, PatchOut: patchPath},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	if _, err := sess.changeBatchMgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sess.changeBatchMgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	sess.StopHandler().Handle(context.Background(), hooks.Stop, hooks.StopPayload{})
+
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(patch), "-before\n+after\n") {
+		t.Fatalf("patch = %q, want the rejected change", patch)
+	}
+}
+
+func TestBindPreToolUseHookBlocksTool(t *testing.T) {
+	frontend := &mockFrontend{approvalResult: true}
+	dispatcher := hooks.NewDispatcher()
+	dispatcher.On(hooks.PreToolUse, blockingHook{reason: "no secrets"})
+	sess := &Session{
+		agent:          &core.Agent{},
+		dispatcher:     dispatcher,
+		frontend:       frontend,
+		config:         Config{SkipApprovals: true},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	sess.Bind(context.Background())
+
+	if sess.agent.OnToolCall("danger_tool", map[string]any{}) {
+		t.Fatalf("OnToolCall returned true, want false")
+	}
+	denied, ok := frontend.events[0].(ToolDeniedEvent)
+	if !ok {
+		t.Fatalf("first event = %T, want ToolDeniedEvent", frontend.events[0])
+	}
+	if denied.Rule != "PreToolUse hook: no secrets" {
+		t.Fatalf("Rule = %q", denied.Rule)
+	}
+}
+
+type blockingHook struct{ reason string }
+
+func (blockingHook) Handle(context.Context, hooks.Event, any) {}
+
+func (h blockingHook) Decide(context.Context, hooks.Event, any) hooks.Decision {
+	return hooks.Decision{Block: true, Reason: h.reason}
+}
+
+func TestStopHandlerFiresNotificationAndBatchDecision(t *testing.T) {
+	cwd := t.TempDir()
+	path := filepath.Join(cwd, "notes.txt")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var fired []hooks.Event
+	var decision hooks.ChangeBatchDecisionPayload
+	dispatcher := hooks.NewDispatcher()
+	record := hooks.HandlerFunc(func(_ context.Context, event hooks.Event, payload any) {
+		fired = append(fired, event)
+		if p, ok := payload.(hooks.ChangeBatchDecisionPayload); ok {
+			decision = p
+		}
+	})
+	dispatcher.On(hooks.Notification, record)
+	dispatcher.On(hooks.ChangeBatchDecision, record)
+	sess := &Session{
+		dispatcher:     dispatcher,
+		frontend:       NewHeadlessFrontend(&bytes.Buffer{}, strings.NewReader("n\n")),
+		config:         Config{CWD: cwd},
+		changeBatchMgr: changebatch.NewManager(),
+	}
+	if _, err := sess.changeBatchMgr.BeginChange(cwd, "edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sess.changeBatchMgr.CompleteChange("edit_file", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	sess.StopHandler().Handle(context.Background(), hooks.Stop, hooks.StopPayload{})
+
+	if len(fired) != 2 || fired[0] != hooks.Notification || fired[1] != hooks.ChangeBatchDecision {
+		t.Fatalf("fired = %v, want Notification then ChangeBatchDecision", fired)
+	}
+	if decision.Decision != hooks.BatchRejected || len(decision.Files) != 1 || decision.Files[0] != "notes.txt" || len(decision.Kept) != 0 {
+		t.Fatalf("decision = %+v", decision)
+	}
+}
+
+func TestFlushHookErrorsReportsHookErrorEvents(t *testing.T) {
+	frontend := &mockFrontend{}
+	dispatcher := hooks.NewDispatcher()
+	dispatcher.On(hooks.Stop, hooks.HandlerFunc(func(context.Context, hooks.Event, any) { panic("boom") }))
+	sess := &Session{dispatcher: dispatcher, frontend: frontend}
+
+	dispatcher.Fire(context.Background(), hooks.Stop, hooks.StopPayload{})
+	sess.FlushHookErrors(context.Background())
+
+	if len(frontend.events) != 1 {
+		t.Fatalf("events = %v, want one HookErrorEvent", frontend.events)
+	}
+	if event, ok := frontend.events[0].(HookErrorEvent); !ok || event.Event != "Stop" {
+		t.Fatalf("event = %#v", frontend.events[0])
+	}
+}
+
+func TestAgentPromptPutsResumeBeforeHookContext(t *testing.T) {
+	got := AgentPrompt("Transcript so far: ...\n\n", "fix the build", "branch: main")
+	if want := "Transcript so far: ...\n\nbranch: main\n\nfix the build"; got != want {
+		t.Errorf("AgentPrompt = %q, want %q", got, want)
+	}
+	if got := AgentPrompt("", "hi", ""); got != "hi" {
+		t.Errorf("AgentPrompt without resume or context = %q", got)
+	}
+}
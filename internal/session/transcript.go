@@ -0,0 +1,95 @@
+package session
+
+import (
+	"context"
+
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/permissions"
+	"github.com/webforspeed/bono/internal/transcript"
+)
+
+// RecordingMiddleware appends frontend-visible session events to a transcript.
+// User prompts are recorded by TranscriptHandler instead, because the TUI
+// submits prompts to the agent directly rather than through the frontend.
+func RecordingMiddleware(rec *transcript.Recorder) Middleware {
+	return func(next SessionFrontend) SessionFrontend {
+		return &transcriptFrontend{next: next, rec: rec}
+	}
+}
+
+type transcriptFrontend struct {
+	next      SessionFrontend
+	rec       *transcript.Recorder
+	lastModel string
+}
+
+func (f *transcriptFrontend) HandleEvent(ctx context.Context, event Event) {
+	switch event := event.(type) {
+	case MessageEvent:
+		f.rec.Record(transcript.Entry{Type: transcript.EntryMessage, Content: event.Content})
+	case ToolDoneEvent:
+		f.rec.Record(transcript.Entry{
+			Type:   transcript.EntryTool,
+			Tool:   event.Name,
+			Label:  FormatTool(event.Name, event.Args),
+			Args:   event.Args,
+			Status: event.Status,
+		})
+	case ToolDeniedEvent:
+		f.rec.Record(transcript.Entry{
+			Type:   transcript.EntryTool,
+			Tool:   event.Name,
+			Label:  FormatTool(event.Name, event.Args),
+			Args:   event.Args,
+			Status: "denied by " + event.Rule,
+		})
+	case ResponseModelEvent:
+		if event.ModelID != "" && event.ModelID != f.lastModel {
+			f.lastModel = event.ModelID
+			f.rec.Record(transcript.Entry{Type: transcript.EntryModel, Model: event.ModelID})
+		}
+	case ContextUsageEvent:
+		f.rec.Record(transcript.Entry{Type: transcript.EntryUsage, ContextPct: event.Pct, TotalCost: event.TotalCost})
+	case ErrorEvent:
+		if event.Err != nil {
+			f.rec.Record(transcript.Entry{Type: transcript.EntryError, Content: event.Err.Error()})
+		}
+	case HookErrorEvent:
+		f.rec.Record(transcript.Entry{Type: transcript.EntryError, Content: event.Err.Error()})
+	}
+	f.next.HandleEvent(ctx, event)
+}
+
+func (f *transcriptFrontend) RequestApproval(ctx context.Context, req ApprovalRequest) bool {
+	ok := f.next.RequestApproval(ctx, req)
+	entry := transcript.Entry{
+		Type:     transcript.EntryApproval,
+		Tool:     req.ToolName,
+		Status:   string(req.Kind),
+		Approved: &ok,
+	}
+	if req.ToolName != "" {
+		entry.Label = FormatTool(req.ToolName, req.ToolArgs)
+	}
+	f.rec.Record(entry)
+	return ok
+}
+
+func (f *transcriptFrontend) AuthorizeTool(ctx context.Context, name string, args map[string]any) permissions.Decision {
+	return authorizeTool(ctx, f.next, name, args)
+}
+
+func (f *transcriptFrontend) RequestSubAgentApproval(ctx context.Context, result core.SubAgentResult) core.SubAgentApprovalResponse {
+	return f.next.RequestSubAgentApproval(ctx, result)
+}
+
+// TranscriptHandler records UserPromptSubmit payloads so both the TUI and
+// headless paths capture user input.
+func TranscriptHandler(rec *transcript.Recorder) hooks.Handler {
+	return hooks.HandlerFunc(func(_ context.Context, _ hooks.Event, payload any) {
+		if p, ok := payload.(hooks.UserPromptSubmitPayload); ok {
+			rec.Record(transcript.Entry{Type: transcript.EntryUserPrompt, Content: p.Input})
+		}
+	})
+}
@@ -0,0 +1,345 @@
+// Package transcript persists session transcripts as JSONL so sessions can be resumed.
+package transcript
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Entry types recorded in a transcript.
+const (
+	EntrySessionStart  = "session_start"
+	EntrySessionResume = "session_resume"
+	EntryUserPrompt    = "user_prompt"
+	EntryMessage       = "message"
+	EntryTool          = "tool"
+	EntryApproval      = "approval"
+	EntryModel         = "model"
+	EntryUsage         = "usage"
+	EntryError         = "error"
+)
+
+// Entry is one line of a transcript file.
+type Entry struct {
+	Time       time.Time      `json:"time"`
+	Type       string         `json:"type"`
+	Content    string         `json:"content,omitempty"`
+	Tool       string         `json:"tool,omitempty"`
+	Label      string         `json:"label,omitempty"`
+	Args       map[string]any `json:"args,omitempty"`
+	Status     string         `json:"status,omitempty"`
+	Approved   *bool          `json:"approved,omitempty"`
+	Model      string         `json:"model,omitempty"`
+	ContextPct float64        `json:"context_pct,omitempty"`
+	TotalCost  float64        `json:"total_cost,omitempty"`
+	CWD        string         `json:"cwd,omitempty"`
+}
+
+// DefaultDir returns ~/.bono/<project>/sessions for the given working directory.
+func DefaultDir(cwd string) (string, error) {
+	dir, err := ProjectDir(cwd)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+// ProjectDir returns ~/.bono/<project>, the per-project state directory.
+// The project segment is the absolute cwd with path separators flattened to '-'.
+func ProjectDir(cwd string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".bono", ProjectKey(abs)), nil
+}
+
+// ProjectKey flattens an absolute path into a single directory name.
+func ProjectKey(absPath string) string {
+	key := strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(filepath.ToSlash(absPath))
+	if key == "" {
+		return "-"
+	}
+	return key
+}
+
+// NewID returns a sortable, collision-resistant session ID.
+func NewID(now time.Time) string {
+	var b [3]byte
+	_, _ = rand.Read(b[:])
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+// Store manages transcript files in one sessions directory.
+type Store struct {
+	dir string
+	now func() time.Time
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, now: time.Now}
+}
+
+// Dir returns the sessions directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path returns the transcript file path for a session ID.
+func (s *Store) Path(id string) string {
+	return filepath.Join(s.dir, id+".jsonl")
+}
+
+// Create starts a new transcript and records a session_start entry.
+func (s *Store) Create(cwd string) (*Recorder, error) {
+	rec := &Recorder{store: s}
+	if err := rec.open(NewID(s.now())); err != nil {
+		return nil, err
+	}
+	rec.Record(Entry{Type: EntrySessionStart, CWD: cwd})
+	return rec, nil
+}
+
+// Open loads an existing transcript and returns a recorder that appends to it.
+func (s *Store) Open(id string) (*Recorder, []Entry, error) {
+	entries, err := s.Load(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec := &Recorder{store: s}
+	if err := rec.open(id); err != nil {
+		return nil, nil, err
+	}
+	rec.Record(Entry{Type: EntrySessionResume})
+	return rec, entries, nil
+}
+
+// Load reads every entry of a transcript. Malformed lines are skipped so a
+// transcript truncated by a crash can still be resumed.
+func (s *Store) Load(id string) ([]Entry, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+	f, err := os.Open(s.Path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("session %s not found in %s", id, s.dir)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// Latest returns the ID of the most recently modified transcript.
+func (s *Store) Latest() (string, error) {
+	ids, err := s.List()
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no previous sessions in %s", s.dir)
+	}
+	return ids[0], nil
+}
+
+// List returns session IDs, most recently modified first.
+func (s *Store) List() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	type item struct {
+		id  string
+		mod time.Time
+	}
+	var items []item
+	for _, de := range dirEntries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".jsonl") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		items = append(items, item{id: strings.TrimSuffix(de.Name(), ".jsonl"), mod: info.ModTime()})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].mod.Equal(items[j].mod) {
+			return items[i].id > items[j].id
+		}
+		return items[i].mod.After(items[j].mod)
+	})
+	ids := make([]string, len(items))
+	for i, it := range items {
+		ids[i] = it.id
+	}
+	return ids, nil
+}
+
+// Recorder appends entries to one transcript file. A nil Recorder is a no-op,
+// so callers can wire it unconditionally when persistence is unavailable.
+type Recorder struct {
+	store *Store
+
+	mu  sync.Mutex
+	id  string
+	f   *os.File
+	enc *json.Encoder
+}
+
+// ID returns the current session ID.
+func (r *Recorder) ID() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// Record appends an entry, stamping its time if unset. Write errors are
+// dropped: losing a transcript line must never interrupt the agent.
+func (r *Recorder) Record(e Entry) {
+	if r == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = r.store.now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enc == nil {
+		return
+	}
+	_ = r.enc.Encode(e)
+}
+
+// Rotate closes the current transcript and starts a fresh one (used by /clear).
+func (r *Recorder) Rotate(cwd string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	r.closeLocked()
+	r.mu.Unlock()
+	if err := r.open(NewID(r.store.now())); err != nil {
+		return err
+	}
+	r.Record(Entry{Type: EntrySessionStart, CWD: cwd})
+	return nil
+}
+
+// Close closes the transcript file.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeLocked()
+}
+
+func (r *Recorder) open(id string) error {
+	if err := os.MkdirAll(r.store.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.store.Path(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id = id
+	r.f = f
+	r.enc = enc
+	return nil
+}
+
+func (r *Recorder) closeLocked() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	r.enc = nil
+	return err
+}
+
+// maxResumeChars bounds the replayed context so a long transcript cannot
+// blow the model's context window on the first resumed turn.
+const maxResumeChars = 24000
+
+// trimToLastLines returns the end of s, at most max bytes, starting at a
+// line boundary, or at a rune boundary when the last line alone is longer.
+func trimToLastLines(s string, max int) string {
+	s = s[len(s)-max:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+// ResumePrompt condenses a transcript into a preamble for the first prompt of a
+// resumed session. bono-core owns the agent's message history and exposes no
+// way to inject prior turns, so resume re-primes the model with this summary.
+func ResumePrompt(entries []Entry) string {
+	var lines []string
+	for _, e := range entries {
+		switch e.Type {
+		case EntryUserPrompt:
+			lines = append(lines, "User: "+e.Content)
+		case EntryMessage:
+			if strings.TrimSpace(e.Content) != "" {
+				lines = append(lines, "Assistant: "+e.Content)
+			}
+		case EntryTool:
+			lines = append(lines, fmt.Sprintf("Tool: %s => %s", e.Label, e.Status))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	body := strings.Join(lines, "\n")
+	if len(body) > maxResumeChars {
+		body = "[earlier turns omitted]\n" + trimToLastLines(body, maxResumeChars)
+	}
+	return "This conversation resumes an earlier session. Transcript so far:\n\n" +
+		body + "\n\nContinue from here. New request follows.\n\n"
+}
@@ -0,0 +1,128 @@
+package transcript
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestCreateRecordAndOpenAppends(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	rec, err := store.Create("/work")
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	id := rec.ID()
+	rec.Record(Entry{Type: EntryUserPrompt, Content: "fix the bug"})
+	rec.Record(Entry{Type: EntryMessage, Content: "done"})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, entries, err := store.Open(id)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer reopened.Close()
+	if len(entries) != 3 {
+		t.Fatalf("len(entries) = %d, want 3", len(entries))
+	}
+	if entries[0].Type != EntrySessionStart || entries[0].CWD != "/work" {
+		t.Fatalf("entries[0] = %+v, want session_start for /work", entries[0])
+	}
+	if entries[1].Content != "fix the bug" {
+		t.Fatalf("entries[1].Content = %q, want %q", entries[1].Content, "fix the bug")
+	}
+
+	reopened.Record(Entry{Type: EntryUserPrompt, Content: "again"})
+	all, err := store.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := all[len(all)-1].Content; got != "again" {
+		t.Fatalf("last entry content = %q, want %q", got, "again")
+	}
+}
+
+func TestLatestReturnsMostRecentSession(t *testing.T) {
+	store := NewStore(t.TempDir())
+	first, err := store.Create("/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	second, err := store.Create("/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Close()
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(store.Path(first.ID()), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatalf("Latest returned error: %v", err)
+	}
+	if latest != second.ID() {
+		t.Fatalf("Latest = %q, want %q", latest, second.ID())
+	}
+}
+
+func TestOpenRejectsUnknownAndPathLikeIDs(t *testing.T) {
+	store := NewStore(t.TempDir())
+	for _, id := range []string{"missing", "../escape", ""} {
+		if _, _, err := store.Open(id); err == nil {
+			t.Fatalf("Open(%q) error = nil, want non-nil", id)
+		}
+	}
+}
+
+func TestResumePromptSummarizesConversation(t *testing.T) {
+	prompt := ResumePrompt([]Entry{
+		{Type: EntrySessionStart},
+		{Type: EntryUserPrompt, Content: "add a flag"},
+		{Type: EntryTool, Label: "Edit('main.go')", Status: "success"},
+		{Type: EntryMessage, Content: "Added --verbose."},
+	})
+	for _, want := range []string{"User: add a flag", "Tool: Edit('main.go') => success", "Assistant: Added --verbose."} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("ResumePrompt missing %q in %q", want, prompt)
+		}
+	}
+	if ResumePrompt([]Entry{{Type: EntrySessionStart}}) != "" {
+		t.Fatalf("ResumePrompt for empty conversation should be empty")
+	}
+}
+
+func TestResumePromptTruncatesOnBoundaries(t *testing.T) {
+	long := strings.Repeat("héllo wörld ", maxResumeChars/8)
+	prompt := ResumePrompt([]Entry{
+		{Type: EntryUserPrompt, Content: "first"},
+		{Type: EntryMessage, Content: long},
+		{Type: EntryUserPrompt, Content: "last"},
+	})
+	if !utf8.ValidString(prompt) {
+		t.Fatal("ResumePrompt produced invalid UTF-8")
+	}
+	if !strings.Contains(prompt, "[earlier turns omitted]\nUser: last") || strings.Contains(prompt, "first") {
+		t.Errorf("want only whole trailing lines, got %d bytes starting %q", len(prompt), prompt[:120])
+	}
+
+	// A single over-long line is cut on a rune boundary.
+	prompt = ResumePrompt([]Entry{{Type: EntryUserPrompt, Content: strings.Repeat("ü", maxResumeChars)}})
+	if !utf8.ValidString(prompt) {
+		t.Error("ResumePrompt split a rune")
+	}
+}
+
+func TestProjectKeyFlattensPath(t *testing.T) {
+	if got := ProjectKey("/home/me/src/app"); got != "-home-me-src-app" {
+		t.Fatalf("ProjectKey = %q, want %q", got, "-home-me-src-app")
+	}
+}
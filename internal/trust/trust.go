@@ -0,0 +1,160 @@
+// Package trust remembers which folders the user trusts to supply project
+// settings. A project's .env, .bono/config.toml, .bono/hooks.json,
+// .bono/models.json and .bono/permissions can point bono at another endpoint,
+// read its API key, run commands and approve tool calls, so they are ignored
+// until the user trusts the folder.
+//
+// Decisions live in a plain-text file, one per line:
+//
+//	trust  /home/me/src/app
+//	deny   /home/me/Downloads
+//
+// The decision for the nearest enclosing folder applies, so trusting a
+// directory trusts everything below it.
+package trust
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decision is the user's answer for a folder.
+type Decision string
+
+const (
+	// Unknown means the user has not been asked.
+	Unknown Decision = ""
+	Trusted Decision = "trust"
+	Denied  Decision = "deny"
+)
+
+// File returns the decisions file ($XDG_CONFIG_HOME/bono/trusted_folders,
+// defaulting to ~/.config/bono/trusted_folders).
+func File() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bono", "trusted_folders"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bono", "trusted_folders"), nil
+}
+
+type entry struct {
+	decision Decision
+	dir      string
+}
+
+// Lookup returns the decision recorded for dir or its nearest enclosing
+// folder.
+func Lookup(dir string) (Decision, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Unknown, err
+	}
+	entries, _, err := read()
+	if err != nil {
+		return Unknown, err
+	}
+	best, decision := -1, Unknown
+	for _, e := range entries {
+		if contains(e.dir, dir) && len(e.dir) > best {
+			best, decision = len(e.dir), e.decision
+		}
+	}
+	return decision, nil
+}
+
+// Set records decision for dir, replacing any earlier decision for the same
+// folder. Unknown removes it, so the user is asked again.
+func Set(dir string, decision Decision) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	entries, file, err := read()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("# bono folder trust: trust|deny <folder>\n")
+	for _, e := range entries {
+		if e.dir != dir {
+			fmt.Fprintf(&b, "%s %s\n", e.decision, e.dir)
+		}
+	}
+	if decision != Unknown {
+		fmt.Fprintf(&b, "%s %s\n", decision, dir)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func read() ([]entry, string, error) {
+	file, err := File()
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, file, nil
+	}
+	if err != nil {
+		return nil, file, err
+	}
+	defer f.Close()
+
+	var entries []entry
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, dir, _ := strings.Cut(line, " ")
+		dir = strings.TrimSpace(dir)
+		decision := Decision(word)
+		if (decision != Trusted && decision != Denied) || !filepath.IsAbs(dir) {
+			return nil, file, fmt.Errorf("%s:%d: want \"trust <folder>\" or \"deny <folder>\"", file, n)
+		}
+		entries = append(entries, entry{decision: decision, dir: filepath.Clean(dir)})
+	}
+	return entries, file, s.Err()
+}
+
+// contains reports whether dir is parent or below it.
+func contains(parent, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Ask asks whether to trust dir, listing the project files that would load,
+// and returns the answer. Anything but yes is Denied.
+func Ask(in io.Reader, out io.Writer, dir string, files []string) (Decision, error) {
+	fmt.Fprintf(out, "Do you trust the files in %s?\n", dir)
+	fmt.Fprintf(out, "Bono will load %s, which can change the model endpoint, read its API key and run commands.\n", strings.Join(files, ", "))
+	fmt.Fprint(out, "Trust this folder? [y/N] ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return Unknown, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return Trusted, nil
+	}
+	return Denied, nil
+}
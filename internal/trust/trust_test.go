@@ -0,0 +1,74 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetAndLookupNearestFolder(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	app := filepath.Join(root, "src", "app")
+	vendor := filepath.Join(app, "vendor")
+
+	if d, err := Lookup(app); err != nil || d != Unknown {
+		t.Fatalf("Lookup before Set = %q, %v", d, err)
+	}
+	if err := Set(root, Trusted); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(vendor, Denied); err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]Decision{
+		root:                         Trusted,
+		app:                          Trusted,
+		vendor:                       Denied,
+		filepath.Join(vendor, "lib"): Denied,
+		root + "-other":              Unknown,
+	}
+	for dir, want := range checks {
+		if d, err := Lookup(dir); err != nil || d != want {
+			t.Errorf("Lookup(%s) = %q, %v; want %q", dir, d, err, want)
+		}
+	}
+
+	if err := Set(vendor, Unknown); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := Lookup(vendor); d != Trusted {
+		t.Errorf("after reset, Lookup(vendor) = %q, want the parent's decision", d)
+	}
+}
+
+func TestMalformedFileNamesLine(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	file, _ := File()
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("# header\nmaybe /tmp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lookup(t.TempDir()); err == nil || !strings.Contains(err.Error(), file+":2") {
+		t.Fatalf("err = %v, want %s:2", err, file)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	for answer, want := range map[string]Decision{"y\n": Trusted, "YES\n": Trusted, "\n": Denied, "no\n": Denied} {
+		var out strings.Builder
+		d, err := Ask(strings.NewReader(answer), &out, "/src/app", []string{".env"})
+		if err != nil || d != want {
+			t.Errorf("Ask(%q) = %q, %v; want %q", answer, d, err, want)
+		}
+		if !strings.Contains(out.String(), "/src/app") || !strings.Contains(out.String(), ".env") {
+			t.Errorf("prompt does not name the folder and files: %q", out.String())
+		}
+	}
+	if _, err := Ask(strings.NewReader(""), &strings.Builder{}, "/src/app", nil); err == nil {
+		t.Error("Ask at EOF: error = nil")
+	}
+}
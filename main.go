@@ -1,14 +1,16 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -17,8 +19,12 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	core "github.com/webforspeed/bono-core"
 	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/config"
 	"github.com/webforspeed/bono/internal/logging"
+	"github.com/webforspeed/bono/internal/permissions"
 	"github.com/webforspeed/bono/internal/session"
+	"github.com/webforspeed/bono/internal/transcript"
 	"github.com/webforspeed/bono/prompts"
 	"github.com/webforspeed/bono/tui"
 )
@@ -30,15 +36,96 @@ const updateCheckTimeout = 3 * time.Second
 // version is set at build time via -ldflags "-X main.version=vX.Y.Z".
 var version = "dev"
 
+// Headless output formats accepted by --output-format.
+const (
+	outputFormatText       = "text"
+	outputFormatJSON       = "json"
+	outputFormatStreamJSON = "stream-json"
+)
+
 type cliOptions struct {
 	Prompt        string
 	SkipApprovals bool
+	OutputFormat  string
+	Resume        string
+	Continue      bool
+	History       bool
+	Undo          bool
+	UndoBatch     int // 0 undoes the latest batch that is still applied
+	PatchOut      string
+	Model         string
+	Profile       string
+	Set           []string // key=value config overrides
+}
+
+// ConfigFlags returns the config overrides given by --profile, --model and --set.
+func (o cliOptions) ConfigFlags() []config.Flag {
+	var flags []config.Flag
+	if o.Profile != "" {
+		flags = append(flags, config.Flag{Name: "--profile", Key: "profile", Value: o.Profile})
+	}
+	if o.Model != "" {
+		flags = append(flags, config.Flag{Name: "--model", Key: "model", Value: o.Model})
+	}
+	for _, kv := range o.Set {
+		key, value, _ := strings.Cut(kv, "=")
+		flags = append(flags, config.Flag{Name: "--set", Key: strings.TrimSpace(key), Value: value})
+	}
+	return flags
+}
+
+// setValue collects repeated --set key=value flags.
+type setValue struct{ opts *cliOptions }
+
+func (v setValue) String() string {
+	if v.opts == nil {
+		return ""
+	}
+	return strings.Join(v.opts.Set, ",")
+}
+
+func (v setValue) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("want key=value")
+	}
+	v.opts.Set = append(v.opts.Set, s)
+	return nil
+}
+
+// undoValue lets --undo work both bare and as --undo=<n>.
+type undoValue struct{ opts *cliOptions }
+
+func (v undoValue) IsBoolFlag() bool { return true }
+
+func (v undoValue) String() string {
+	if v.opts == nil || !v.opts.Undo {
+		return ""
+	}
+	return strconv.Itoa(v.opts.UndoBatch)
+}
+
+func (v undoValue) Set(s string) error {
+	if s == "true" {
+		v.opts.Undo, v.opts.UndoBatch = true, 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("want a batch number from --history")
+	}
+	v.opts.Undo, v.opts.UndoBatch = true, n
+	return nil
 }
 
 func (o cliOptions) Headless() bool {
 	return strings.TrimSpace(o.Prompt) != ""
 }
 
+// JSONOutput reports whether headless output is machine-readable JSON.
+func (o cliOptions) JSONOutput() bool {
+	return o.OutputFormat == outputFormatJSON || o.OutputFormat == outputFormatStreamJSON
+}
+
 func parseCLIArgs(args []string) (cliOptions, error) {
 	var opts cliOptions
 
@@ -47,164 +134,170 @@ func parseCLIArgs(args []string) (cliOptions, error) {
 	fs.StringVar(&opts.Prompt, "p", "", "run a single prompt in headless mode")
 	fs.StringVar(&opts.Prompt, "prompt", "", "run a single prompt in headless mode")
 	fs.BoolVar(&opts.SkipApprovals, "skip-approvals", false, "skip all approval prompts and execution limits")
+	fs.StringVar(&opts.OutputFormat, "output-format", outputFormatText, "headless output format: text, json, or stream-json")
+	fs.StringVar(&opts.Resume, "resume", "", "resume a recorded session by id (text-only: the transcript is summarised into the next prompt)")
+	fs.BoolVar(&opts.Continue, "continue", false, "resume the most recent session in this project (text-only, like --resume)")
+	fs.BoolVar(&opts.History, "history", false, "list approved change batches for this project")
+	fs.Var(undoValue{&opts}, "undo", "undo the latest change batch, or batch n with --undo=<n>")
+	fs.StringVar(&opts.PatchOut, "patch-out", "", "write the proposed change batch to this file as a patch (requires -p)")
+	addConfigFlags(fs, &opts)
 
 	if err := fs.Parse(args); err != nil {
 		return cliOptions{}, err
 	}
 	if fs.NArg() > 0 {
-		return cliOptions{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+		return cliOptions{}, fmt.Errorf("unknown command %q (run bono help)", fs.Arg(0))
+	}
+	switch opts.OutputFormat {
+	case outputFormatText, outputFormatJSON, outputFormatStreamJSON:
+	default:
+		return cliOptions{}, fmt.Errorf("invalid --output-format %q (want text, json, or stream-json)", opts.OutputFormat)
+	}
+	if opts.JSONOutput() && !opts.Headless() {
+		return cliOptions{}, fmt.Errorf("--output-format %s requires -p", opts.OutputFormat)
+	}
+	if opts.Continue && strings.TrimSpace(opts.Resume) != "" {
+		return cliOptions{}, fmt.Errorf("--resume and --continue are mutually exclusive")
+	}
+	if opts.PatchOut != "" && !opts.Headless() {
+		return cliOptions{}, fmt.Errorf("--patch-out requires -p")
+	}
+	if opts.History && opts.Undo {
+		return cliOptions{}, fmt.Errorf("--history and --undo are mutually exclusive")
+	}
+	if (opts.History || opts.Undo) && opts.Headless() {
+		return cliOptions{}, fmt.Errorf("--history and --undo cannot be combined with -p")
 	}
 	return opts, nil
 }
 
+// addConfigFlags defines the flags that override config.toml values.
+func addConfigFlags(fs *flag.FlagSet, opts *cliOptions) {
+	fs.StringVar(&opts.Profile, "profile", "", "config profile to use, overriding config and BONO_PROFILE")
+	fs.StringVar(&opts.Model, "model", "", "model to use, overriding config and MODEL")
+	fs.Var(setValue{opts}, "set", "override a config key, as key=value (repeatable)")
+}
+
 func main() {
-	loadEnv()
+	if cmd, ok := lookupCommand(os.Args[1:]); ok {
+		os.Exit(cmd.Run(os.Stdout, os.Args[2:]))
+	}
 	opts, err := parseCLIArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(runHelpCommand(os.Stdout, nil))
+	}
 	if err != nil {
 		fmt.Printf("Error parsing arguments: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Keep stdout clean for JSON consumers; diagnostics go to stderr instead.
+	var diagOut io.Writer = os.Stdout
+	if opts.JSONOutput() {
+		diagOut = os.Stderr
+	}
+
 	cwd, _ := os.Getwd()
 	if cwd == "" {
 		cwd = "."
 	}
-	username := os.Getenv("USER")
-	if username == "" {
-		username = os.Getenv("LOGNAME")
+	if opts.History || opts.Undo {
+		os.Exit(runHistoryCommand(os.Stdout, openHistory(cwd), opts))
 	}
-
-	promptCtx := prompts.PromptContext{
-		HostContext: prompts.HostContext{
-			CWD:      cwd,
-			OS:       runtime.GOOS,
-			Arch:     runtime.GOARCH,
-			Username: username,
-			DateTime: time.Now().Format("2006-01-02 15:04:05 MST"),
-		},
-		Identity: prompts.AgentIdentity{
-			Role:     "coding",
-			Platform: "terminal",
-		},
+	proj, err := openProject(cwd, !opts.JSONOutput() && isTerminal(os.Stdin), diagOut)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading project: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := config.Load(cwd, proj.configOptions(opts.ConfigFlags()))
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
 
-	systemPrompt, err := prompts.LoadSystemPromptVersion(promptCtx, systemPromptVersion)
+	systemPrompt, err := loadSystemPrompt(cwd, promptVersion(cfg))
 	if err != nil {
-		fmt.Printf("Error loading system prompt version %q: %v\n", systemPromptVersion, err)
+		fmt.Fprintf(diagOut, "Error loading system prompt version %q: %v\n", promptVersion(cfg), err)
 		os.Exit(1)
 	}
 
-	// Load model catalog from code, merging remote and local (Ollama) models.
+	// Load the model catalog from its data files, merging remote models and
+	// those discovered on local providers.
 	ctx := context.Background()
-	models := tui.LoadModelCatalog(ctx)
-
-	// Model priority: MODEL env var > openrouter/free (if API key set) > first local model > bono-core default
-	model := os.Getenv("MODEL")
-	if model == "" {
-		apiKey := os.Getenv("OPENROUTER_API_KEY")
-		if apiKey != "" {
-			model = "openrouter/free"
-		} else {
-			for _, m := range models {
-				if m.IsLocal {
-					model = m.ID
-					break
-				}
-			}
-			if model == "" {
-				model = "openrouter/free"
-			}
-		}
+	catalog, err := catalogOptions(proj, cfg)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
-	embeddingDims := 0
-	if n := os.Getenv("EMBEDDING_DIMS"); n != "" {
-		if v, err := strconv.Atoi(n); err == nil && v > 0 {
-			embeddingDims = v
-		}
+	models, providers, err := tui.LoadModelCatalog(ctx, catalog)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading model catalog: %v\n", err)
+		os.Exit(1)
 	}
 
-	config := core.Config{
-		APIKey:       os.Getenv("OPENROUTER_API_KEY"),
-		BaseURL:      os.Getenv("BASE_URL"),
-		Model:        model,
-		SystemPrompt: systemPrompt,
-		HTTPTimeout:  120 * time.Second,
-		Sandbox: core.SandboxConfig{
-			CommandTimeout: 30 * time.Second,
-		},
-		CodeSearch: &core.CodeSearchConfig{
-			DBPath: ".bono/index.db",
-			Model:  os.Getenv("EMBEDDING_MODEL"),
-			Dims:   embeddingDims,
-		},
-		Web: &core.WebConfig{
-			Model:        envOr("WEB_ANSWER_MODEL", "perplexity/sonar"),
-			SearchEngine: envOr("WEB_SEARCH_ENGINE", "exa"),
-		},
-	}
-	config.ShellPolicy = core.DefaultShellPolicy()
-	if n := os.Getenv("API_TIMEOUT_SEC"); n != "" {
-		if v, err := strconv.Atoi(n); err == nil && v > 0 {
-			config.HTTPTimeout = time.Duration(v) * time.Second
+	model := resolveModel(cfg, models)
+	for _, m := range models {
+		if m.ID == model && !m.SupportsTools() {
+			fmt.Fprintf(diagOut, "Warning: %s does not support tool calls; it cannot read, search or edit files\n", model)
+			break
 		}
 	}
-	if n := os.Getenv("SHELL_TIMEOUT_SEC"); n != "" {
-		if v, err := strconv.Atoi(n); err == nil && v > 0 {
-			config.Sandbox.CommandTimeout = time.Duration(v) * time.Second
-		}
-	}
-	if n := os.Getenv("MAX_TOOL_CALLS_PER_TURN"); n != "" {
-		if v, err := strconv.Atoi(n); err == nil && v >= 0 {
-			config.MaxToolCallsPerTurn = v
-		}
-	} else {
-		config.MaxToolCallsPerTurn = 100
-	}
-	config.MaxChatTurns = 100
-	config.MaxPreTaskTurns = 100
-	config.MaxSubAgentTurns = 100
+	coreConfig := cfg.Core(model, systemPrompt)
 	if opts.SkipApprovals {
-		config.DisableLimits = true
-		config.MaxToolCallsPerTurn = 0
-		config.MaxChatTurns = 0
-		config.MaxPreTaskTurns = 0
-		config.MaxSubAgentTurns = 0
-		config.HTTPTimeout = 0
-		config.Sandbox.CommandTimeout = -1
+		coreConfig.DisableLimits = true
+		coreConfig.MaxToolCallsPerTurn = 0
+		coreConfig.MaxChatTurns = 0
+		coreConfig.MaxPreTaskTurns = 0
+		coreConfig.MaxSubAgentTurns = 0
+		coreConfig.HTTPTimeout = 0
+		coreConfig.Sandbox.CommandTimeout = -1
 	}
 
 	// Create agent
-	agent, err := core.NewAgent(config)
+	agent, err := core.NewAgent(coreConfig)
 	if err != nil {
-		fmt.Printf("Error creating agent: %v\n", err)
+		fmt.Fprintf(diagOut, "Error creating agent: %v\n", err)
 		os.Exit(1)
 	}
+	if cfg.Reasoning != "" {
+		agent.SetReasoningEffort(reasoningEffort(cfg))
+	}
 	defer func() {
 		if err := agent.Close(); err != nil {
-			fmt.Printf("Warning: failed to close agent resources: %v\n", err)
+			fmt.Fprintf(diagOut, "Warning: failed to close agent resources: %v\n", err)
 		}
 	}()
 
 	// Set up structured logging and hook dispatcher
-	logger, closeLog, logErr := logging.New("logs/bono.jsonl")
-	if logErr != nil {
-		fmt.Printf("Warning: structured logging unavailable: %v\n", logErr)
+	logger, closeLog := openLogger(cfg.Log.Path, diagOut)
+	defer closeLog()
+	dispatcher, flushWebhooks, err := newDispatcher(proj, logger, diagOut)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading hooks: %v\n", err)
+		os.Exit(1)
 	}
-	if closeLog != nil {
-		defer closeLog()
+	defer flushWebhooks()
+
+	// Record the session transcript so it can be resumed later.
+	rec, history, err := openTranscript(cwd, opts)
+	if err != nil {
+		if opts.Continue || opts.Resume != "" {
+			fmt.Fprintf(diagOut, "Error resuming session: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(diagOut, "Warning: session transcript unavailable: %v\n", err)
 	}
+	defer rec.Close()
+	dispatcher.On(hooks.UserPromptSubmit, session.TranscriptHandler(rec))
+	dispatcher.SetSession(hooks.SessionInfo{CWD: cwd, SessionID: rec.ID, Model: agent.ModelName})
 
-	dispatcher := hooks.NewDispatcher()
-	if logger != nil {
-		logHandler := hooks.NewLogHandler(logger)
-		dispatcher.On(hooks.SessionStart, logHandler)
-		dispatcher.On(hooks.SessionEnd, logHandler)
-		dispatcher.On(hooks.UserPromptSubmit, logHandler)
-		dispatcher.On(hooks.PreToolUse, logHandler)
-		dispatcher.On(hooks.PostToolUse, logHandler)
-		dispatcher.On(hooks.PostToolUseFailure, logHandler)
-		dispatcher.On(hooks.PermissionRequest, logHandler)
-		dispatcher.On(hooks.Stop, logHandler)
+	// Load allow/ask/deny rules. A broken policy file is fatal: silently
+	// dropping a deny rule would be worse than not starting. An untrusted
+	// project's policy could allow anything, so only the user's applies.
+	policy, err := permissions.Load(cwd, proj.trusted)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Error loading permissions: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Create context
@@ -212,12 +305,12 @@ func main() {
 	defer cancel()
 
 	if err := agent.CodeSearchInitError(); err != nil {
-		fmt.Printf("Warning: code search unavailable: %v\n", err)
+		fmt.Fprintf(diagOut, "Warning: code search unavailable: %v (run bono doctor)\n", err)
 	} else if svc := agent.CodeSearchService(); svc != nil && !svc.CodeSearchSupportsVector() {
-		fmt.Println("Warning: sqlite-vec unavailable; code search is running in text-only mode.")
+		fmt.Fprintln(diagOut, "Warning: sqlite-vec unavailable; code search is running in text-only mode (run bono doctor).")
 	}
 	if err := agent.WebInitError(); err != nil {
-		fmt.Printf("Warning: web tools unavailable: %v\n", err)
+		fmt.Fprintf(diagOut, "Warning: web tools unavailable: %v\n", err)
 	}
 
 	// Warm model limits in background so context usage shows from the first response.
@@ -228,41 +321,84 @@ func main() {
 	}()
 
 	if opts.Headless() {
-		if err := runHeadless(ctx, cwd, config, agent, dispatcher, opts); err != nil {
+		if err := runHeadless(ctx, cwd, coreConfig, fallbackPolicy(cfg, models), roleRoutes(cfg, models), agent, dispatcher, rec, history, policy, opts); err != nil {
+			flushWebhooks()
 			os.Exit(1)
 		}
 		return
 	}
 
-	if err := runTUI(ctx, cwd, version, models, config, agent, dispatcher, opts); err != nil {
+	if err := runTUI(ctx, proj, version, models, providers, cfg, coreConfig, fallbackPolicy(cfg, models), roleRoutes(cfg, models), agent, dispatcher, rec, history, policy, opts); err != nil {
 		fmt.Printf("Error running TUI: %v\n", err)
+		flushWebhooks()
 		os.Exit(1)
 	}
 }
 
-func runHeadless(ctx context.Context, cwd string, config core.Config, agent *core.Agent, dispatcher *hooks.Dispatcher, opts cliOptions) error {
+func runHeadless(ctx context.Context, cwd string, config core.Config, fallback session.Fallback, roles session.Roles, agent *core.Agent, dispatcher *hooks.Dispatcher, rec *transcript.Recorder, history []transcript.Entry, policy *permissions.Policy, opts cliOptions) error {
+	changeLog := openHistory(cwd)
+	var base session.SessionFrontend
+	var jsonFrontend *session.JSONFrontend
+	if opts.JSONOutput() {
+		jsonFrontend = session.NewJSONFrontend(os.Stdout, os.Stdin, opts.OutputFormat == outputFormatStreamJSON)
+		jsonFrontend.SetSessionID(rec.ID())
+		base = jsonFrontend
+	} else {
+		base = session.NewHeadlessFrontend(os.Stdout, os.Stdin)
+	}
 	frontend := session.Chain(
-		session.NewHeadlessFrontend(os.Stdout, os.Stdin),
+		base,
 		session.SynchronizedMiddleware(),
+		session.RecordingMiddleware(rec),
+		session.PolicyMiddleware(policy),
 	)
 	sess := session.New(agent, dispatcher, session.Config{
 		CWD:           cwd,
 		ShellPolicy:   config.ShellPolicy,
 		SkipApprovals: opts.SkipApprovals,
+		ResumeContext: transcript.ResumePrompt(history),
+		ChangeLog:     changeLog,
+		PatchOut:      opts.PatchOut,
+		Fallback:      fallback,
+		Roles:         roles,
 	}, frontend)
+	dispatcher.On(hooks.UserPromptSubmit, sess.PromptHandler())
 	dispatcher.On(hooks.Stop, sess.StopHandler())
 	sess.Bind(ctx)
-	_, err := sess.RunPrompt(ctx, opts.Prompt)
+
+	// Report hook errors while the prompt runs, then pick up any left by
+	// async handlers drained at SessionEnd before the final result.
+	reportCtx, stopReporting := context.WithCancel(ctx)
+	reported := make(chan struct{})
+	go func() {
+		sess.ReportHookErrors(reportCtx)
+		close(reported)
+	}()
+	response, err := sess.RunPrompt(ctx, opts.Prompt)
+	stopReporting()
+	<-reported
+	sess.FlushHookErrors(ctx)
+	if jsonFrontend != nil {
+		jsonFrontend.Finish(response, err)
+	}
 	return err
 }
 
-func runTUI(ctx context.Context, cwd, version string, models []tui.ModelInfo, config core.Config, agent *core.Agent, dispatcher *hooks.Dispatcher, opts cliOptions) error {
+func runTUI(ctx context.Context, proj project, version string, models []tui.ModelInfo, providers []tui.ProviderStatus, cfg *config.Config, coreConfig core.Config, fallback session.Fallback, roles session.Roles, agent *core.Agent, dispatcher *hooks.Dispatcher, rec *transcript.Recorder, history []transcript.Entry, policy *permissions.Policy, opts cliOptions) error {
 	tuiModel := tui.NewWithOptions(agent, ctx, tui.SpinnerDot, models)
 	tuiModel.SetStatusBarText(tui.StatusBarText(version))
 	tuiModel.SetDispatcher(dispatcher)
+	tuiModel.SetPermissions(policy)
+	tuiModel.SetLocalProviders(providers)
+	changeLog := openHistory(proj.dir)
+	tuiModel.SetChangeHistory(changeLog)
+	if len(history) > 0 {
+		tuiModel.ReplayTranscript(history)
+		tuiModel.SetResumeContext(transcript.ResumePrompt(history))
+	}
 
 	var watcher *tui.FileWatcher
-	if w, err := tui.NewFileWatcher(cwd); err == nil {
+	if w, err := tui.NewFileWatcher(proj.dir); err == nil {
 		watcher = w
 		tuiModel.SetWatcher(watcher)
 	}
@@ -287,46 +423,354 @@ func runTUI(ctx context.Context, cwd, version string, models []tui.ModelInfo, co
 	frontend := session.Chain(
 		tui.NewSessionFrontend(p),
 		session.SynchronizedMiddleware(),
+		session.RecordingMiddleware(rec),
+		session.PolicyMiddleware(policy),
 	)
 	sess := session.New(agent, dispatcher, session.Config{
-		CWD:           cwd,
-		ShellPolicy:   config.ShellPolicy,
+		CWD:           proj.dir,
+		ShellPolicy:   coreConfig.ShellPolicy,
 		SkipApprovals: opts.SkipApprovals,
+		ChangeLog:     changeLog,
+		Fallback:      fallback,
+		Roles:         roles,
 	}, frontend)
+	dispatcher.On(hooks.UserPromptSubmit, sess.PromptHandler())
 	dispatcher.On(hooks.Stop, sess.StopHandler())
-	tuiModel.SetOnSessionClear(sess.Reset)
+	tuiModel.SetChat(sess.Chat)
+	tuiModel.SetProfiles(cfg.Profiles(), cfg.Profile, func(name string) (tui.Profile, error) {
+		return loadProfile(proj, cfg, models, opts, name, func(next *config.Config) {
+			sess.SetRouting(fallbackPolicy(next, models), roleRoutes(next, models))
+		})
+	})
+	tuiModel.SetOnSessionClear(func() {
+		sess.Reset()
+		_ = rec.Rotate(proj.dir)
+	})
 	sess.Bind(ctx)
+	go sess.ReportHookErrors(ctx)
 
 	dispatcher.Fire(ctx, hooks.SessionStart, hooks.SessionStartPayload{})
 	defer dispatcher.Fire(ctx, hooks.SessionEnd, hooks.SessionEndPayload{})
 
 	_, err := p.Run()
+	if id := rec.ID(); id != "" {
+		fmt.Printf("Session saved. Resume with: bono --resume %s\n", id)
+	}
 	return err
 }
 
-func envOr(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// fallbackPolicy builds the retry and fallback policy from cfg. Fallback
+// models in the catalog talk to their own base URL, so a chain can end on
+// a local model.
+func fallbackPolicy(cfg *config.Config, models []tui.ModelInfo) session.Fallback {
+	var chain []string
+	for _, id := range strings.Split(cfg.Fallback.Models, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chain = append(chain, id)
+		}
+	}
+	return session.Fallback{
+		Models:  chain,
+		Retries: cfg.Fallback.Retries,
+		Backoff: cfg.Fallback.Backoff,
+		BaseURL: func(id string) string {
+			return modelBaseURL(cfg, models, id)
+		},
+	}
+}
+
+// roleRoutes builds the per-role models and reasoning efforts from cfg.
+func roleRoutes(cfg *config.Config, models []tui.ModelInfo) session.Roles {
+	routes := map[session.Role]session.Route{}
+	for role, r := range map[session.Role]config.Role{
+		session.RolePlan:       cfg.Roles.Plan,
+		session.RoleExplore:    cfg.Roles.Explore,
+		session.RoleCompaction: cfg.Roles.Compaction,
+	} {
+		if r.Model != "" || r.Reasoning != "" {
+			routes[role] = session.Route{Model: r.Model, Reasoning: r.Reasoning}
+		}
+	}
+	return session.Roles{
+		Routes: routes,
+		BaseURL: func(id string) string {
+			return modelBaseURL(cfg, models, id)
+		},
 	}
-	return fallback
 }
 
-func loadEnv() {
-	f, err := os.Open(".env")
+// modelBaseURL returns the endpoint for model id: a local model's own
+// server, else the configured base URL, else the catalog entry's, else
+// OpenRouter.
+func modelBaseURL(cfg *config.Config, models []tui.ModelInfo, id string) string {
+	var info *tui.ModelInfo
+	for i := range models {
+		if models[i].ID == id {
+			info = &models[i]
+			break
+		}
+	}
+	switch {
+	case info != nil && info.IsLocal && info.BaseURL != "":
+		return info.BaseURL
+	case cfg.BaseURL != "":
+		return cfg.BaseURL
+	case info != nil && info.BaseURL != "":
+		return info.BaseURL
+	}
+	return tui.OpenRouterBaseURL
+}
+
+// loadProfile reloads the config with profile name selected, for /profile.
+// The profile's model, base URL and reasoning go to the running agent, and
+// its fallback chain and role routes to apply. Other settings are fixed when
+// the agent starts, so a profile that changes them is refused.
+func loadProfile(proj project, current *config.Config, models []tui.ModelInfo, opts cliOptions, name string, apply func(next *config.Config)) (tui.Profile, error) {
+	next, err := config.Load(proj.dir, proj.configOptions(append(opts.ConfigFlags(), config.Flag{Name: "/profile", Key: "profile", Value: name})))
 	if err != nil {
-		return
+		return tui.Profile{}, err
+	}
+	var restart []string
+	for _, key := range current.Diff(next) {
+		switch {
+		case key == "profile", key == "model", key == "base_url", key == "reasoning":
+		case strings.HasPrefix(key, "fallback."), strings.HasPrefix(key, "roles."):
+		default:
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		return tui.Profile{}, fmt.Errorf("profile %s changes %s, which only apply at startup; restart with bono --profile %s", name, strings.Join(restart, ", "), name)
+	}
+
+	model := resolveModel(next, models)
+	baseURL := next.BaseURL
+	if baseURL == "" {
+		baseURL = tui.OpenRouterBaseURL
+		for _, m := range models {
+			if m.ID == model && m.BaseURL != "" {
+				baseURL = m.BaseURL
+				break
+			}
+		}
+	}
+	p := tui.Profile{Name: name, Model: model, BaseURL: baseURL, Reasoning: next.Reasoning}
+	if apply != nil {
+		p.Apply = func() { apply(next) }
+	}
+	return p, nil
+}
+
+// openTranscript starts a new session transcript, or reopens an earlier one
+// when --resume/--continue is set and returns its entries for replay.
+func openTranscript(cwd string, opts cliOptions) (*transcript.Recorder, []transcript.Entry, error) {
+	dir, err := transcript.DefaultDir(cwd)
+	if err != nil {
+		return nil, nil, err
+	}
+	store := transcript.NewStore(dir)
+
+	id := strings.TrimSpace(opts.Resume)
+	if opts.Continue {
+		if id, err = store.Latest(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if id == "" {
+		rec, err := store.Create(cwd)
+		return rec, nil, err
+	}
+	return store.Open(id)
+}
+
+// openHistory returns the project's change history, or nil if there is no
+// home directory to keep it in.
+func openHistory(cwd string) *changebatch.History {
+	dir, err := transcript.ProjectDir(cwd)
+	if err != nil {
+		return nil
+	}
+	return changebatch.NewHistory(filepath.Join(dir, "history"))
+}
+
+// promptVersion returns the configured system prompt version, defaulting to
+// systemPromptVersion.
+func promptVersion(cfg *config.Config) string {
+	if cfg.PromptVersion != "" {
+		return cfg.PromptVersion
+	}
+	return systemPromptVersion
+}
+
+// reasoningEffort maps the reasoning config value to the agent's: "none"
+// disables reasoning like /reasoning none does.
+func reasoningEffort(cfg *config.Config) string {
+	if cfg.Reasoning == "none" {
+		return ""
+	}
+	return cfg.Reasoning
+}
+
+// loadSystemPrompt renders the main agent's system prompt for cwd.
+func loadSystemPrompt(cwd, version string) (string, error) {
+	username := os.Getenv("USER")
+	if username == "" {
+		username = os.Getenv("LOGNAME")
+	}
+	promptCtx := prompts.PromptContext{
+		HostContext: prompts.HostContext{
+			CWD:      cwd,
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			Username: username,
+			DateTime: time.Now().Format("2006-01-02 15:04:05 MST"),
+		},
+		Identity: prompts.AgentIdentity{
+			Role:     "coding",
+			Platform: "terminal",
+		},
 	}
-	defer f.Close()
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	return prompts.LoadSystemPromptVersion(promptCtx, version)
+}
+
+// catalogOptions returns where the model catalog is read from: the user and,
+// in a trusted folder, the project models.json, the OpenRouter refresh
+// settings and the local providers to discover.
+func catalogOptions(proj project, cfg *config.Config) (tui.CatalogOptions, error) {
+	providers, err := tui.LocalProviders(cfg.Local.Ollama, cfg.Local.OpenAI)
+	if err != nil {
+		return tui.CatalogOptions{}, fmt.Errorf("local providers: %w", err)
+	}
+	opts := tui.CatalogOptions{Refresh: cfg.Models.Refresh, CacheTTL: cfg.Models.CacheTTL, Providers: providers}
+	if file, err := tui.UserModelsFile(); err == nil {
+		opts.Files = append(opts.Files, file)
+	}
+	if proj.trusted {
+		opts.Files = append(opts.Files, filepath.Join(proj.dir, tui.ProjectModelsFile))
+	}
+	if file, err := tui.ModelCacheFile(); err == nil {
+		opts.CacheFile = file
+	}
+	return opts, nil
+}
+
+// resolveModel picks the startup model. Priority: config (flag > env > files)
+// > openrouter/free (if API key set) > first local model that can call tools
+// > first local model > openrouter/free.
+func resolveModel(cfg *config.Config, models []tui.ModelInfo) string {
+	if cfg.Model != "" {
+		return cfg.Model
+	}
+	if cfg.APIKey == "" {
+		local := ""
+		for _, m := range models {
+			if !m.IsLocal {
+				continue
+			}
+			if m.SupportsTools() {
+				return m.ID
+			}
+			if local == "" {
+				local = m.ID
+			}
+		}
+		if local != "" {
+			return local
 		}
-		if k, v, ok := strings.Cut(line, "="); ok {
-			os.Setenv(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return "openrouter/free"
+}
+
+// openLogger opens the structured event log. Failing to open it is only a
+// warning; the returned close function is always safe to call.
+func openLogger(path string, diagOut io.Writer) (*slog.Logger, func()) {
+	logger, closeLog, err := logging.New(path)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Warning: structured logging unavailable: %v\n", err)
+		return nil, func() {}
+	}
+	return logger, func() { _ = closeLog() }
+}
+
+// newDispatcher creates the hook dispatcher with the log handler, command
+// hooks and webhooks registered. flush waits a few seconds for queued webhook
+// deliveries and should run after SessionEnd.
+func newDispatcher(proj project, logger *slog.Logger, diagOut io.Writer) (dispatcher *hooks.Dispatcher, flush func(), err error) {
+	dispatcher = hooks.NewDispatcher()
+	if logger != nil {
+		logHandler := hooks.NewLogHandler(logger)
+		for _, event := range hooks.Events {
+			dispatcher.On(event, logHandler)
+		}
+	}
+
+	// Webhooks, like the log, are registered ahead of command hooks so they
+	// still see an event that a command hook blocks.
+	webhooks, err := hooks.LoadWebhooks(proj.dir, proj.trusted, proj.env.Getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	var webhookHandlers []*hooks.WebhookHandler
+	for _, w := range webhooks {
+		w.Config.Logger = logger
+		handler := hooks.NewWebhookHandler(w.Config)
+		for _, event := range w.Events {
+			dispatcher.On(event, handler)
+		}
+		webhookHandlers = append(webhookHandlers, handler)
+	}
+
+	// Command hooks run before the transcript handler so a prompt rejected
+	// by a hook is never recorded.
+	commandHooks, err := hooks.LoadCommandHooks(proj.dir, proj.trusted)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, h := range commandHooks {
+		dispatcher.Register(h.Event, h, h.Options()...)
+	}
+
+	flush = func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, handler := range webhookHandlers {
+			if err := handler.Close(closeCtx); err != nil {
+				fmt.Fprintf(diagOut, "Warning: webhook deliveries abandoned: %v\n", err)
+			}
+		}
+	}
+	return dispatcher, flush, nil
+}
+
+// runHistoryCommand implements --history and --undo and returns the exit code.
+func runHistoryCommand(out io.Writer, history *changebatch.History, opts cliOptions) int {
+	if opts.Undo {
+		batch, err := history.Undo(opts.UndoBatch)
+		if err != nil {
+			fmt.Fprintf(out, "Error: undo failed: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "● Undone %s\n", batch.Summary())
+		return 0
+	}
+
+	batches, err := history.List()
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	if len(batches) == 0 {
+		fmt.Fprintln(out, "No approved change batches yet.")
+		return 0
+	}
+	for i, b := range batches {
+		line := fmt.Sprintf("%d. %s", i+1, b.Summary())
+		if b.Undone() {
+			line += " [undone]"
 		}
+		fmt.Fprintln(out, line)
 	}
+	return 0
 }
 
 func startUpdateCheck(ctx context.Context, p *tea.Program, currentVersion string) {
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/config"
+	"github.com/webforspeed/bono/internal/dotenv"
+	"github.com/webforspeed/bono/internal/permissions"
+	"github.com/webforspeed/bono/internal/trust"
+	"github.com/webforspeed/bono/tui"
+)
+
+// project is the folder bono runs in and what may be loaded from it.
+type project struct {
+	dir     string
+	files   []string // project files that need trust, relative to dir
+	trust   trust.Decision
+	trusted bool       // the project .env, config, hooks and permissions may load
+	env     dotenv.Env // the project .env; empty unless trusted
+}
+
+// projectFiles lists the files in dir that only load once it is trusted.
+func projectFiles(dir string) []string {
+	var found []string
+	for _, name := range []string{dotenv.FileName, config.ProjectFile, hooks.SettingsFile, tui.ProjectModelsFile, permissions.FileName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// openProject decides whether dir is trusted and reads its .env if so. When
+// ask is set, an undecided folder is asked about on the terminal and the
+// answer remembered; otherwise it stays untrusted and a warning says how to
+// trust it. A folder with no project files needs no decision.
+func openProject(dir string, ask bool, diagOut io.Writer) (project, error) {
+	p := project{dir: dir, files: projectFiles(dir)}
+	if len(p.files) == 0 {
+		p.trusted = true
+		return p, nil
+	}
+
+	decision, err := trust.Lookup(dir)
+	if err != nil {
+		fmt.Fprintf(diagOut, "Warning: folder trust unavailable: %v\n", err)
+		decision = trust.Unknown
+		ask = false
+	}
+	if decision == trust.Unknown && ask {
+		if decision, err = trust.Ask(os.Stdin, diagOut, dir, p.files); err != nil {
+			return p, err
+		}
+		if err := trust.Set(dir, decision); err != nil {
+			fmt.Fprintf(diagOut, "Warning: couldn't remember the answer: %v\n", err)
+		}
+	}
+	p.trust = decision
+	switch decision {
+	case trust.Trusted:
+		p.trusted = true
+	case trust.Unknown:
+		fmt.Fprintf(diagOut, "Warning: ignoring %s in untrusted folder (run bono trust to load them)\n", strings.Join(p.files, ", "))
+	}
+	if !p.trusted {
+		return p, nil
+	}
+
+	if p.env, err = dotenv.Read(filepath.Join(dir, dotenv.FileName)); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// configOptions returns the config.Load options for the project.
+func (p project) configOptions(flags []config.Flag) config.Options {
+	return config.Options{Project: p.trusted, Env: p.env, Flags: flags}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runTrustCommand implements `bono trust`.
+func runTrustCommand(out io.Writer, args []string) int {
+	usage := commandUsage("trust")
+	fs := commandFlags("trust", nil)
+	deny := fs.Bool("deny", false, "never load this folder's project files")
+	reset := fs.Bool("reset", false, "forget the decision so bono asks again")
+	if !parseCommandFlags(out, fs, usage, args) {
+		return 2
+	}
+	if *deny && *reset {
+		fmt.Fprintf(out, "Error: --deny and --reset cannot be combined\nUsage: %s\n", usage)
+		return 2
+	}
+
+	cwd := workingDir()
+	decision, verb := trust.Trusted, "Trusted"
+	switch {
+	case *deny:
+		decision, verb = trust.Denied, "Denied"
+	case *reset:
+		decision, verb = trust.Unknown, "Forgot the decision for"
+	}
+	if err := trust.Set(cwd, decision); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "%s %s\n", verb, cwd)
+	return 0
+}
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/diff"
+)
+
+// startBatchReview resets per-hunk review state for the pending batch and
+// selects its first hunk.
+func (m *Model) startBatchReview() {
+	m.reviewFile, m.reviewHunk = -1, 0
+	for i := range m.diffPreviews {
+		p := m.diffPreviews[i].preview
+		m.diffPreviews[i].reverted = make([]bool, len(diff.Hunks(p.OldContent, p.NewContent)))
+		if m.reviewFile < 0 && len(m.diffPreviews[i].reverted) > 0 {
+			m.reviewFile = i
+		}
+	}
+	m.rerenderDiffPreviews()
+}
+
+// handleBatchReviewKey moves between hunks and marks them keep/revert while
+// a change batch awaits approval. It reports whether the key was consumed.
+func (m *Model) handleBatchReviewKey(msg tea.KeyMsg) bool {
+	if m.reviewFile < 0 || m.reviewFile >= len(m.diffPreviews) {
+		return false
+	}
+	switch {
+	case msg.Type == tea.KeyRunes && string(msg.Runes) == "]":
+		m.moveReviewCursor(1)
+	case msg.Type == tea.KeyRunes && string(msg.Runes) == "[":
+		m.moveReviewCursor(-1)
+	case msg.Type == tea.KeySpace:
+		reverted := m.diffPreviews[m.reviewFile].reverted
+		reverted[m.reviewHunk] = !reverted[m.reviewHunk]
+	case msg.Type == tea.KeyRunes && string(msg.Runes) == "x":
+		reverted := m.diffPreviews[m.reviewFile].reverted
+		revert := !allTrue(reverted)
+		for i := range reverted {
+			reverted[i] = revert
+		}
+	default:
+		return false
+	}
+	m.rerenderDiffPreviews()
+	return true
+}
+
+// moveReviewCursor steps to the next or previous hunk, crossing file
+// boundaries and skipping files without hunks.
+func (m *Model) moveReviewCursor(step int) {
+	file, hunk := m.reviewFile, m.reviewHunk+step
+	for file >= 0 && file < len(m.diffPreviews) {
+		n := len(m.diffPreviews[file].reverted)
+		if hunk >= 0 && hunk < n {
+			m.reviewFile, m.reviewHunk = file, hunk
+			return
+		}
+		file += step
+		if file >= 0 && file < len(m.diffPreviews) && step < 0 {
+			hunk = len(m.diffPreviews[file].reverted) - 1
+		} else {
+			hunk = 0
+		}
+	}
+}
+
+// batchDecisions turns the review marks into per-file decisions, or nil when
+// nothing was marked for revert.
+func (m Model) batchDecisions() []changebatch.FileDecision {
+	var decisions []changebatch.FileDecision
+	changed := false
+	for _, block := range m.diffPreviews {
+		d := changebatch.FileDecision{Path: block.preview.RelPath, Keep: true}
+		switch {
+		case len(block.reverted) > 0 && allTrue(block.reverted):
+			d.Keep = false
+			changed = true
+		case anyTrue(block.reverted):
+			d.Hunks = make([]bool, len(block.reverted))
+			for i, r := range block.reverted {
+				d.Hunks[i] = !r
+			}
+			changed = true
+		}
+		decisions = append(decisions, d)
+	}
+	if !changed {
+		return nil
+	}
+	return decisions
+}
+
+func allTrue(flags []bool) bool {
+	for _, f := range flags {
+		if !f {
+			return false
+		}
+	}
+	return true
+}
+
+func anyTrue(flags []bool) bool {
+	for _, f := range flags {
+		if f {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/webforspeed/bono/internal/diff"
 )
 
 type DiffViewMode int
@@ -22,6 +23,7 @@ const (
 	diffLineContext diffLineType = iota
 	diffLineAdded
 	diffLineDeleted
+	diffLineSkip // collapsed run of unchanged lines; Content holds the label
 )
 
 type diffLine struct {
@@ -29,6 +31,7 @@ type diffLine struct {
 	OldLineNum int
 	NewLineNum int
 	Content    string
+	Hunk       int // index of the change hunk, -1 for context lines
 }
 
 type DiffViewer struct {
@@ -40,10 +43,18 @@ type DiffViewer struct {
 	ready       bool
 	oldFilename string
 	newFilename string
+
+	// context is the number of unchanged lines kept around each change;
+	// longer unchanged runs collapse into one line. Negative shows everything.
+	context int
+
+	// Batch review state, see SetReview.
+	cursorHunk int
+	reverted   []bool
 }
 
 func NewDiffViewer() DiffViewer {
-	v := DiffViewer{viewMode: DiffViewInline, width: 80, height: 20}
+	v := DiffViewer{viewMode: DiffViewInline, width: 80, height: 20, context: diff.DefaultContext, cursorHunk: -1}
 	v.viewport = viewport.New(v.width, v.height)
 	v.ready = true
 	return v
@@ -64,7 +75,7 @@ func (d *DiffViewer) SetSize(width, height int) {
 func (d *DiffViewer) SetContent(oldContent, newContent, oldFilename, newFilename string) {
 	d.oldFilename = oldFilename
 	d.newFilename = newFilename
-	d.diffLines = computeDiffLines(oldContent, newContent)
+	d.diffLines = collapseContext(computeDiffLines(oldContent, newContent), d.context)
 	d.viewport.SetContent(d.renderDiff())
 	d.viewport.GotoTop()
 }
@@ -118,99 +129,110 @@ func (d DiffViewer) RenderFull() string {
 }
 
 func computeDiffLines(oldContent, newContent string) []diffLine {
-	oldLines := splitLines(oldContent)
-	newLines := splitLines(newContent)
-	ops := diffOps(oldLines, newLines)
+	ops := diff.Compute(diff.SplitLines(oldContent), diff.SplitLines(newContent))
 
 	result := make([]diffLine, 0, len(ops))
 	oldN, newN := 1, 1
+	hunk := -1
+	inHunk := false
 	for _, op := range ops {
-		switch op.kind {
-		case opEqual:
-			result = append(result, diffLine{Type: diffLineContext, OldLineNum: oldN, NewLineNum: newN, Content: op.text})
+		text := strings.TrimRight(op.Text, "\r\n")
+		if op.Kind == diff.Equal {
+			inHunk = false
+			result = append(result, diffLine{Type: diffLineContext, OldLineNum: oldN, NewLineNum: newN, Content: text, Hunk: -1})
 			oldN++
 			newN++
-		case opDelete:
-			result = append(result, diffLine{Type: diffLineDeleted, OldLineNum: oldN, Content: op.text})
+			continue
+		}
+		if !inHunk {
+			inHunk = true
+			hunk++
+		}
+		if op.Kind == diff.Delete {
+			result = append(result, diffLine{Type: diffLineDeleted, OldLineNum: oldN, Content: text, Hunk: hunk})
 			oldN++
-		case opInsert:
-			result = append(result, diffLine{Type: diffLineAdded, NewLineNum: newN, Content: op.text})
+		} else {
+			result = append(result, diffLine{Type: diffLineAdded, NewLineNum: newN, Content: text, Hunk: hunk})
 			newN++
 		}
 	}
 	return result
 }
 
-func splitLines(s string) []string {
-	if s == "" {
-		return nil
-	}
-	s = strings.ReplaceAll(s, "\r\n", "\n")
-	lines := strings.Split(s, "\n")
-	if len(lines) > 0 && lines[len(lines)-1] == "" {
-		lines = lines[:len(lines)-1]
-	}
-	return lines
-}
-
-type opKind int
-
-const (
-	opEqual opKind = iota
-	opDelete
-	opInsert
-)
-
-type lineOp struct {
-	kind opKind
-	text string
-}
-
-// diffOps computes a line-level diff via LCS dynamic programming.
-func diffOps(a, b []string) []lineOp {
-	n, m := len(a), len(b)
-	dp := make([][]int, n+1)
-	for i := range dp {
-		dp[i] = make([]int, m+1)
+// collapseContext replaces unchanged lines more than context lines away from
+// any change with a single skip line.
+func collapseContext(lines []diffLine, context int) []diffLine {
+	if context < 0 {
+		return lines
 	}
-	for i := n - 1; i >= 0; i-- {
-		for j := m - 1; j >= 0; j-- {
-			if a[i] == b[j] {
-				dp[i][j] = dp[i+1][j+1] + 1
-			} else if dp[i+1][j] >= dp[i][j+1] {
-				dp[i][j] = dp[i+1][j]
-			} else {
-				dp[i][j] = dp[i][j+1]
-			}
-		}
-	}
-
-	i, j := 0, 0
-	ops := make([]lineOp, 0, n+m)
-	for i < n && j < m {
-		if a[i] == b[j] {
-			ops = append(ops, lineOp{kind: opEqual, text: a[i]})
+	result := make([]diffLine, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].Type != diffLineContext {
+			result = append(result, lines[i])
 			i++
-			j++
 			continue
 		}
-		if dp[i+1][j] >= dp[i][j+1] {
-			ops = append(ops, lineOp{kind: opDelete, text: a[i]})
-			i++
+		end := i
+		for end < len(lines) && lines[end].Type == diffLineContext {
+			end++
+		}
+		head, tail := context, context
+		if i == 0 {
+			head = 0
+		}
+		if end == len(lines) {
+			tail = 0
+		}
+		if hidden := end - i - head - tail; hidden > 0 {
+			result = append(result, lines[i:i+head]...)
+			label := fmt.Sprintf("⋯ %d unchanged lines", hidden)
+			if hidden == 1 {
+				label = "⋯ 1 unchanged line"
+			}
+			result = append(result, diffLine{Type: diffLineSkip, Content: label, Hunk: -1})
+			result = append(result, lines[end-tail:end]...)
 		} else {
-			ops = append(ops, lineOp{kind: opInsert, text: b[j]})
-			j++
+			result = append(result, lines[i:end]...)
 		}
+		i = end
 	}
-	for i < n {
-		ops = append(ops, lineOp{kind: opDelete, text: a[i]})
-		i++
+	return result
+}
+
+// HunkCount returns the number of change hunks in the current diff.
+func (d DiffViewer) HunkCount() int {
+	count := 0
+	for _, l := range d.diffLines {
+		if l.Hunk+1 > count {
+			count = l.Hunk + 1
+		}
 	}
-	for j < m {
-		ops = append(ops, lineOp{kind: opInsert, text: b[j]})
-		j++
+	return count
+}
+
+// SetReview marks hunks for batch review: cursor is the selected hunk (-1
+// for none) and reverted flags hunks the user chose to undo.
+func (d *DiffViewer) SetReview(cursor int, reverted []bool) {
+	d.cursorHunk = cursor
+	d.reverted = reverted
+	d.viewport.SetContent(d.renderDiff())
+}
+
+// reviewMarker returns the gutter marker for a line: a bar on the selected
+// hunk while a batch is under review, and nothing outside review.
+func (d DiffViewer) reviewMarker(l diffLine) string {
+	switch {
+	case d.cursorHunk < 0 && d.reverted == nil:
+		return ""
+	case l.Hunk >= 0 && l.Hunk == d.cursorHunk:
+		return "▌"
+	default:
+		return " "
 	}
-	return ops
+}
+
+func (d DiffViewer) isReverted(l diffLine) bool {
+	return l.Hunk >= 0 && l.Hunk < len(d.reverted) && d.reverted[l.Hunk]
 }
 
 func (d DiffViewer) renderDiff() string {
@@ -226,8 +248,11 @@ func (d DiffViewer) renderInline() string {
 	contextStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("250"))
 	addStyle := lipgloss.NewStyle().Background(lipgloss.Color("22")).Foreground(lipgloss.Color("120"))
 	delStyle := lipgloss.NewStyle().Background(lipgloss.Color("52")).Foreground(lipgloss.Color("203"))
+	revertStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Strikethrough(true)
+	markerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
 
 	for _, line := range d.diffLines {
+		marker := markerStyle.Render(d.reviewMarker(line))
 		oldNum := "    "
 		newNum := "    "
 		if line.OldLineNum > 0 {
@@ -236,13 +261,19 @@ func (d DiffViewer) renderInline() string {
 		if line.NewLineNum > 0 {
 			newNum = fmt.Sprintf("%4d", line.NewLineNum)
 		}
-		lineNums := lineNumStyle.Render(oldNum) + " " + lineNumStyle.Render(newNum) + " "
+		lineNums := marker + lineNumStyle.Render(oldNum) + " " + lineNumStyle.Render(newNum) + " "
 
-		switch line.Type {
-		case diffLineAdded:
+		switch {
+		case d.isReverted(line) && line.Type == diffLineAdded:
+			sb.WriteString(lineNums + revertStyle.Render("+ "+line.Content) + "\n")
+		case d.isReverted(line):
+			sb.WriteString(lineNums + contextStyle.Render("↺ "+line.Content) + "\n")
+		case line.Type == diffLineAdded:
 			sb.WriteString(lineNums + addStyle.Render("+ "+line.Content) + "\n")
-		case diffLineDeleted:
+		case line.Type == diffLineDeleted:
 			sb.WriteString(lineNums + delStyle.Render("- "+line.Content) + "\n")
+		case line.Type == diffLineSkip:
+			sb.WriteString(lineNums + lineNumStyle.Render(line.Content) + "\n")
 		default:
 			sb.WriteString(lineNums + contextStyle.Render("  "+line.Content) + "\n")
 		}
@@ -261,21 +292,30 @@ func (d DiffViewer) renderSideBySide() string {
 	contextStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("250"))
 	addStyle := lipgloss.NewStyle().Background(lipgloss.Color("22")).Foreground(lipgloss.Color("120"))
 	delStyle := lipgloss.NewStyle().Background(lipgloss.Color("52")).Foreground(lipgloss.Color("203"))
+	revertStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Strikethrough(true)
+	markerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
 
 	for _, l := range d.diffLines {
 		var left, right string
+		lineAdd, lineDel := addStyle, delStyle
+		if d.isReverted(l) {
+			lineAdd, lineDel = revertStyle, contextStyle
+		}
 		switch l.Type {
+		case diffLineSkip:
+			left = formatSide(lineNumStyle, lineNumStyle, 0, l.Content, halfWidth)
+			right = formatSide(lineNumStyle, lineNumStyle, 0, "", halfWidth)
 		case diffLineContext:
 			left = formatSide(lineNumStyle, contextStyle, l.OldLineNum, l.Content, halfWidth)
 			right = formatSide(lineNumStyle, contextStyle, l.NewLineNum, l.Content, halfWidth)
 		case diffLineDeleted:
-			left = formatSide(lineNumStyle, delStyle, l.OldLineNum, l.Content, halfWidth)
+			left = formatSide(lineNumStyle, lineDel, l.OldLineNum, l.Content, halfWidth)
 			right = strings.Repeat(" ", halfWidth)
 		case diffLineAdded:
 			left = strings.Repeat(" ", halfWidth)
-			right = formatSide(lineNumStyle, addStyle, l.NewLineNum, l.Content, halfWidth)
+			right = formatSide(lineNumStyle, lineAdd, l.NewLineNum, l.Content, halfWidth)
 		}
-		sb.WriteString(left + " │ " + right + "\n")
+		sb.WriteString(markerStyle.Render(d.reviewMarker(l)) + left + " │ " + right + "\n")
 	}
 	return sb.String()
 }
@@ -0,0 +1,24 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseContextKeepsLinesNearChanges(t *testing.T) {
+	var old strings.Builder
+	for i := 0; i < 20; i++ {
+		old.WriteString("line\n")
+	}
+	lines := collapseContext(computeDiffLines(old.String(), "new\n"+old.String()), 3)
+
+	if len(lines) != 5 {
+		t.Fatalf("len(lines) = %d, want 5 (1 added, 3 context, 1 skip)", len(lines))
+	}
+	if lines[0].Type != diffLineAdded {
+		t.Fatalf("lines[0].Type = %v, want added", lines[0].Type)
+	}
+	if skip := lines[4]; skip.Type != diffLineSkip || skip.Content != "⋯ 17 unchanged lines" {
+		t.Fatalf("lines[4] = %+v, want skip of 17 lines", skip)
+	}
+}
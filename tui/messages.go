@@ -1,5 +1,11 @@
 package tui
 
+import (
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/permissions"
+	"github.com/webforspeed/bono/internal/session"
+)
+
 // AgentMessageMsg is sent when the agent produces a message response.
 type AgentMessageMsg string
 
@@ -10,6 +16,9 @@ type AgentToolCallMsg struct {
 	Approved        chan bool // nil for auto-approved tools, otherwise TUI sends approval here
 	Sandboxed       bool      // true if running in sandbox (shell/python)
 	ExecutionReason string    // optional reason for special execution routing/approval
+
+	Grants []permissions.Grant          // "always allow" choices offered alongside Enter
+	Choose func(permissions.GrantScope) // records the picked grant before approving
 }
 
 // AgentToolDoneMsg is sent when a tool call completes.
@@ -20,6 +29,13 @@ type AgentToolDoneMsg struct {
 	Sandboxed bool // true if ran in sandbox (shell/python)
 }
 
+// AgentToolDeniedMsg is sent when a permission rule blocks a tool call.
+type AgentToolDeniedMsg struct {
+	Name string
+	Args map[string]any
+	Rule string
+}
+
 // AgentDiffPreviewMsg carries file-scoped before/after content for post-write review.
 type AgentDiffPreviewMsg struct {
 	RelPath    string
@@ -30,7 +46,8 @@ type AgentDiffPreviewMsg struct {
 // AgentChangeBatchApprovalMsg asks the user to approve or undo a batch of changes.
 type AgentChangeBatchApprovalMsg struct {
 	Count    int
-	Approved chan bool // TUI sends approval here (Enter=true, Esc=false)
+	Approved chan bool                        // TUI sends approval here (Enter=true, Esc=false)
+	Decide   func([]changebatch.FileDecision) // reports reverted files/hunks before approving
 }
 
 // AgentPreTaskStartMsg is sent when a pre-task agent starts.
@@ -64,6 +81,13 @@ type AgentSandboxFallbackMsg struct {
 	Approved chan bool // TUI sends approval here
 }
 
+// AgentUndoConflictMsg asks whether to overwrite files edited after the
+// agent changed them when undoing a batch cannot merge those edits.
+type AgentUndoConflictMsg struct {
+	Changes  []changebatch.FileChange
+	Approved chan bool // Enter overwrites, Esc keeps the files as they are
+}
+
 // AgentPlanApprovalMsg asks the user to approve, reject, or revise a subagent plan.
 type AgentPlanApprovalMsg struct {
 	OutputPath string
@@ -98,6 +122,17 @@ type AgentResponseModelMsg struct {
 	ModelID string
 }
 
+// AgentRetryMsg is sent when a failed request is retried or moved to a
+// fallback model.
+type AgentRetryMsg struct {
+	Event session.RetryEvent
+}
+
+// AgentRoleMsg is sent when the agent moves onto a role's model, or back.
+type AgentRoleMsg struct {
+	Event session.RoleEvent
+}
+
 // ModelWarmDoneMsg is sent after background warm-up of usage limits for a switched model.
 type ModelWarmDoneMsg struct {
 	ModelID string
@@ -2,8 +2,10 @@ package tui
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,6 +13,10 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	core "github.com/webforspeed/bono-core"
 	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/changebatch"
+	"github.com/webforspeed/bono/internal/permissions"
+	"github.com/webforspeed/bono/internal/session"
+	"github.com/webforspeed/bono/internal/transcript"
 )
 
 // Model is the main Bubble Tea model that composes all TUI components.
@@ -35,9 +41,17 @@ type Model struct {
 
 	// External dependencies
 	agent      *core.Agent
+	chat       func(ctx context.Context, prompt string) (string, error)
 	ctx        context.Context
 	renderer   *glamour.TermRenderer
 	dispatcher *hooks.Dispatcher
+	policy     *permissions.Policy
+	history    *changebatch.History
+
+	// Config profiles for /profile
+	profiles    []string
+	profile     string
+	loadProfile func(name string) (Profile, error)
 
 	// For async agent calls
 	program *tea.Program
@@ -49,7 +63,9 @@ type Model struct {
 
 	// Tool approval state
 	pendingApproval        *AgentToolCallMsg        // current tool awaiting Enter/Esc
+	pendingGrant           *permissions.Grant       // grant picked for pendingApproval, saved on Enter
 	pendingSandboxFallback *AgentSandboxFallbackMsg // sandbox fallback awaiting Enter/Esc
+	pendingUndoConflict    *AgentUndoConflictMsg    // undo conflict awaiting Enter/Esc
 	pendingBatchApproval   *AgentChangeBatchApprovalMsg
 	pendingPlanApproval    *AgentPlanApprovalMsg // plan review awaiting Enter/Esc/feedback
 
@@ -57,10 +73,15 @@ type Model struct {
 	diffViewer   DiffViewer
 	diffActive   bool // true when batch review is awaiting approval (for Tab key handling)
 	diffPreviews []diffPreviewBlock
+	reviewFile   int // diffPreviews index of the selected hunk
+	reviewHunk   int
 
 	// Lifecycle callbacks
 	onSessionClear func() // called on /clear to reset per-session state.
 
+	// resumeContext is prepended to the first prompt after --resume/--continue.
+	resumeContext string
+
 	// Code search watcher metadata
 	watcher *FileWatcher
 
@@ -73,6 +94,7 @@ type Model struct {
 type diffPreviewBlock struct {
 	messageIndex int
 	preview      AgentDiffPreviewMsg
+	reverted     []bool // per-hunk revert marks during batch review
 }
 
 // New creates a new TUI Model with the given agent and context.
@@ -106,6 +128,7 @@ func NewWithOptions(agent *core.Agent, ctx context.Context, spinnerType SpinnerT
 	sidebar := NewSidebar()
 	sidebar.SetCwd(cwd)
 	sidebar.SetModelName(modelName)
+	sidebar.SetReasoningEffort(agent.ReasoningEffort())
 	sidebar.SetGitStatus(FetchGitStatus())
 
 	slashCommands := DefaultSlashCommandSpecs()
@@ -125,6 +148,7 @@ func NewWithOptions(agent *core.Agent, ctx context.Context, spinnerType SpinnerT
 		slashCommandIndex: slashCommandIndex(slashCommands),
 		statusBarBaseText: statusBar.Text(),
 		agent:             agent,
+		chat:              agent.Chat,
 		ctx:               ctx,
 		renderer:          renderer,
 		messages:          []string{},
@@ -329,6 +353,12 @@ func (m Model) mainWidth() int {
 	return m.width - sidebarWidth
 }
 
+// SetChat sets how prompts are sent to the agent, such as through a
+// session's retry and fallback policy. The default is the agent's Chat.
+func (m *Model) SetChat(chat func(ctx context.Context, prompt string) (string, error)) {
+	m.chat = chat
+}
+
 // SetWatcher sets the file watcher for change notifications.
 func (m *Model) SetWatcher(w *FileWatcher) {
 	m.watcher = w
@@ -339,11 +369,86 @@ func (m *Model) SetDispatcher(d *hooks.Dispatcher) {
 	m.dispatcher = d
 }
 
+// fireHook dispatches a hook event off the UI goroutine, since handlers may
+// run commands or wait on the network.
+func (m *Model) fireHook(event hooks.Event, payload any) tea.Cmd {
+	d, ctx := m.dispatcher, m.ctx
+	if d == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		d.Fire(ctx, event, payload)
+		return nil
+	}
+}
+
+// Profile is what /profile applies to the running agent.
+type Profile struct {
+	Name      string
+	Model     string
+	BaseURL   string
+	Reasoning string // "" leaves reasoning unchanged; "none" disables it
+	Apply     func() // applies the rest of the profile, such as fallback and role models; may be nil
+}
+
+// SetProfiles sets the config profiles offered by /profile, the one in use,
+// and a loader that resolves a profile against the rest of the config.
+func (m *Model) SetProfiles(names []string, current string, load func(name string) (Profile, error)) {
+	m.profiles = names
+	m.profile = current
+	m.loadProfile = load
+	m.sidebar.SetProfile(current)
+}
+
+// SetLocalProviders records how local model discovery went for /model.
+func (m *Model) SetLocalProviders(status []ProviderStatus) {
+	m.modelModal.SetProviders(status)
+}
+
+// SetPermissions sets the permission policy shown and edited by /permissions.
+func (m *Model) SetPermissions(p *permissions.Policy) {
+	m.policy = p
+}
+
+// SetChangeHistory sets the approved-batch history used by /history and /undo.
+func (m *Model) SetChangeHistory(h *changebatch.History) {
+	m.history = h
+}
+
 // SetOnSessionClear sets a callback invoked during /clear for session cleanup.
 func (m *Model) SetOnSessionClear(fn func()) {
 	m.onSessionClear = fn
 }
 
+// SetResumeContext sets a preamble sent with the next prompt to re-prime a resumed session.
+func (m *Model) SetResumeContext(text string) {
+	m.resumeContext = text
+}
+
+// ReplayTranscript renders a previous session's transcript into the viewport.
+func (m *Model) ReplayTranscript(entries []transcript.Entry) {
+	for _, e := range entries {
+		switch e.Type {
+		case transcript.EntryUserPrompt:
+			m.AppendRawMessage("> " + e.Content)
+		case transcript.EntryMessage:
+			if strings.TrimSpace(e.Content) != "" {
+				m.AppendMessage(e.Content)
+			}
+		case transcript.EntryTool:
+			m.AppendRawMessage(fmt.Sprintf("● %s => %s", e.Label, e.Status))
+		case transcript.EntryError:
+			m.AppendRawMessage("Error: " + e.Content)
+		case transcript.EntryModel:
+			m.sidebar.SetModelName(m.displayModelName(e.Model))
+		case transcript.EntryUsage:
+			m.sidebar.SetContextUsage(e.ContextPct)
+			m.sidebar.SetTotalCost(e.TotalCost)
+		}
+	}
+	m.AppendRawMessage("  ↳ Resumed previous session")
+}
+
 // AgentResponseMsg is sent when the agent finishes processing.
 type AgentResponseMsg struct {
 	Response string
@@ -381,6 +486,28 @@ func (m *Model) displayModelName(modelID string) string {
 	return modelID
 }
 
+// reasoningNote returns a warning line when the current model does not
+// list reasoning support, or "" when it does or nothing is known.
+func (m *Model) reasoningNote() string {
+	for _, info := range m.modelModal.models {
+		if info.ID == m.agent.ModelName() && !info.SupportsReasoning() {
+			return fmt.Sprintf("  ↳ Note: %s does not list reasoning support; the effort may be ignored", info.Name)
+		}
+	}
+	return ""
+}
+
+// warmModelLimits loads usage limits for modelID in the background so context
+// usage shows from the first response.
+func (m *Model) warmModelLimits(modelID string) tea.Cmd {
+	return func() tea.Msg {
+		warmCtx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+		defer cancel()
+		err := m.agent.WarmModelUsageLimits(warmCtx, modelID)
+		return ModelWarmDoneMsg{ModelID: modelID, Err: err}
+	}
+}
+
 // submitInput handles submitting the current input.
 func (m *Model) submitInput() tea.Cmd {
 	value := strings.TrimSpace(m.input.Value())
@@ -393,11 +520,6 @@ func (m *Model) submitInput() tea.Cmd {
 		return m.handleSlashCommand(value)
 	}
 
-	// Fire UserPromptSubmit hook
-	if m.dispatcher != nil {
-		m.dispatcher.Fire(m.ctx, hooks.UserPromptSubmit, hooks.UserPromptSubmitPayload{Input: value})
-	}
-
 	// Clear input and submit to agent
 	m.input.Reset()
 
@@ -409,14 +531,27 @@ func (m *Model) submitInput() tea.Cmd {
 	m.spinnerBar.SetText("Thinking...")
 	m.spinnerBar.SetActive(true)
 
+	resume := m.resumeContext
+	m.resumeContext = ""
+
 	// Return a command that will call the agent asynchronously
-	agent := m.agent
+	chat := m.chat
 	ctx := m.ctx
 	d := m.dispatcher
 	return tea.Batch(
 		m.spinnerBar.Tick(),
 		func() tea.Msg {
-			response, err := agent.Chat(ctx, value)
+			// UserPromptSubmit hooks may run commands, so they fire here
+			// rather than on the UI goroutine.
+			var hookContext string
+			if d != nil {
+				decision := d.Fire(ctx, hooks.UserPromptSubmit, hooks.UserPromptSubmitPayload{Input: value})
+				if decision.Block {
+					return AgentResponseMsg{Err: fmt.Errorf("prompt blocked by hook: %s", decision.Reason)}
+				}
+				hookContext = decision.Context
+			}
+			response, err := chat(ctx, session.AgentPrompt(resume, value, hookContext))
 			if d != nil {
 				d.Fire(ctx, hooks.Stop, hooks.StopPayload{Response: response, Err: err})
 			}
@@ -0,0 +1,292 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// deleteTimeout bounds a model delete started from the picker.
+const deleteTimeout = 30 * time.Second
+
+// OllamaPullMsg reports progress of a model pull started from the picker,
+// or its end when Done is set.
+type OllamaPullMsg struct {
+	Name     string
+	Progress OllamaPullProgress
+	Done     bool
+	Err      error
+
+	next <-chan OllamaPullMsg
+}
+
+// OllamaDeleteMsg reports the end of a model delete started from the picker.
+type OllamaDeleteMsg struct {
+	Name string
+	Err  error
+}
+
+// LocalModelsMsg carries a fresh discovery of the local providers.
+type LocalModelsMsg struct {
+	Models []ModelInfo
+	Status []ProviderStatus
+}
+
+// pullState is the pull in progress. Total and completed are bytes of the
+// layer being downloaded.
+type pullState struct {
+	name      string
+	status    string
+	total     int64
+	completed int64
+	cancel    context.CancelFunc
+}
+
+// handleActionKey runs the picker's single-key actions: p pulls, d deletes,
+// r refreshes the local models and x cancels a pull.
+func (mm *ModelModal) handleActionKey(key string) (tea.Cmd, bool) {
+	switch key {
+	case "p":
+		if _, ok := mm.ollamaProvider(); !ok {
+			mm.notice = "Pulling models needs an Ollama provider (local.ollama)"
+		} else if mm.pull != nil {
+			mm.notice = "Already pulling " + mm.pull.name
+		} else {
+			mm.mode, mm.pullName, mm.notice = modePullName, "", ""
+		}
+		return nil, true
+
+	case "d":
+		if mm.selected >= len(mm.models) {
+			return nil, true
+		}
+		model := mm.models[mm.selected]
+		if _, ok := mm.ollamaProviderFor(model); !ok {
+			mm.notice = "Only Ollama models can be deleted here"
+			return nil, true
+		}
+		mm.mode, mm.deleting, mm.notice = modeConfirmDelete, model, ""
+		return nil, true
+
+	case "r":
+		if len(mm.providers) == 0 {
+			mm.notice = "No local providers are configured"
+			return nil, true
+		}
+		mm.notice = "Refreshing local models…"
+		return mm.refresh(), true
+
+	case "x":
+		if mm.pull != nil {
+			mm.pull.cancel()
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (mm *ModelModal) handlePullNameKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		mm.mode = modeList
+	case tea.KeyEnter:
+		mm.mode = modeList
+		if name := strings.TrimSpace(mm.pullName); name != "" {
+			return mm.startPull(name)
+		}
+	case tea.KeyBackspace:
+		if r := []rune(mm.pullName); len(r) > 0 {
+			mm.pullName = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes:
+		mm.pullName += string(msg.Runes)
+	}
+	return nil
+}
+
+func (mm *ModelModal) handleConfirmDeleteKey(msg tea.KeyMsg) tea.Cmd {
+	mm.mode = modeList
+	if msg.Type != tea.KeyRunes || strings.ToLower(string(msg.Runes)) != "y" {
+		return nil
+	}
+	model := mm.deleting
+	p, _ := mm.ollamaProviderFor(model)
+	mm.notice = "Deleting " + model.ID + "…"
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+		defer cancel()
+		return OllamaDeleteMsg{Name: model.ID, Err: DeleteOllamaModel(ctx, p, model.ID)}
+	}
+}
+
+// startPull pulls name on the Ollama provider in the background. Progress
+// arrives as a chain of OllamaPullMsg, each carrying the channel to wait on
+// for the next.
+func (mm *ModelModal) startPull(name string) tea.Cmd {
+	p, _ := mm.ollamaProvider()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan OllamaPullMsg, 16)
+	mm.pull = &pullState{name: name, status: "starting", cancel: cancel}
+	mm.notice = ""
+	go func() {
+		err := PullOllamaModel(ctx, p, name, func(progress OllamaPullProgress) {
+			ch <- OllamaPullMsg{Name: name, Progress: progress, next: ch}
+		})
+		ch <- OllamaPullMsg{Name: name, Done: true, Err: err}
+	}()
+	return waitPull(ch)
+}
+
+func waitPull(ch <-chan OllamaPullMsg) tea.Cmd {
+	return func() tea.Msg { return <-ch }
+}
+
+// refresh rediscovers the models on the local providers.
+func (mm *ModelModal) refresh() tea.Cmd {
+	providers := make([]Provider, len(mm.providers))
+	for i, s := range mm.providers {
+		providers[i] = s.Provider
+	}
+	return func() tea.Msg {
+		models, status := DiscoverLocalModels(context.Background(), providers)
+		return LocalModelsMsg{Models: models, Status: status}
+	}
+}
+
+// Update applies the progress and results of the picker's actions. It runs
+// whether or not the modal is visible, so a pull finishes in the
+// background.
+func (mm *ModelModal) Update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case OllamaPullMsg:
+		if mm.pull == nil || mm.pull.name != msg.Name {
+			return nil
+		}
+		if !msg.Done {
+			mm.pull.status = msg.Progress.Status
+			mm.pull.total, mm.pull.completed = msg.Progress.Total, msg.Progress.Completed
+			return waitPull(msg.next)
+		}
+		mm.pull.cancel()
+		mm.pull = nil
+		switch {
+		case errors.Is(msg.Err, context.Canceled):
+			mm.notice = "Cancelled pulling " + msg.Name
+		case msg.Err != nil:
+			mm.notice = "Couldn't pull: " + msg.Err.Error()
+		default:
+			mm.notice = "Pulled " + msg.Name
+			return mm.refresh()
+		}
+
+	case OllamaDeleteMsg:
+		if msg.Err != nil {
+			mm.notice = "Couldn't delete: " + msg.Err.Error()
+			return nil
+		}
+		mm.notice = "Deleted " + msg.Name
+		return mm.refresh()
+
+	case LocalModelsMsg:
+		models := make([]ModelInfo, 0, len(mm.models))
+		for _, m := range mm.models {
+			if !m.IsLocal {
+				models = append(models, m)
+			}
+		}
+		mm.models = append(models, msg.Models...)
+		mm.providers = msg.Status
+		if mm.selected >= len(mm.models) {
+			mm.selected = max(len(mm.models)-1, 0)
+		}
+		if strings.HasPrefix(mm.notice, "Refreshing") {
+			mm.notice = ""
+		}
+	}
+	return nil
+}
+
+// ollamaProvider returns the first Ollama provider, which pulls go to.
+func (mm ModelModal) ollamaProvider() (Provider, bool) {
+	for _, s := range mm.providers {
+		if s.Provider.Kind == ProviderOllama {
+			return s.Provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// ollamaProviderFor returns the Ollama provider serving m.
+func (mm ModelModal) ollamaProviderFor(m ModelInfo) (Provider, bool) {
+	for _, s := range mm.providers {
+		if s.Provider.Kind == ProviderOllama && m.IsLocal && m.BaseURL == s.Provider.BaseURL() {
+			return s.Provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// footer returns the lines below the model list: unreachable providers,
+// the pull in progress, the pending prompt, the last notice and key hints.
+func (mm ModelModal) footer() []string {
+	var lines []string
+	for _, s := range mm.unreachable() {
+		lines = append(lines, "  ⚠ "+s.String())
+	}
+	if mm.pull != nil {
+		lines = append(lines, "  "+mm.pull.String())
+	}
+	switch mm.mode {
+	case modePullName:
+		lines = append(lines, "  Pull model: "+mm.pullName+"█  (enter to pull, esc to cancel)")
+	case modeConfirmDelete:
+		lines = append(lines, fmt.Sprintf("  Delete %s? (y/n)", mm.deleting.ID))
+	}
+	if mm.notice != "" {
+		lines = append(lines, "  "+mm.notice)
+	}
+	if mm.mode == modeList && len(mm.providers) > 0 {
+		hint := "  enter select · r refresh"
+		if _, ok := mm.ollamaProvider(); ok {
+			hint += " · p pull · d delete"
+		}
+		if mm.pull != nil {
+			hint += " · x cancel pull"
+		}
+		lines = append(lines, hint+" · esc close")
+	}
+	return lines
+}
+
+// String renders the pull as a status line with a progress bar while a
+// layer downloads.
+func (p pullState) String() string {
+	line := fmt.Sprintf("Pulling %s: %s", p.name, p.status)
+	if p.total <= 0 {
+		return line
+	}
+	const width = 24
+	done := min(p.completed, p.total)
+	filled := int(done * width / p.total)
+	return fmt.Sprintf("%s [%s%s] %d%% %s/%s", line,
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled),
+		done*100/p.total, formatBytes(done), formatBytes(p.total))
+}
+
+// formatBytes renders n as "1.2 GB", "340 MB" and so on.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runModalCmd runs cmd and feeds each resulting message back to the modal
+// until no command is left, returning the messages seen.
+func runModalCmd(t *testing.T, mm *ModelModal, cmd tea.Cmd) []tea.Msg {
+	t.Helper()
+	var msgs []tea.Msg
+	for cmd != nil {
+		msg := cmd()
+		msgs = append(msgs, msg)
+		cmd = mm.Update(msg)
+	}
+	return msgs
+}
+
+func keyRunes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestModelModalPullDeleteAndRefresh(t *testing.T) {
+	srv := fakeOllama(t, "qwen3:8b")
+	providers := []Provider{{Name: "Ollama", Kind: ProviderOllama, URL: srv.URL}}
+	local, status := DiscoverLocalModels(context.Background(), providers)
+	remote := ModelInfo{ID: "openrouter/free", Name: "Free", BaseURL: OpenRouterBaseURL}
+	mm := NewModelModal(append([]ModelInfo{remote}, local...))
+	mm.SetProviders(status)
+	mm.Show()
+
+	if hint := strings.Join(mm.footer(), "\n"); !strings.Contains(hint, "p pull") || !strings.Contains(hint, "d delete") {
+		t.Errorf("footer = %q, want the pull and delete keys", hint)
+	}
+
+	// Pull: p, type the name, enter.
+	mm.HandleKey(keyRunes("p"))
+	for _, r := range "gemma3:4bx" {
+		mm.HandleKey(keyRunes(string(r)))
+	}
+	mm.HandleKey(tea.KeyMsg{Type: tea.KeyBackspace})
+	if !strings.Contains(strings.Join(mm.footer(), "\n"), "Pull model: gemma3:4b█") {
+		t.Errorf("footer while typing = %q", mm.footer())
+	}
+	cmd, _ := mm.HandleKey(tea.KeyMsg{Type: tea.KeyEnter})
+	if mm.pull == nil {
+		t.Fatal("enter did not start a pull")
+	}
+	msgs := runModalCmd(t, &mm, cmd)
+
+	var sawBar bool
+	for _, msg := range msgs {
+		if pull, ok := msg.(OllamaPullMsg); ok && pull.Progress.Total > 0 {
+			sawBar = true
+		}
+	}
+	if !sawBar {
+		t.Errorf("no progress with a total among %d messages", len(msgs))
+	}
+	if mm.pull != nil || mm.notice != "Pulled gemma3:4b" {
+		t.Errorf("after pull: pull = %v, notice = %q", mm.pull, mm.notice)
+	}
+	if len(mm.models) != 3 || mm.models[0].ID != "openrouter/free" || mm.models[2].ID != "gemma3:4b" {
+		t.Fatalf("models after pull = %v", mm.models)
+	}
+
+	// Delete the selected model after confirming.
+	mm.selected = 1
+	mm.HandleKey(keyRunes("d"))
+	if !strings.Contains(strings.Join(mm.footer(), "\n"), "Delete qwen3:8b? (y/n)") {
+		t.Errorf("footer while confirming = %q", mm.footer())
+	}
+	cmd, _ = mm.HandleKey(keyRunes("y"))
+	runModalCmd(t, &mm, cmd)
+	if mm.notice != "Deleted qwen3:8b" || len(mm.models) != 2 || mm.models[1].ID != "gemma3:4b" {
+		t.Errorf("after delete: notice = %q, models = %v", mm.notice, mm.models)
+	}
+
+	// A refresh that shrinks the list while confirming still deletes the
+	// model that was asked about.
+	mm.selected = 1
+	mm.HandleKey(keyRunes("d"))
+	mm.Update(LocalModelsMsg{Status: status})
+	if !strings.Contains(strings.Join(mm.footer(), "\n"), "Delete gemma3:4b? (y/n)") {
+		t.Errorf("footer after the list shrank = %q", mm.footer())
+	}
+	cmd, _ = mm.HandleKey(keyRunes("y"))
+	if msg, ok := cmd().(OllamaDeleteMsg); !ok || msg.Name != "gemma3:4b" || msg.Err != nil {
+		t.Errorf("delete after the list shrank = %+v", msg)
+	}
+	runModalCmd(t, &mm, mm.Update(OllamaDeleteMsg{Name: "gemma3:4b"}))
+
+	// Remote models cannot be deleted; a failed pull is reported.
+	mm.selected = 0
+	if cmd, _ := mm.HandleKey(keyRunes("d")); cmd != nil || mm.mode != modeList || !strings.Contains(mm.notice, "Only Ollama") {
+		t.Errorf("deleting a remote model: mode = %v, notice = %q", mm.mode, mm.notice)
+	}
+	mm.HandleKey(keyRunes("p"))
+	mm.HandleKey(keyRunes("missing:1b"))
+	cmd, _ = mm.HandleKey(tea.KeyMsg{Type: tea.KeyEnter})
+	runModalCmd(t, &mm, cmd)
+	if !strings.Contains(mm.notice, "Couldn't pull") || !strings.Contains(mm.notice, "file does not exist") {
+		t.Errorf("failed pull notice = %q", mm.notice)
+	}
+}
+
+func TestPullStateString(t *testing.T) {
+	p := pullState{name: "qwen3:8b", status: "pulling 6a0746a1ec1a", total: 2_000_000_000, completed: 500_000_000}
+	want := "Pulling qwen3:8b: pulling 6a0746a1ec1a [██████░░░░░░░░░░░░░░░░░░] 25% 500.0 MB/2.0 GB"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q\nwant        %q", got, want)
+	}
+	p.total = 0
+	if got := p.String(); got != "Pulling qwen3:8b: pulling 6a0746a1ec1a" {
+		t.Errorf("without a total: %q", got)
+	}
+}
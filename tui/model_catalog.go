@@ -1,184 +1,294 @@
 package tui
 
-import "context"
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const OpenRouterBaseURL = "https://openrouter.ai/api/v1"
 
-// LoadModelCatalog returns the default model catalog merged with available Ollama models.
-func LoadModelCatalog(ctx context.Context) []ModelInfo {
+// ProjectModelsFile is the project-level catalog override, relative to the
+// workspace root.
+const ProjectModelsFile = ".bono/models.json"
+
+// DefaultCatalogTTL is how long a cached OpenRouter model list is used
+// before it is fetched again.
+const DefaultCatalogTTL = 24 * time.Hour
+
+//go:embed models.json
+var defaultCatalog []byte
+
+// CatalogOptions control LoadModelCatalog.
+type CatalogOptions struct {
+	// Files override the built-in catalog in order, for example the user
+	// and then the project models.json. Missing files are skipped.
+	Files []string
+	// Refresh fills in context length, pricing and supported parameters
+	// from OpenRouter's /models endpoint. The response is cached in
+	// CacheFile for CacheTTL; Force fetches even when the cache is fresh.
+	Refresh   bool
+	Force     bool
+	CacheFile string
+	CacheTTL  time.Duration
+	// BaseURL is the OpenRouter API to refresh from; empty uses
+	// OpenRouterBaseURL.
+	BaseURL string
+	// Providers are the local model servers to discover models on.
+	Providers []Provider
+}
+
+// UserModelsFile returns the user-level catalog override
+// ($XDG_CONFIG_HOME/bono/models.json, defaulting to ~/.config/bono/models.json).
+func UserModelsFile() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bono", "models.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bono", "models.json"), nil
+}
+
+// ModelCacheFile returns where the OpenRouter model list is cached.
+func ModelCacheFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bono", "openrouter-models.json"), nil
+}
+
+// LoadModelCatalog returns the remote model catalog merged with the models
+// discovered on the local providers, and how discovery went on each. A
+// broken override file is an error naming the file; a failed refresh or an
+// unreachable provider is not, and the catalog keeps the cached or built-in
+// values.
+func LoadModelCatalog(ctx context.Context, opts CatalogOptions) ([]ModelInfo, []ProviderStatus, error) {
+	models, err := remoteCatalog(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	local, status := DiscoverLocalModels(ctx, opts.Providers)
+	return append(models, local...), status, nil
+}
+
+func remoteCatalog(ctx context.Context, opts CatalogOptions) ([]ModelInfo, error) {
 	models := DefaultModelCatalog()
-	models = append(models, FetchOllamaModels(ctx)...)
-	return models
+	for _, file := range opts.Files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			models, err = mergeCatalog(models, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	visible := models[:0]
+	for _, m := range models {
+		if !m.Hidden {
+			visible = append(visible, m)
+		}
+	}
+	models = visible
+
+	if opts.Refresh {
+		if list, err := openRouterModels(ctx, opts); err == nil {
+			applyOpenRouter(models, list)
+		} else {
+			log.Warn("model catalog refresh failed", "error", err)
+		}
+	}
+	return models, nil
 }
 
-// DefaultModelCatalog returns the hardcoded remote model catalog.
+// DefaultModelCatalog returns the built-in remote model catalog.
 func DefaultModelCatalog() []ModelInfo {
-	return []ModelInfo{
-		{
-			ID:           "anthropic/claude-sonnet-4.6",
-			Name:         "Claude Sonnet 4.6",
-			Provider:     "Anthropic",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "high cost"},
-			Context:      "1M",
-			Tier:         "balanced",
-		},
-		{
-			ID:           "anthropic/claude-opus-4.6",
-			Name:         "Claude Opus 4.6",
-			Provider:     "Anthropic",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "frontier", "very high cost"},
-			Context:      "1M",
-			Tier:         "frontier",
-		},
-		{
-			ID:           "anthropic/claude-haiku-4.5",
-			Name:         "Claude Haiku 4.5",
-			Provider:     "Anthropic",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "mid cost"},
-			Context:      "200K",
-			Tier:         "balanced",
-		},
-
-		{
-			ID:           "openai/gpt-5.3-chat",
-			Name:         "GPT-5.3 Chat",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "high cost"},
-			Context:      "128K",
-			Tier:         "balanced",
-		},
-		{
-			ID:           "openai/gpt-5.3-codex",
-			Name:         "GPT-5.3 Codex",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "frontier", "high cost"},
-			Context:      "400K",
-			Tier:         "frontier",
-		},
-		{
-			ID:           "openai/gpt-5.4-pro",
-			Name:         "GPT-5.4 Pro",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "frontier", "very high cost"},
-			Context:      "1M",
-			Tier:         "frontier",
-		},
-		{
-			ID:           "openai/gpt-5.4",
-			Name:         "GPT-5.4",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "high cost"},
-			Context:      "1M",
-			Tier:         "balanced",
-		},
-
-		{
-			ID:           "openai/gpt-oss-120b",
-			Name:         "GPT-OSS 120B",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"very fast", "low intelligence", "ultra low cost"},
-			Context:      "131K",
-			Tier:         "mid",
-		},
-		{
-			ID:           "openai/gpt-oss-20b",
-			Name:         "GPT-OSS 20B",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"very fast", "very low intelligence", "ultra low cost"},
-			Context:      "131K",
-			Tier:         "mid",
-		},
-		{
-			ID:           "openai/gpt-oss-safeguard-20b:nitro",
-			Name:         "GPT-OSS Safeguard 20B (Nitro)",
-			Provider:     "OpenAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"very fast", "very low intelligence", "ultra low cost"},
-			Context:      "131K",
-			Tier:         "mid",
-		},
-		{
-			ID:           "qwen/qwen3-32b:nitro",
-			Name:         "Qwen3 32B (Nitro)",
-			Provider:     "Qwen",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"very fast", "very low intelligence", "ultra low cost"},
-			Context:      "40,960",
-			Tier:         "mid",
-		},
-		{
-			ID:           "openrouter/free",
-			Name:         "OpenRouter Free Router",
-			Provider:     "OpenRouter",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"free", "random free model", "varies by model"},
-			Context:      "varies",
-			Tier:         "budget",
-		},
-
-		{
-			ID:           "google/gemini-3.1-pro-preview",
-			Name:         "Gemini 3.1 Pro (Preview)",
-			Provider:     "Google",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"balanced", "frontier", "high cost"},
-			Context:      "1.05M",
-			Tier:         "frontier",
-		},
-		{
-			ID:           "google/gemini-3-flash-preview",
-			Name:         "Gemini 3 Flash (Preview)",
-			Provider:     "Google",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "low cost"},
-			Context:      "1.05M",
-			Tier:         "balanced",
-		},
-
-		{
-			ID:           "minimax/minimax-m2.5",
-			Name:         "MiniMax M2.5",
-			Provider:     "MiniMax",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "low cost"},
-			Context:      "196K",
-			Tier:         "balanced",
-		},
-		{
-			ID:           "moonshotai/kimi-k2.5",
-			Name:         "Kimi K2.5",
-			Provider:     "MoonshotAI",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "balanced", "low cost"},
-			Context:      "262K",
-			Tier:         "balanced",
-		},
-		{
-			ID:           "deepseek/deepseek-v3.2",
-			Name:         "DeepSeek V3.2",
-			Provider:     "DeepSeek",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "cheap", "ultra low cost"},
-			Context:      "163K",
-			Tier:         "mid",
-		},
-		{
-			ID:           "z-ai/glm-5",
-			Name:         "GLM-5",
-			Provider:     "Z.ai",
-			BaseURL:      OpenRouterBaseURL,
-			Capabilities: []string{"recommended", "frontier", "low cost"},
-			Context:      "202K",
-			Tier:         "frontier",
-		},
+	models, err := mergeCatalog(nil, defaultCatalog)
+	if err != nil {
+		panic("tui: bad embedded models.json: " + err.Error())
+	}
+	return models
+}
+
+// mergeCatalog applies a models.json array to models. An entry whose id is
+// already present only overrides the fields it sets; "hidden": true removes
+// a model from the picker. New entries default to OpenRouter.
+func mergeCatalog(models []ModelInfo, data []byte) ([]ModelInfo, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for i, raw := range entries {
+		var probe struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		if probe.ID == "" {
+			return nil, fmt.Errorf("entry %d: id is required", i)
+		}
+
+		idx := -1
+		for j := range models {
+			if models[j].ID == probe.ID {
+				idx = j
+				break
+			}
+		}
+		var m ModelInfo
+		if idx >= 0 {
+			m = models[idx]
+			if m.Pricing != nil {
+				pricing := *m.Pricing
+				m.Pricing = &pricing
+			}
+		}
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("entry %d (%s): %w", i, probe.ID, err)
+		}
+		if m.Name == "" {
+			m.Name = m.ID
+		}
+		if m.BaseURL == "" && !m.IsLocal {
+			m.BaseURL = OpenRouterBaseURL
+		}
+		if idx >= 0 {
+			models[idx] = m
+		} else {
+			models = append(models, m)
+		}
+	}
+	return models, nil
+}
+
+// openRouterModel is one entry of OpenRouter's /models response. Prices are
+// USD per token, as strings.
+type openRouterModel struct {
+	ID            string `json:"id"`
+	ContextLength int    `json:"context_length"`
+	Pricing       struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+// openRouterModels returns OpenRouter's model list from the cache when it is
+// fresh, and otherwise fetches and caches it. A failed fetch falls back to
+// a stale cache.
+func openRouterModels(ctx context.Context, opts CatalogOptions) ([]openRouterModel, error) {
+	ttl := opts.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCatalogTTL
+	}
+	var cached []byte
+	if opts.CacheFile != "" {
+		if info, err := os.Stat(opts.CacheFile); err == nil {
+			if data, err := os.ReadFile(opts.CacheFile); err == nil {
+				cached = data
+				if !opts.Force && time.Since(info.ModTime()) < ttl {
+					return decodeOpenRouter(cached)
+				}
+			}
+		}
+	}
+
+	data, err := fetchOpenRouter(ctx, opts.BaseURL)
+	if err != nil {
+		if cached != nil {
+			return decodeOpenRouter(cached)
+		}
+		return nil, err
+	}
+	list, err := decodeOpenRouter(data)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CacheFile != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.CacheFile), 0o755); err == nil {
+			_ = os.WriteFile(opts.CacheFile, data, 0o644)
+		}
+	}
+	return list, nil
+}
+
+func fetchOpenRouter(ctx context.Context, baseURL string) ([]byte, error) {
+	if baseURL == "" {
+		baseURL = OpenRouterBaseURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func decodeOpenRouter(data []byte) ([]openRouterModel, error) {
+	var body struct {
+		Data []openRouterModel `json:"data"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("decode OpenRouter models: %w", err)
+	}
+	return body.Data, nil
+}
+
+// applyOpenRouter copies live metadata onto catalog models. Variants such as
+// "qwen/qwen3-32b:nitro" use their base model's entry.
+func applyOpenRouter(models []ModelInfo, list []openRouterModel) {
+	byID := make(map[string]openRouterModel, len(list))
+	for _, m := range list {
+		byID[m.ID] = m
+	}
+	for i := range models {
+		m := &models[i]
+		if m.IsLocal || m.BaseURL != OpenRouterBaseURL {
+			continue
+		}
+		live, ok := byID[m.ID]
+		if !ok {
+			base, _, _ := strings.Cut(m.ID, ":")
+			if live, ok = byID[base]; !ok {
+				continue
+			}
+		}
+		if live.ContextLength > 0 {
+			m.ContextLength = live.ContextLength
+		}
+		if len(live.SupportedParameters) > 0 {
+			m.SupportedParameters = live.SupportedParameters
+		}
+		prompt, errP := strconv.ParseFloat(live.Pricing.Prompt, 64)
+		completion, errC := strconv.ParseFloat(live.Pricing.Completion, 64)
+		if errP == nil && errC == nil && prompt >= 0 && completion >= 0 {
+			m.Pricing = &ModelPricing{Input: prompt * 1e6, Output: completion * 1e6}
+		}
 	}
 }
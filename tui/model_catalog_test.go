@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultModelCatalogIsEmbedded(t *testing.T) {
+	models := DefaultModelCatalog()
+	if len(models) == 0 {
+		t.Fatal("embedded catalog is empty")
+	}
+	for _, m := range models {
+		if m.ID == "" || m.BaseURL != OpenRouterBaseURL || m.ContextLabel() == "" {
+			t.Errorf("incomplete model %+v", m)
+		}
+	}
+}
+
+func TestCatalogOverrides(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.json")
+	project := filepath.Join(dir, "project.json")
+	writeCatalog(t, user, `[
+		{"id": "anthropic/claude-haiku-4.5", "name": "Haiku", "pricing": {"input": 1, "output": 5}},
+		{"id": "openrouter/free", "hidden": true},
+		{"id": "acme/coder", "context_length": 64000}
+	]`)
+	writeCatalog(t, project, `[{"id": "acme/coder", "base_url": "https://llm.acme.test/v1"}]`)
+
+	models, err := remoteCatalog(context.Background(), CatalogOptions{Files: []string{user, project, filepath.Join(dir, "missing.json")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]ModelInfo{}
+	for _, m := range models {
+		byID[m.ID] = m
+	}
+	haiku := byID["anthropic/claude-haiku-4.5"]
+	if haiku.Name != "Haiku" || haiku.Provider != "Anthropic" || haiku.PriceLabel() != "$1/$5" || haiku.ContextLabel() != "200K" {
+		t.Errorf("overridden model = %+v", haiku)
+	}
+	if _, ok := byID["openrouter/free"]; ok {
+		t.Error("hidden model is still listed")
+	}
+	acme := byID["acme/coder"]
+	if acme.Name != "acme/coder" || acme.ContextLength != 64000 || acme.BaseURL != "https://llm.acme.test/v1" {
+		t.Errorf("added model = %+v", acme)
+	}
+	if got := DefaultModelCatalog(); got[2].Name == "Haiku" {
+		t.Error("override modified the built-in catalog")
+	}
+
+	writeCatalog(t, project, `[{"name": "no id"}]`)
+	if _, err := remoteCatalog(context.Background(), CatalogOptions{Files: []string{project}}); err == nil || !strings.Contains(err.Error(), project) {
+		t.Fatalf("err = %v, want it to name %s", err, project)
+	}
+}
+
+func TestCatalogRefreshUsesCache(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data": [
+			{"id": "qwen/qwen3-32b", "context_length": 40960, "pricing": {"prompt": "0.0000001", "completion": "0.0000003"}, "supported_parameters": ["tools"]},
+			{"id": "anthropic/claude-sonnet-4.6", "context_length": 1000000, "pricing": {"prompt": "0.000003", "completion": "0.000015"}, "supported_parameters": ["reasoning", "tools"]}
+		]}`))
+	}))
+	defer srv.Close()
+	opts := CatalogOptions{Refresh: true, BaseURL: srv.URL, CacheFile: filepath.Join(t.TempDir(), "cache.json"), CacheTTL: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		models, err := remoteCatalog(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range models {
+			switch m.ID {
+			case "qwen/qwen3-32b:nitro":
+				if m.PriceLabel() != "$0.1/$0.3" || m.SupportsReasoning() {
+					t.Errorf("variant = %+v, want its base model's price and parameters", m)
+				}
+			case "anthropic/claude-sonnet-4.6":
+				if m.PriceLabel() != "$3/$15" || !m.SupportsReasoning() {
+					t.Errorf("sonnet = %+v", m)
+				}
+			}
+		}
+	}
+	if requests != 1 {
+		t.Errorf("fetched %d times, want the second load served from the cache", requests)
+	}
+
+	srv.Close()
+	opts.Force = true
+	if models, err := remoteCatalog(context.Background(), opts); err != nil || models[0].Pricing == nil {
+		t.Errorf("stale cache fallback: err = %v", err)
+	}
+}
+
+func writeCatalog(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,8 @@ package tui
 import (
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,12 +19,80 @@ type ModelInfo struct {
 	Name         string   `json:"name"`
 	Provider     string   `json:"provider"`
 	Capabilities []string `json:"capabilities"`
-	Context      string   `json:"context"`
-	Tier         string   `json:"tier"`
+	// Context is a display label such as "varies"; ContextLength, in
+	// tokens, wins when set.
+	Context       string `json:"context,omitempty"`
+	ContextLength int    `json:"context_length,omitempty"`
+	Tier          string `json:"tier"`
+	// Pricing is nil when unknown.
+	Pricing *ModelPricing `json:"pricing,omitempty"`
+	// SupportedParameters lists the request parameters the model accepts,
+	// such as "reasoning" and "tools". Empty means unknown.
+	SupportedParameters []string `json:"supported_parameters,omitempty"`
 	// BaseURL is the API endpoint for this model. Every model should set this explicitly.
 	BaseURL string `json:"base_url,omitempty"`
 	// IsLocal indicates this is a locally hosted model.
 	IsLocal bool `json:"is_local,omitempty"`
+	// Hidden removes a built-in model from the picker in a models.json override.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+// ModelPricing is the price in USD per million tokens.
+type ModelPricing struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// String renders the price as "$in/$out" per million tokens.
+func (p ModelPricing) String() string {
+	if p.Input == 0 && p.Output == 0 {
+		return "free"
+	}
+	return "$" + formatPrice(p.Input) + "/$" + formatPrice(p.Output)
+}
+
+func formatPrice(usd float64) string {
+	s := strconv.FormatFloat(usd, 'f', 2, 64)
+	return strings.TrimSuffix(strings.TrimSuffix(s, "0"), ".0")
+}
+
+// ContextLabel returns the context window for display, such as "200K".
+func (m ModelInfo) ContextLabel() string {
+	switch n := m.ContextLength; {
+	case n >= 1_000_000:
+		return strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(float64(n)/1e6, 'f', 2, 64), "0"), ".") + "M"
+	case n >= 1000:
+		return strconv.Itoa((n+500)/1000) + "K"
+	case n > 0:
+		return strconv.Itoa(n)
+	}
+	return m.Context
+}
+
+// PriceLabel returns the price for display, or "" when unknown.
+func (m ModelInfo) PriceLabel() string {
+	if m.IsLocal {
+		return "local"
+	}
+	if m.Pricing == nil {
+		return ""
+	}
+	return m.Pricing.String()
+}
+
+// SupportsReasoning reports whether the model accepts a reasoning effort.
+// Models with unknown parameters are assumed to.
+func (m ModelInfo) SupportsReasoning() bool {
+	if len(m.SupportedParameters) == 0 {
+		return true
+	}
+	return slices.Contains(m.SupportedParameters, "reasoning") || slices.Contains(m.SupportedParameters, "include_reasoning")
+}
+
+// SupportsTools reports whether the model can make tool calls. Models with
+// unknown parameters are assumed to.
+func (m ModelInfo) SupportsTools() bool {
+	return len(m.SupportedParameters) == 0 || slices.Contains(m.SupportedParameters, "tools")
 }
 
 // ModelSelectedMsg is sent when a model is selected from the picker.
@@ -31,13 +101,31 @@ type ModelSelectedMsg struct {
 }
 
 // ModelModal is a picker that displays available models for selection.
+// With an Ollama provider it also pulls and deletes models; see
+// model_actions.go.
 type ModelModal struct {
-	models   []ModelInfo
-	selected int
-	active   bool
-	width    int
+	models    []ModelInfo
+	providers []ProviderStatus
+	selected  int
+	active    bool
+	width     int
+
+	mode     modelModalMode
+	pullName string     // typed in modePullName
+	deleting ModelInfo  // the model modeConfirmDelete asks about
+	pull     *pullState // the pull in progress, if any
+	notice   string     // outcome of the last action
 }
 
+// modelModalMode is what the modal's keys currently do.
+type modelModalMode int
+
+const (
+	modeList modelModalMode = iota
+	modePullName
+	modeConfirmDelete
+)
+
 // NewModelModal creates a new model picker.
 func NewModelModal(models []ModelInfo) ModelModal {
 	return ModelModal{
@@ -45,6 +133,23 @@ func NewModelModal(models []ModelInfo) ModelModal {
 	}
 }
 
+// SetProviders records how local model discovery went, so the picker can
+// list providers that could not be reached.
+func (mm *ModelModal) SetProviders(status []ProviderStatus) {
+	mm.providers = status
+}
+
+// unreachable returns the providers whose discovery failed.
+func (mm ModelModal) unreachable() []ProviderStatus {
+	var failed []ProviderStatus
+	for _, s := range mm.providers {
+		if s.Err != nil {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
 // IsActive returns whether the modal is visible.
 func (mm ModelModal) IsActive() bool {
 	return mm.active
@@ -55,6 +160,8 @@ func (mm *ModelModal) Show() {
 	log.Info("ModelModal activated")
 	mm.active = true
 	mm.selected = 0
+	mm.mode = modeList
+	mm.notice = ""
 }
 
 // Hide deactivates the modal.
@@ -77,7 +184,7 @@ func (mm ModelModal) Height() int {
 	if h > 14 {
 		h = 14
 	}
-	return h
+	return h + len(mm.footer())
 }
 
 // HandleKey handles keyboard input when the modal is active.
@@ -85,6 +192,12 @@ func (mm *ModelModal) HandleKey(msg tea.KeyMsg) (cmd tea.Cmd, handled bool) {
 	if !mm.active {
 		return nil, false
 	}
+	switch mm.mode {
+	case modePullName:
+		return mm.handlePullNameKey(msg), true
+	case modeConfirmDelete:
+		return mm.handleConfirmDeleteKey(msg), true
+	}
 
 	switch msg.Type {
 	case tea.KeyUp:
@@ -110,6 +223,9 @@ func (mm *ModelModal) HandleKey(msg tea.KeyMsg) (cmd tea.Cmd, handled bool) {
 	case tea.KeyEsc:
 		mm.active = false
 		return nil, true
+
+	case tea.KeyRunes:
+		return mm.handleActionKey(string(msg.Runes))
 	}
 
 	return nil, false
@@ -127,20 +243,28 @@ func (mm ModelModal) View(styles Styles) string {
 	var items []string
 	for i, m := range mm.models {
 		caps := strings.Join(m.Capabilities, ", ")
-		line := fmt.Sprintf("%-22s  %-10s  ctx:%s  [%s]", m.Name, m.Provider, m.Context, caps)
+		price := m.PriceLabel()
+		if price != "" {
+			price = "  " + price
+		}
+		line := fmt.Sprintf("%-22s  %-10s  ctx:%s%s  [%s]", m.Name, m.Provider, m.ContextLabel(), price, caps)
 
 		if i == mm.selected {
 			item := styles.SlashItemSelected.Render("▸ " + line)
 			items = append(items, item)
 		} else {
 			name := styles.SlashCommand.Render("  " + m.Name)
-			rest := fmt.Sprintf("  %-10s  ctx:%s  [%s]", m.Provider, m.Context, caps)
+			rest := fmt.Sprintf("  %-10s  ctx:%s%s  [%s]", m.Provider, m.ContextLabel(), price, caps)
 			_ = capsStyle
 			item := name + tierStyle.Render(rest)
 			items = append(items, item)
 		}
 	}
 
+	for _, line := range mm.footer() {
+		items = append(items, tierStyle.Render(line))
+	}
+
 	content := strings.Join(items, "\n")
 	style := styles.SlashModal
 	if mm.width > 0 {
@@ -4,18 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// OllamaAPIURL is the default base URL for the Ollama API.
-// Used for the /api/tags endpoint to list models.
-const OllamaAPIURL = "http://127.0.0.1:11434"
-
-// OllamaOpenAIBaseURL is the base URL for Ollama's OpenAI-compatible API.
-// The /v1 prefix is required for OpenAI-compatible endpoints like /v1/chat/completions.
-const OllamaOpenAIBaseURL = "http://127.0.0.1:11434/v1"
-
 // OllamaModel represents a model from the Ollama API.
 type OllamaModel struct {
 	Name      string   `json:"name"`
@@ -41,47 +37,159 @@ type OllamaTagsResponse struct {
 	Models []OllamaModel `json:"models"`
 }
 
-// FetchOllamaModels queries the local Ollama API for available models.
-// It returns nil if Ollama is not available.
-func FetchOllamaModels(ctx context.Context) []ModelInfo {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", OllamaAPIURL+"/api/tags", nil)
-	if err != nil {
-		return nil
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil
-	}
+// OllamaShowResponse is the part of the /api/show response bono reads.
+type OllamaShowResponse struct {
+	Parameters   string             `json:"parameters"`
+	Template     string             `json:"template"`
+	Details      OllamaModelDetails `json:"details"`
+	ModelInfo    map[string]any     `json:"model_info"`
+	Capabilities []string           `json:"capabilities"`
+}
 
+// fetchOllamaModels lists the models on an Ollama server, filling in each
+// model's context length, capabilities and quantization from /api/show. A
+// model whose details cannot be read keeps what /api/tags says.
+func fetchOllamaModels(ctx context.Context, p Provider) ([]ModelInfo, error) {
 	var tagsResp OllamaTagsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tagsResp); err != nil {
-		return nil
+	if err := getJSON(ctx, p.ModelsURL(), &tagsResp); err != nil {
+		return nil, err
 	}
 
-	models := make([]ModelInfo, 0, len(tagsResp.Models))
-	for _, m := range tagsResp.Models {
-		models = append(models, ModelInfo{
+	models := make([]ModelInfo, len(tagsResp.Models))
+	var wg sync.WaitGroup
+	for i, m := range tagsResp.Models {
+		models[i] = ModelInfo{
 			ID:           m.Name,
 			Name:         formatOllamaModelName(m.Name),
-			Provider:     "Ollama",
+			Provider:     p.Name,
 			Capabilities: ollamaCapabilities(m),
-			Context:      ollamaContextSize(m),
+			Context:      "varies",
 			Tier:         "local",
-			BaseURL:      OllamaOpenAIBaseURL,
+			BaseURL:      p.BaseURL(),
 			IsLocal:      true,
-		})
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var show OllamaShowResponse
+			if err := requestJSON(ctx, http.MethodPost, p.URL+"/api/show", map[string]string{"model": m.Name}, &show); err != nil {
+				log.Warn("ollama show failed", "model", m.Name, "error", err)
+				return
+			}
+			applyOllamaShow(&models[i], m, show)
+		}()
+	}
+	wg.Wait()
+	return slices.DeleteFunc(models, func(m ModelInfo) bool { return m.Hidden }), nil
+}
+
+// ollamaChatParameters are the request parameters Ollama's OpenAI-compatible
+// API accepts for every model.
+var ollamaChatParameters = []string{"max_tokens", "seed", "stop", "temperature", "top_p"}
+
+// applyOllamaShow fills in info from a model's /api/show response. The
+// context length is the num_ctx the model is configured with, or else the
+// length it was trained for. Older servers without a capabilities list get
+// tool support from the chat template. Models that cannot chat, such as
+// embedding models, are hidden.
+func applyOllamaShow(info *ModelInfo, m OllamaModel, show OllamaShowResponse) {
+	if show.Capabilities != nil && !slices.Contains(show.Capabilities, "completion") {
+		info.Hidden = true
+		return
+	}
+	if n := ollamaNumCtx(show.Parameters); n > 0 {
+		info.ContextLength = n
+	} else if arch, _ := show.ModelInfo["general.architecture"].(string); arch != "" {
+		if n, ok := show.ModelInfo[arch+".context_length"].(float64); ok && n > 0 {
+			info.ContextLength = int(n)
+		}
+	}
+
+	caps := show.Capabilities
+	if caps == nil && strings.Contains(show.Template, ".Tools") {
+		caps = []string{"tools"}
+	}
+	if show.Details.QuantizationLevel != "" {
+		m.Details.QuantizationLevel = show.Details.QuantizationLevel
+	}
+	info.Capabilities = ollamaCapabilities(m)
+	info.SupportedParameters = slices.Clone(ollamaChatParameters)
+	for _, c := range caps {
+		switch c {
+		case "tools":
+			info.SupportedParameters = append(info.SupportedParameters, "tools")
+		case "thinking":
+			info.SupportedParameters = append(info.SupportedParameters, "reasoning")
+		case "vision":
+		default:
+			continue
+		}
+		info.Capabilities = append(info.Capabilities, c)
 	}
+	if !info.SupportsTools() {
+		info.Capabilities = append(info.Capabilities, "no tools")
+	}
+}
+
+// ollamaNumCtx returns the num_ctx setting from a model's parameters, or 0.
+func ollamaNumCtx(parameters string) int {
+	for _, line := range strings.Split(parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
 
-	return models
+// OllamaPullProgress is one line of the /api/pull stream. Total and
+// Completed are bytes of the layer named by Digest.
+type OllamaPullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullOllamaModel downloads name onto the Ollama server, calling progress
+// for every status line it streams. It returns when the pull succeeds,
+// fails or ctx is cancelled.
+func PullOllamaModel(ctx context.Context, p Provider, name string, progress func(OllamaPullProgress)) error {
+	resp, err := sendJSON(ctx, http.MethodPost, p.URL+"/api/pull", map[string]any{"model": name, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var line OllamaPullProgress
+		if err := dec.Decode(&line); err == io.EOF {
+			return fmt.Errorf("pull %s: stream ended before success", name)
+		} else if err != nil {
+			return fmt.Errorf("pull %s: %w", name, err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("pull %s: %s", name, line.Error)
+		}
+		if progress != nil {
+			progress(line)
+		}
+		if line.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// DeleteOllamaModel removes name from the Ollama server.
+func DeleteOllamaModel(ctx context.Context, p Provider, name string) error {
+	if err := requestJSON(ctx, http.MethodDelete, p.URL+"/api/delete", map[string]string{"model": name}, nil); err != nil {
+		return fmt.Errorf("delete %s: %w", name, err)
+	}
+	return nil
 }
 
 // formatOllamaModelName converts "modelname:tag" to "Model Name (tag)".
@@ -129,24 +237,14 @@ func toUpper(r rune) rune {
 	return r
 }
 
-// ollamaCapabilities returns a list of capabilities for an Ollama model.
+// ollamaCapabilities returns the labels shown for an Ollama model: its
+// family, size and quantization.
 func ollamaCapabilities(m OllamaModel) []string {
-	caps := []string{"local", "offline"}
-	
-	if m.Details.Family != "" {
-		caps = append(caps, m.Details.Family)
-	}
-	
-	if m.Details.ParameterSize != "" {
-		caps = append(caps, m.Details.ParameterSize)
+	caps := []string{"local"}
+	for _, c := range []string{m.Details.Family, m.Details.ParameterSize, m.Details.QuantizationLevel} {
+		if c != "" {
+			caps = append(caps, c)
+		}
 	}
-	
 	return caps
 }
-
-// ollamaContextSize returns a default context size for Ollama models.
-// Ollama typically uses 4096 by default, but this varies by model.
-func ollamaContextSize(m OllamaModel) string {
-	// Return a reasonable default - actual context size varies by model
-	return "4K-128K"
-}
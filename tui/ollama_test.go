@@ -2,38 +2,137 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// fakeOllama serves /api/tags, /api/show, /api/pull and /api/delete for
+// the given model names. Names containing "embed" are embedding models and
+// names containing "chat" cannot call tools. Pulling a name containing
+// "missing" fails.
+func fakeOllama(t *testing.T, names ...string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var req struct{ Model string }
+		if r.Method != http.MethodGet {
+			json.NewDecoder(r.Body).Decode(&req)
+		}
+		switch r.URL.Path {
+		case "/api/tags":
+			var resp OllamaTagsResponse
+			for _, name := range names {
+				resp.Models = append(resp.Models, OllamaModel{Name: name, Details: OllamaModelDetails{Family: "qwen3", ParameterSize: "8B"}})
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/api/show":
+			if r.Method != http.MethodPost || !slices.Contains(names, req.Model) {
+				http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+				return
+			}
+			resp := OllamaShowResponse{
+				Parameters:   "stop \"<|im_end|>\"\nnum_ctx 16384",
+				Details:      OllamaModelDetails{QuantizationLevel: "Q4_K_M"},
+				ModelInfo:    map[string]any{"general.architecture": "qwen3", "qwen3.context_length": 40960},
+				Capabilities: []string{"completion", "tools", "thinking"},
+			}
+			switch {
+			case strings.Contains(req.Model, "embed"):
+				resp.Capabilities = []string{"embedding"}
+			case strings.Contains(req.Model, "chat"):
+				resp.Capabilities = []string{"completion"}
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/api/pull":
+			enc := json.NewEncoder(w)
+			enc.Encode(OllamaPullProgress{Status: "pulling manifest"})
+			if strings.Contains(req.Model, "missing") {
+				enc.Encode(OllamaPullProgress{Error: "pull model manifest: file does not exist"})
+				return
+			}
+			enc.Encode(OllamaPullProgress{Status: "pulling 6a0746a1ec1a", Digest: "sha256:6a0746a1ec1a", Total: 2_000_000_000, Completed: 500_000_000})
+			enc.Encode(OllamaPullProgress{Status: "success"})
+			names = append(names, req.Model)
+		case "/api/delete":
+			i := slices.Index(names, req.Model)
+			if r.Method != http.MethodDelete || i < 0 {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+				return
+			}
+			names = slices.Delete(names, i, i+1)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
 func TestFetchOllamaModels(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	srv := fakeOllama(t, "qwen3:8b", "nomic-embed-text:latest", "llama2-chat:7b")
+	p := Provider{Name: "Ollama", Kind: ProviderOllama, URL: srv.URL}
 
-	models := FetchOllamaModels(ctx)
-	
-	if len(models) == 0 {
-		t.Log("No Ollama models found - Ollama may not be running")
-		return
-	}
-	
-	t.Logf("Found %d Ollama models:", len(models))
-	for _, m := range models {
-		t.Logf("  - %s (Provider: %s, ID: %s, BaseURL: %s, IsLocal: %v)", 
-			m.Name, m.Provider, m.ID, m.BaseURL, m.IsLocal)
+	models, err := fetchOllamaModels(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("got %d models, want the embedding model hidden: %v", len(models), models)
 	}
-	
-	// Verify each model has the expected properties
 	for _, m := range models {
-		if m.Provider != "Ollama" {
-			t.Errorf("Expected provider 'Ollama', got '%s'", m.Provider)
+		if m.Provider != "Ollama" || !m.IsLocal || m.BaseURL != srv.URL+"/v1" {
+			t.Errorf("model %s: provider %q, local %v, base URL %q", m.ID, m.Provider, m.IsLocal, m.BaseURL)
 		}
-		if !m.IsLocal {
-			t.Errorf("Expected IsLocal to be true")
+		if m.ContextLength != 16384 {
+			t.Errorf("model %s: context length %d, want num_ctx 16384", m.ID, m.ContextLength)
 		}
-		if m.BaseURL == "" {
-			t.Errorf("Expected BaseURL to be set")
+	}
+
+	qwen, chat := models[0], models[1]
+	if qwen.Name != "Qwen3 (8b)" {
+		t.Errorf("name = %q", qwen.Name)
+	}
+	if !qwen.SupportsTools() || !qwen.SupportsReasoning() || !slices.Contains(qwen.Capabilities, "Q4_K_M") {
+		t.Errorf("qwen3: capabilities %v, parameters %v", qwen.Capabilities, qwen.SupportedParameters)
+	}
+	if chat.SupportsTools() || chat.SupportsReasoning() || !slices.Contains(chat.Capabilities, "no tools") {
+		t.Errorf("llama2-chat: capabilities %v, parameters %v", chat.Capabilities, chat.SupportedParameters)
+	}
+}
+
+func TestApplyOllamaShowFallbacks(t *testing.T) {
+	// Older servers send no capabilities and no num_ctx.
+	var info ModelInfo
+	applyOllamaShow(&info, OllamaModel{Name: "mistral:7b"}, OllamaShowResponse{
+		Template:  "{{ if .Tools }}[AVAILABLE_TOOLS] {{ json .Tools }}{{ end }}",
+		ModelInfo: map[string]any{"general.architecture": "llama", "llama.context_length": float64(32768)},
+	})
+	if info.ContextLength != 32768 || !info.SupportsTools() || info.SupportsReasoning() || info.Hidden {
+		t.Errorf("info = %+v", info)
+	}
+
+	// A failed /api/show keeps the tag details and assumes tools work.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			w.Write([]byte(`{"models":[{"name":"phi4:latest"}]}`))
+			return
 		}
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	models, err := fetchOllamaModels(context.Background(), Provider{Name: "Ollama", Kind: ProviderOllama, URL: srv.URL})
+	if err != nil || len(models) != 1 || !models[0].SupportsTools() || models[0].ContextLabel() != "varies" {
+		t.Errorf("models = %+v, err = %v", models, err)
 	}
 }
 
@@ -41,11 +140,12 @@ func TestLoadModelCatalog(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	models := LoadModelCatalog(ctx)
-	
-	t.Logf("Total models in catalog: %d", len(models))
-	
-	// Check that we have remote models
+	srv := fakeOllama(t, "qwen3:8b")
+	models, status, err := LoadModelCatalog(ctx, CatalogOptions{Providers: []Provider{{Name: "Ollama", Kind: ProviderOllama, URL: srv.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	remoteCount := 0
 	localCount := 0
 	for _, m := range models {
@@ -55,13 +155,40 @@ func TestLoadModelCatalog(t *testing.T) {
 			remoteCount++
 		}
 	}
-	
-	t.Logf("Remote models: %d, Local (Ollama) models: %d", remoteCount, localCount)
-	
 	if remoteCount == 0 {
 		t.Error("Expected at least one remote model")
 	}
-	// localCount may be 0 if Ollama is not running
+	if localCount != 1 || len(status) != 1 || status[0].Err != nil || status[0].Models != 1 {
+		t.Errorf("local models = %d, status = %v", localCount, status)
+	}
+}
+
+func TestPullAndDeleteOllamaModel(t *testing.T) {
+	srv := fakeOllama(t, "qwen3:8b")
+	p := Provider{Name: "Ollama", Kind: ProviderOllama, URL: srv.URL}
+	ctx := context.Background()
+
+	var statuses []string
+	if err := PullOllamaModel(ctx, p, "gemma3:4b", func(u OllamaPullProgress) { statuses = append(statuses, u.Status) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pulling manifest", "pulling 6a0746a1ec1a", "success"}; !slices.Equal(statuses, want) {
+		t.Errorf("progress = %q, want %q", statuses, want)
+	}
+	if err := PullOllamaModel(ctx, p, "missing:latest", nil); err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("pulling a missing model: err = %v", err)
+	}
+
+	if err := DeleteOllamaModel(ctx, p, "qwen3:8b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteOllamaModel(ctx, p, "qwen3:8b"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("deleting twice: err = %v", err)
+	}
+	models, err := fetchOllamaModels(ctx, p)
+	if err != nil || len(models) != 1 || models[0].ID != "gemma3:4b" {
+		t.Errorf("models after pull and delete = %v, %v", models, err)
+	}
 }
 
 func TestFormatOllamaModelName(t *testing.T) {
@@ -0,0 +1,254 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderKind says how a local model server lists its models.
+type ProviderKind string
+
+const (
+	// ProviderOllama lists models with GET /api/tags and serves them from
+	// its OpenAI-compatible /v1 API.
+	ProviderOllama ProviderKind = "ollama"
+	// ProviderOpenAI is any OpenAI-compatible server, such as llama.cpp's
+	// server, LM Studio or vLLM, listing models with GET /models.
+	ProviderOpenAI ProviderKind = "openai"
+)
+
+// Provider is a local model server to discover models on.
+type Provider struct {
+	Name string
+	Kind ProviderKind
+	// URL is the Ollama API root, or the OpenAI-compatible base URL
+	// including its /v1 prefix.
+	URL string
+}
+
+// ModelsURL returns the endpoint that lists the provider's models.
+func (p Provider) ModelsURL() string {
+	if p.Kind == ProviderOllama {
+		return p.URL + "/api/tags"
+	}
+	return p.URL + "/models"
+}
+
+// BaseURL returns the OpenAI-compatible base URL the agent talks to.
+func (p Provider) BaseURL() string {
+	if p.Kind == ProviderOllama {
+		return p.URL + "/v1"
+	}
+	return p.URL
+}
+
+// ProviderStatus is the outcome of discovering models on one provider.
+type ProviderStatus struct {
+	Provider Provider
+	Models   int
+	Err      error
+}
+
+// String describes the outcome for the model picker and bono models.
+func (s ProviderStatus) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("%s unreachable at %s: %v", s.Provider.Name, s.Provider.URL, s.Err)
+	}
+	return fmt.Sprintf("%s: %d models at %s", s.Provider.Name, s.Models, s.Provider.URL)
+}
+
+// LocalProviders builds the providers to discover. ollama is an Ollama host
+// in OLLAMA_HOST form ("127.0.0.1:11434", "http://host", "0.0.0.0"); empty
+// disables Ollama. openai is a comma-separated list of OpenAI-compatible
+// base URLs, each optionally named as name=url.
+func LocalProviders(ollama, openai string) ([]Provider, error) {
+	var providers []Provider
+	if ollama = strings.TrimSpace(ollama); ollama != "" {
+		u, err := ollamaURL(ollama)
+		if err != nil {
+			return nil, fmt.Errorf("ollama host %q: %w", ollama, err)
+		}
+		providers = append(providers, Provider{Name: "Ollama", Kind: ProviderOllama, URL: u})
+	}
+	for _, entry := range strings.Split(openai, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok || strings.ContainsAny(name, ":/") {
+			name, raw = "", entry
+		}
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("OpenAI-compatible server %q: want a base URL such as http://127.0.0.1:1234/v1", entry)
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			name = u.Host
+		}
+		providers = append(providers, Provider{Name: name, Kind: ProviderOpenAI, URL: strings.TrimSuffix(u.String(), "/")})
+	}
+	return providers, nil
+}
+
+// ollamaURL turns an OLLAMA_HOST value into the API root URL, filling in
+// the scheme and default port and replacing a listen-on-all address with
+// loopback.
+func ollamaURL(host string) (string, error) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	hostname, port := u.Hostname(), u.Port()
+	if hostname == "" || hostname == "0.0.0.0" {
+		hostname = "127.0.0.1"
+	}
+	if port == "" {
+		port = "11434"
+	}
+	u.Host = net.JoinHostPort(hostname, port)
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// DiscoverLocalModels queries every provider concurrently and returns their
+// models in provider order, with one status per provider.
+func DiscoverLocalModels(ctx context.Context, providers []Provider) ([]ModelInfo, []ProviderStatus) {
+	found := make([][]ModelInfo, len(providers))
+	status := make([]ProviderStatus, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetch := fetchOpenAIModels
+			if p.Kind == ProviderOllama {
+				fetch = fetchOllamaModels
+			}
+			models, err := fetch(ctx, p)
+			found[i] = models
+			status[i] = ProviderStatus{Provider: p, Models: len(models), Err: err}
+		}()
+	}
+	wg.Wait()
+
+	var models []ModelInfo
+	for _, m := range found {
+		models = append(models, m...)
+	}
+	return models, status
+}
+
+// getJSON decodes the JSON response of a GET to rawURL into v.
+func getJSON(ctx context.Context, rawURL string, v any) error {
+	return requestJSON(ctx, http.MethodGet, rawURL, nil, v)
+}
+
+// requestJSON sends body, if any, as JSON and decodes the response into v
+// unless v is nil.
+func requestJSON(ctx context.Context, method, rawURL string, body, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := sendJSON(ctx, method, rawURL, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// sendJSON sends body, if any, as JSON and returns a 200 response for the
+// caller to read and close. Other statuses are errors, quoting the error
+// message of a JSON error body.
+func sendJSON(ctx context.Context, method, rawURL string, body any) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Drop the `Get "url":` prefix; the status names the provider.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s: %s: %s", rawURL, resp.Status, apiErr.Error)
+		}
+		return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+// openAIModel is one entry of an OpenAI-compatible /models response.
+// vLLM adds max_model_len.
+type openAIModel struct {
+	ID          string `json:"id"`
+	MaxModelLen int    `json:"max_model_len,omitempty"`
+}
+
+func fetchOpenAIModels(ctx context.Context, p Provider) ([]ModelInfo, error) {
+	var body struct {
+		Data []openAIModel `json:"data"`
+	}
+	if err := getJSON(ctx, p.ModelsURL(), &body); err != nil {
+		return nil, err
+	}
+	models := make([]ModelInfo, 0, len(body.Data))
+	for _, m := range body.Data {
+		if m.ID == "" {
+			continue
+		}
+		models = append(models, ModelInfo{
+			ID:            m.ID,
+			Name:          m.ID,
+			Provider:      p.Name,
+			Capabilities:  []string{"local"},
+			Context:       "varies",
+			ContextLength: m.MaxModelLen,
+			Tier:          "local",
+			BaseURL:       p.BaseURL(),
+			IsLocal:       true,
+		})
+	}
+	return models, nil
+}
@@ -0,0 +1,88 @@
+package tui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalProviders(t *testing.T) {
+	providers, err := LocalProviders("0.0.0.0", "http://127.0.0.1:8080/v1, lmstudio=http://127.0.0.1:1234/v1/,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Provider{
+		{Name: "Ollama", Kind: ProviderOllama, URL: "http://127.0.0.1:11434"},
+		{Name: "127.0.0.1:8080", Kind: ProviderOpenAI, URL: "http://127.0.0.1:8080/v1"},
+		{Name: "lmstudio", Kind: ProviderOpenAI, URL: "http://127.0.0.1:1234/v1"},
+	}
+	if len(providers) != len(want) {
+		t.Fatalf("got %v, want %v", providers, want)
+	}
+	for i := range want {
+		if providers[i] != want[i] {
+			t.Errorf("provider %d = %+v, want %+v", i, providers[i], want[i])
+		}
+	}
+
+	hosts := map[string]string{
+		"127.0.0.1:11434":      "http://127.0.0.1:11434",
+		"https://ollama.lan":   "https://ollama.lan:11434",
+		"http://gpu-box:9000/": "http://gpu-box:9000",
+	}
+	for host, want := range hosts {
+		providers, err := LocalProviders(host, "")
+		if err != nil || len(providers) != 1 || providers[0].URL != want {
+			t.Errorf("LocalProviders(%q) = %v, %v; want URL %s", host, providers, err, want)
+		}
+	}
+	if providers, _ := LocalProviders("", ""); len(providers) != 0 {
+		t.Errorf("empty settings gave %v", providers)
+	}
+	if _, err := LocalProviders("", "lmstudio=localhost:1234"); err == nil || !strings.Contains(err.Error(), "lmstudio=localhost:1234") {
+		t.Errorf("bad URL error = %v", err)
+	}
+}
+
+func TestDiscoverLocalModelsReportsUnreachableProviders(t *testing.T) {
+	vllm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"Qwen/Qwen3-32B","max_model_len":32768},{"id":"qwen3-8b"}]}`))
+	}))
+	defer vllm.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	ollama := fakeOllama(t, "llama3:latest")
+
+	providers := []Provider{
+		{Name: "vllm", Kind: ProviderOpenAI, URL: vllm.URL + "/v1"},
+		{Name: "lmstudio", Kind: ProviderOpenAI, URL: down.URL + "/v1"},
+		{Name: "Ollama", Kind: ProviderOllama, URL: ollama.URL},
+	}
+	models, status := DiscoverLocalModels(context.Background(), providers)
+
+	if len(models) != 3 {
+		t.Fatalf("got %d models, want 3: %v", len(models), models)
+	}
+	if m := models[0]; m.ID != "Qwen/Qwen3-32B" || m.Provider != "vllm" || m.BaseURL != vllm.URL+"/v1" || m.ContextLength != 32768 || !m.IsLocal {
+		t.Errorf("vLLM model = %+v", m)
+	}
+	if m := models[2]; m.ID != "llama3:latest" || m.BaseURL != ollama.URL+"/v1" {
+		t.Errorf("Ollama model = %+v", m)
+	}
+
+	if len(status) != 3 {
+		t.Fatalf("got %d statuses, want 3", len(status))
+	}
+	if status[0].Err != nil || status[0].Models != 2 || status[2].Err != nil || status[2].Models != 1 {
+		t.Errorf("reachable statuses = %v, %v", status[0], status[2])
+	}
+	if status[1].Err == nil || !strings.Contains(status[1].String(), "lmstudio unreachable at "+down.URL) {
+		t.Errorf("down provider status = %q", status[1])
+	}
+}
@@ -34,6 +34,8 @@ func (f *SessionFrontend) HandleEvent(_ context.Context, event session.Event) {
 			Status:    event.Status,
 			Sandboxed: event.Sandboxed,
 		})
+	case session.ToolDeniedEvent:
+		f.program.Send(AgentToolDeniedMsg{Name: event.Name, Args: event.Args, Rule: event.Rule})
 	case session.DiffPreviewEvent:
 		f.program.Send(AgentDiffPreviewMsg{
 			RelPath:    event.RelPath,
@@ -50,6 +52,8 @@ func (f *SessionFrontend) HandleEvent(_ context.Context, event session.Event) {
 		f.program.Send(SubAgentEndMsg(event.Name))
 	case session.ErrorEvent:
 		f.program.Send(AgentErrorMsg{Err: event.Err})
+	case session.HookErrorEvent:
+		f.program.Send(AgentErrorMsg{Err: event.Err})
 	case session.ContextUsageEvent:
 		f.program.Send(AgentContextUsageMsg{Pct: event.Pct, TotalCost: event.TotalCost})
 	case session.ContentDeltaEvent:
@@ -58,6 +62,10 @@ func (f *SessionFrontend) HandleEvent(_ context.Context, event session.Event) {
 		f.program.Send(AgentReasoningDeltaMsg(event.Delta))
 	case session.ResponseModelEvent:
 		f.program.Send(AgentResponseModelMsg{ModelID: event.ModelID})
+	case session.RoleEvent:
+		f.program.Send(AgentRoleMsg{Event: event})
+	case session.RetryEvent:
+		f.program.Send(AgentRetryMsg{Event: event})
 	case session.RefreshGitStatusEvent:
 		f.program.Send(GitStatusMsg{Status: FetchGitStatus()})
 	case session.UserPromptEvent:
@@ -75,6 +83,8 @@ func (f *SessionFrontend) RequestApproval(ctx context.Context, req session.Appro
 			Args:            req.ToolArgs,
 			Approved:        approved,
 			ExecutionReason: req.ExecutionReason,
+			Grants:          req.Grants,
+			Choose:          req.Choose,
 		})
 	case session.ApprovalSandboxFallback:
 		f.program.Send(AgentSandboxFallbackMsg{
@@ -86,6 +96,12 @@ func (f *SessionFrontend) RequestApproval(ctx context.Context, req session.Appro
 		f.program.Send(AgentChangeBatchApprovalMsg{
 			Count:    req.ChangeCount,
 			Approved: approved,
+			Decide:   req.Decide,
+		})
+	case session.ApprovalUndoConflict:
+		f.program.Send(AgentUndoConflictMsg{
+			Changes:  req.Changes,
+			Approved: approved,
 		})
 	default:
 		return false
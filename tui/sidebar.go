@@ -33,6 +33,8 @@ type GitStatus struct {
 // Sidebar displays session metadata on the right side of the TUI.
 type Sidebar struct {
 	modelName       string
+	profile         string // selected config profile, "" = none
+	phase           string // role whose model is active, "" = main
 	contextUsagePct float64
 	totalCost       float64
 	cwd             string
@@ -59,6 +61,8 @@ func (s *Sidebar) SetHeight(h int)             { s.height = h }
 func (s *Sidebar) SetGitStatus(g GitStatus)        { s.git = g }
 func (s *Sidebar) SetReasoningEffort(effort string) { s.reasoningEffort = effort }
 func (s *Sidebar) SetCurrentMode(mode string)        { s.currentMode = mode }
+func (s *Sidebar) SetProfile(name string)            { s.profile = name }
+func (s *Sidebar) SetPhase(role string)              { s.phase = role }
 
 // SetIndexStats updates the workspace index information.
 func (s *Sidebar) SetIndexStats(files int) {
@@ -129,8 +133,22 @@ func (s Sidebar) sections() []SidebarSection {
 			Color: lipgloss.Color("86"),
 		})
 	}
+	if s.phase != "" {
+		session.Items = append(session.Items, SidebarItem{
+			Text:  "↳ " + s.phase + " role",
+			Color: lipgloss.Color("241"),
+		})
+	}
 	sections = append(sections, session)
 
+	// PROFILE
+	if s.profile != "" {
+		sections = append(sections, SidebarSection{
+			Header: "PROFILE (/profile)",
+			Items:  []SidebarItem{{Text: s.profile, Color: lipgloss.Color("86")}},
+		})
+	}
+
 	// REASONING
 	reasoning := SidebarSection{Header: "REASONING (/reasoning)"}
 	for _, level := range DefaultReasoningLevels() {
@@ -2,10 +2,14 @@ package tui
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/changebatch"
 )
 
 type SlashCommandSpec struct {
@@ -22,6 +26,10 @@ const helpText = `Available commands:
   /clear             - Clear chat history
   /model             - Show current model
   /reasoning <level> - Set reasoning effort (xhigh/high/medium/low/minimal/none)
+  /permissions       - List permission rules; /permissions revoke <n> removes one
+  /history           - List approved change batches
+  /undo [n]          - Undo the latest change batch, or batch n from /history
+  /export-patch [n]  - Save the latest change batch, or batch n, as a patch file
   /spinner           - Cycle to next spinner style
   /spinner <type>    - Set spinner (dot, line, minidot, jump, pulse, points, globe, moon, monkey, meter, hamburger, ellipsis)
   /exit              - Exit Bono`
@@ -35,6 +43,11 @@ func DefaultSlashCommandSpecs() []SlashCommandSpec {
 		{Name: "clear", Description: "Clear the chat history", Handler: handleClear},
 		{Name: "model", Description: "Switch AI model", Handler: handleModel},
 		{Name: "reasoning", Description: "Set reasoning effort level", Handler: handleReasoning},
+		{Name: "profile", Description: "List or switch config profiles", Handler: handleProfile},
+		{Name: "permissions", Description: "List or revoke permission rules", Handler: handlePermissions},
+		{Name: "history", Description: "List approved change batches", Handler: handleHistory},
+		{Name: "undo", Description: "Undo an approved change batch", Handler: handleUndo},
+		{Name: "export-patch", Description: "Save a change batch as a patch file", Handler: handleExportPatch},
 		{Name: "spinner", Description: "Change spinner style", Handler: handleSpinner},
 		{Name: "exit", Description: "Exit Bono", Handler: handleExit},
 	}
@@ -114,6 +127,7 @@ func handleClear(m *Model, arg string) tea.Cmd {
 		m.onSessionClear()
 	}
 	m.ClearMessages()
+	m.resumeContext = ""
 	m.agent.Reset()
 	m.agent.ResetCost()
 	m.sidebar.SetContextUsage(0)
@@ -124,7 +138,11 @@ func handleClear(m *Model, arg string) tea.Cmd {
 
 func handleModel(m *Model, arg string) tea.Cmd {
 	if len(m.modelModal.models) == 0 {
-		m.AppendRawMessage("No models available. Edit tui/model_catalog.go to configure models.")
+		msg := "No models available. Add models to ~/.config/bono/models.json."
+		for _, s := range m.modelModal.unreachable() {
+			msg += "\n  ⚠ " + s.String()
+		}
+		m.AppendRawMessage(msg)
 		m.input.Reset()
 		return nil
 	}
@@ -147,6 +165,10 @@ func handleReasoning(m *Model, arg string) tea.Cmd {
 			return nil
 		}
 		if arg == "none" {
+			arg = ""
+		}
+		hook := m.fireHook(hooks.ReasoningChanged, hooks.ReasoningChangedPayload{From: m.agent.ReasoningEffort(), To: arg})
+		if arg == "" {
 			m.agent.SetReasoningEffort("")
 			m.sidebar.SetReasoningEffort("")
 			m.AppendRawMessage("  Reasoning effort: disabled")
@@ -154,19 +176,226 @@ func handleReasoning(m *Model, arg string) tea.Cmd {
 			m.agent.SetReasoningEffort(arg)
 			m.sidebar.SetReasoningEffort(arg)
 			m.AppendRawMessage(fmt.Sprintf("  Reasoning effort: %s", arg))
+			if note := m.reasoningNote(); note != "" {
+				m.AppendRawMessage(note)
+			}
 		}
 		m.input.Reset()
-		return nil
+		return hook
 	}
 
 	// No argument: show modal picker.
 	m.AppendRawMessage("● /reasoning")
+	if note := m.reasoningNote(); note != "" {
+		m.AppendRawMessage(note)
+	}
 	m.input.Reset()
 	m.reasoningModal.Show(m.agent.ReasoningEffort())
 	m.recalculateLayout()
 	return nil
 }
 
+func handleProfile(m *Model, arg string) tea.Cmd {
+	m.input.Reset()
+	name := strings.TrimSpace(arg)
+	if name == "" {
+		m.AppendRawMessage("● /profile")
+		if len(m.profiles) == 0 {
+			m.AppendRawMessage("  ↳ No profiles defined. Add [profiles.<name>] tables to config.toml")
+			return nil
+		}
+		for _, p := range m.profiles {
+			if p == m.profile {
+				m.AppendRawMessage(fmt.Sprintf("  ↳ %s (current)", p))
+			} else {
+				m.AppendRawMessage("  ↳ " + p)
+			}
+		}
+		m.AppendRawMessage("  ↳ Switch with /profile <name>")
+		return nil
+	}
+
+	m.AppendRawMessage("● /profile " + name)
+	if m.processing {
+		m.AppendRawMessage("  ↳ Wait for the current response to finish before switching profiles")
+		return nil
+	}
+	if m.loadProfile == nil {
+		m.AppendRawMessage("  ↳ Profiles are unavailable")
+		return nil
+	}
+	p, err := m.loadProfile(name)
+	if err != nil {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ %v", err))
+		return nil
+	}
+
+	var cmds []tea.Cmd
+	if p.Model != m.agent.ModelName() {
+		cmds = append(cmds, m.fireHook(hooks.ModelChanged, hooks.ModelChangedPayload{From: m.agent.ModelName(), To: p.Model}))
+		m.agent.SetModel(p.Model)
+		cmds = append(cmds, m.warmModelLimits(p.Model))
+	}
+	m.agent.SetBaseURL(p.BaseURL)
+	m.sidebar.SetModelName(m.displayModelName(p.Model))
+	if p.Reasoning != "" {
+		effort := p.Reasoning
+		if effort == "none" {
+			effort = ""
+		}
+		if effort != m.agent.ReasoningEffort() {
+			cmds = append(cmds, m.fireHook(hooks.ReasoningChanged, hooks.ReasoningChangedPayload{From: m.agent.ReasoningEffort(), To: effort}))
+			m.agent.SetReasoningEffort(effort)
+		}
+		m.sidebar.SetReasoningEffort(effort)
+	}
+	if p.Apply != nil {
+		p.Apply()
+	}
+	m.profile = p.Name
+	m.sidebar.SetProfile(p.Name)
+
+	reasoning := m.agent.ReasoningEffort()
+	if reasoning == "" {
+		reasoning = "disabled"
+	}
+	m.AppendRawMessage(fmt.Sprintf("  ↳ Switched to profile %s: %s, reasoning %s", p.Name, p.Model, reasoning))
+	m.recalculateLayout()
+	return tea.Batch(cmds...)
+}
+
+func handlePermissions(m *Model, arg string) tea.Cmd {
+	m.input.Reset()
+	fields := strings.Fields(arg)
+	if len(fields) > 0 {
+		m.AppendRawMessage("● /permissions " + strings.Join(fields, " "))
+	} else {
+		m.AppendRawMessage("● /permissions")
+	}
+	if m.policy == nil {
+		m.AppendRawMessage("  ↳ Permission rules are unavailable")
+		return nil
+	}
+
+	rules := m.policy.List()
+	if len(fields) > 0 {
+		if len(fields) != 2 || fields[0] != "revoke" {
+			m.AppendRawMessage("  ↳ Usage: /permissions [revoke <n>]")
+			return nil
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 || n > len(rules) {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ No rule %s; run /permissions to list rules", fields[1]))
+			return nil
+		}
+		if err := m.policy.Revoke(rules[n-1]); err != nil {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Revoke failed: %v", err))
+			return nil
+		}
+		m.AppendRawMessage("  ↳ Revoked " + rules[n-1].String())
+		return nil
+	}
+
+	if len(rules) == 0 {
+		m.AppendRawMessage("  ↳ No rules. Answer a tool approval with s, p or a to remember it.")
+		return nil
+	}
+	for i, r := range rules {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ %d. %s", i+1, r))
+	}
+	m.AppendRawMessage("  ↳ Remove one with /permissions revoke <n>")
+	return nil
+}
+
+func handleHistory(m *Model, arg string) tea.Cmd {
+	m.input.Reset()
+	m.AppendRawMessage("● /history")
+	batches, err := m.history.List()
+	if err != nil {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ %v", err))
+		return nil
+	}
+	if len(batches) == 0 {
+		m.AppendRawMessage("  ↳ No approved change batches yet")
+		return nil
+	}
+	for i, b := range batches {
+		line := fmt.Sprintf("  ↳ %d. %s", i+1, b.Summary())
+		if b.Undone() {
+			line += " [undone]"
+		}
+		m.AppendRawMessage(line)
+	}
+	return nil
+}
+
+func handleUndo(m *Model, arg string) tea.Cmd {
+	m.input.Reset()
+	arg = strings.TrimSpace(arg)
+	if arg != "" {
+		m.AppendRawMessage("● /undo " + arg)
+	} else {
+		m.AppendRawMessage("● /undo")
+	}
+	if m.processing {
+		m.AppendRawMessage("  ↳ Wait for the agent to finish before undoing changes")
+		return nil
+	}
+	n := 0
+	if arg != "" {
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < 1 {
+			m.AppendRawMessage("  ↳ Usage: /undo [n] (n from /history)")
+			return nil
+		}
+		n = v
+	}
+	batch, err := m.history.Undo(n)
+	if err != nil {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ Undo failed: %v", err))
+		return nil
+	}
+	m.AppendRawMessage("  ↳ Undone " + batch.Summary())
+	return refreshGitStatus
+}
+
+func handleExportPatch(m *Model, arg string) tea.Cmd {
+	m.input.Reset()
+	fields := strings.Fields(arg)
+	m.AppendRawMessage(strings.TrimSpace("● /export-patch " + strings.Join(fields, " ")))
+	n := 1
+	if len(fields) > 0 {
+		if v, err := strconv.Atoi(fields[0]); err == nil {
+			if v < 1 {
+				m.AppendRawMessage("  ↳ Usage: /export-patch [n] [file] (n from /history)")
+				return nil
+			}
+			n = v
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 1 {
+		m.AppendRawMessage("  ↳ Usage: /export-patch [n] [file] (n from /history)")
+		return nil
+	}
+	batch, err := m.history.Get(n)
+	if err != nil {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ Export failed: %v", err))
+		return nil
+	}
+	path := "bono-" + batch.ID + ".patch"
+	if len(fields) == 1 {
+		path = fields[0]
+	}
+	if err := changebatch.SavePatch(path, batch.Changes); err != nil {
+		m.AppendRawMessage(fmt.Sprintf("  ↳ Export failed: %v", err))
+		return nil
+	}
+	m.AppendRawMessage(fmt.Sprintf("  ↳ Wrote %s · %s", path, batch.Summary()))
+	m.AppendRawMessage("  ↳ Apply it with git apply " + path)
+	return nil
+}
+
 func handleSpinner(m *Model, arg string) tea.Cmd {
 	if strings.TrimSpace(arg) == "" {
 		// Cycle to next spinner
@@ -212,10 +441,15 @@ func handleIndex(m *Model, arg string) tea.Cmd {
 
 	ctx := m.ctx
 	prog := m.program
+	d := m.dispatcher
 
 	return tea.Batch(
 		m.spinnerBar.Tick(),
 		func() tea.Msg {
+			root, _ := filepath.Abs(".")
+			if d != nil {
+				d.Fire(ctx, hooks.IndexStart, hooks.IndexStartPayload{Root: root})
+			}
 			stats, err := codeSearchService.CodeSearchIndex(ctx, ".", core.CodeSearchIndexOptions{},
 				func(p core.CodeSearchIndexProgress) {
 					if prog != nil {
@@ -227,6 +461,15 @@ func handleIndex(m *Model, arg string) tea.Cmd {
 					}
 				},
 			)
+			if d != nil {
+				d.Fire(ctx, hooks.IndexComplete, hooks.IndexCompletePayload{
+					Root:            root,
+					TotalFiles:      stats.TotalFiles,
+					TotalChunks:     stats.TotalChunks,
+					DurationSeconds: stats.Duration.Seconds(),
+					Err:             err,
+				})
+			}
 			return IndexDoneMsg{
 				Err:         err,
 				TotalFiles:  stats.TotalFiles,
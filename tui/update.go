@@ -2,6 +2,7 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/webforspeed/bono/hooks"
+	"github.com/webforspeed/bono/internal/permissions"
 	"github.com/webforspeed/bono/internal/session"
 )
 
@@ -66,6 +68,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// s/p/a pick an "always allow" grant for a pending tool call; Enter
+		// confirms it, so stray typing cannot widen permissions.
+		if m.pendingApproval != nil && msg.Type == tea.KeyRunes {
+			for _, g := range m.pendingApproval.Grants {
+				if session.GrantKey(g.Scope) == string(msg.Runes) {
+					m.pendingGrant = &g
+					m.spinnerBar.SetText("Enter to approve and " + g.Describe() + " · Esc to cancel")
+					return m, nil
+				}
+			}
+		}
+
+		if m.pendingBatchApproval != nil && m.handleBatchReviewKey(msg) {
+			return m, nil
+		}
+
 		if m.diffActive && msg.Type == tea.KeyTab {
 			m.diffViewer.ToggleMode()
 			m.rerenderDiffPreviews()
@@ -76,10 +94,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyEnter:
 			// If pending tool approval, approve it
 			if m.pendingApproval != nil {
-				m.pendingApproval.Approved <- true
-				m.pendingApproval = nil
+				cmd := approveTool(m.pendingApproval, m.pendingGrant)
+				m.pendingApproval, m.pendingGrant = nil, nil
 				m.spinnerBar.SetText("Thinking...")
-				return m, nil
+				return m, cmd
 			}
 			// If pending sandbox fallback approval, approve it
 			if m.pendingSandboxFallback != nil {
@@ -88,11 +106,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.spinnerBar.SetText("Running unsandboxed...")
 				return m, nil
 			}
+			// If an undo conflicts with later edits, overwrite them.
+			if m.pendingUndoConflict != nil {
+				m.resolveUndoConflict(true)
+				return m, nil
+			}
 			// If a change batch is awaiting review, approve it.
 			if m.pendingBatchApproval != nil {
 				msg := m.pendingBatchApproval
+				status := "approved"
+				if decisions := m.batchDecisions(); decisions != nil && msg.Decide != nil {
+					msg.Decide(decisions)
+					status = session.BatchReviewStatus(decisions)
+				}
+				m.reviewFile = -1
+				m.rerenderDiffPreviews()
 				if len(m.messages) > 0 {
-					m.messages[len(m.messages)-1] = m.renderBatchReviewLine(msg.Count, "approved")
+					m.messages[len(m.messages)-1] = m.renderBatchReviewLine(msg.Count, status)
 					m.updateViewportContent()
 				}
 				msg.Approved <- true
@@ -144,7 +174,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// If pending approval, reject it before quitting
 			if m.pendingApproval != nil {
 				m.pendingApproval.Approved <- false
-				m.pendingApproval = nil
+				m.pendingApproval, m.pendingGrant = nil, nil
 			}
 			if m.pendingSandboxFallback != nil {
 				m.pendingSandboxFallback.Approved <- false
@@ -156,6 +186,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.diffActive = false
 				m.diffPreviews = nil
 			}
+			if m.pendingUndoConflict != nil {
+				m.pendingUndoConflict.Approved <- false
+				m.pendingUndoConflict = nil
+			}
 			if m.pendingPlanApproval != nil {
 				m.pendingPlanApproval.Response <- planApprovalResponse{Action: 1}
 				m.pendingPlanApproval = nil
@@ -166,7 +200,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// If pending tool approval, reject it
 			if m.pendingApproval != nil {
 				m.pendingApproval.Approved <- false
-				m.pendingApproval = nil
+				m.pendingApproval, m.pendingGrant = nil, nil
 				m.spinnerBar.SetText("Thinking...")
 				// Update the message to show cancelled
 				if len(m.messages) > 0 {
@@ -187,6 +221,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			}
+			// If an undo conflicts with later edits, keep them.
+			if m.pendingUndoConflict != nil {
+				m.resolveUndoConflict(false)
+				return m, nil
+			}
 			// If a change batch is awaiting review, undo it.
 			if m.pendingBatchApproval != nil {
 				msg := m.pendingBatchApproval
@@ -279,16 +318,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.spinnerBar.SetText("Running in sandbox...")
 		} else if msg.Approved != nil && msg.ExecutionReason != "" {
 			displayStr = fmt.Sprintf("● %s [Outside sandbox: %s] [Enter/Esc]", prompt, msg.ExecutionReason)
-			m.pendingApproval = &msg
-			m.spinnerBar.SetText("Waiting for host execution approval...")
+			m.pendingApproval, m.pendingGrant = &msg, nil
+			m.spinnerBar.SetText("Waiting for host execution approval..." + grantHint(msg.Grants))
 		} else if msg.Approved == nil {
 			// Auto-approved (e.g., read_file) - just show it
 			displayStr = fmt.Sprintf("● %s", prompt)
 		} else {
 			// Needs approval - show prompt and store for Enter/Esc handling
 			displayStr = fmt.Sprintf("● %s [Enter/Esc]", prompt)
-			m.pendingApproval = &msg
-			m.spinnerBar.SetText("Waiting for approval...")
+			m.pendingApproval, m.pendingGrant = &msg, nil
+			m.spinnerBar.SetText("Waiting for approval..." + grantHint(msg.Grants))
 		}
 		m.AppendRawMessage(wrapStyle.Render(displayStr))
 
@@ -316,8 +355,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Refresh git status after tool calls (files may have changed)
 		cmds = append(cmds, refreshGitStatus)
 
+	case AgentToolDeniedMsg:
+		wrapWidth := m.mainWidth() - 2
+		if wrapWidth < 40 {
+			wrapWidth = 40
+		}
+		wrapStyle := lipgloss.NewStyle().Width(wrapWidth)
+		prompt := session.FormatTool(msg.Name, msg.Args)
+		m.AppendRawMessage(wrapStyle.Render(fmt.Sprintf("● %s => denied by policy: %s", prompt, msg.Rule)))
+
 	case AgentDiffPreviewMsg:
-		rendered := m.renderDiffPreview(msg)
+		rendered := m.renderDiffPreview(msg, -1, nil)
 		messageIndex := len(m.messages)
 		m.AppendRawMessage(rendered)
 		m.diffPreviews = append(m.diffPreviews, diffPreviewBlock{
@@ -335,7 +383,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.AppendRawMessage(wrapStyle.Render(displayStr))
 		m.pendingBatchApproval = &msg
 		m.diffActive = true
-		m.spinnerBar.SetText("Waiting for change approval...")
+		m.startBatchReview()
+		m.spinnerBar.SetText("Waiting for change approval... ([/]: hunk · space: keep/revert · x: file · tab: view)")
 
 	case AgentPreTaskStartMsg:
 		m.AppendRawMessage(fmt.Sprintf("● Running %s agent...", string(msg)))
@@ -367,11 +416,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else if msg.Approved {
 			// Plan approved — auto-trigger main agent to implement.
 			m.spinnerBar.SetText("Implementing plan...")
-			agent := m.agent
+			chat := m.chat
 			ctx := m.ctx
 			d := m.dispatcher
 			return m, tea.Batch(m.spinnerBar.Tick(), func() tea.Msg {
-				response, err := agent.Chat(ctx, "Implement the plan.")
+				response, err := chat(ctx, "Implement the plan.")
 				if d != nil {
 					d.Fire(ctx, hooks.Stop, hooks.StopPayload{Response: response, Err: err})
 				}
@@ -413,6 +462,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.pendingSandboxFallback = &msg
 		m.spinnerBar.SetText("Sandbox blocked - approve unsandboxed?")
 
+	case AgentUndoConflictMsg:
+		m.AppendRawMessage(fmt.Sprintf("● %s — Enter to overwrite them, Esc to keep them [Enter/Esc]", session.UndoConflictPrompt(msg.Changes)))
+		m.pendingUndoConflict = &msg
+		m.spinnerBar.SetText("Undo conflict - overwrite later edits?")
+
 	case AgentContextUsageMsg:
 		m.sidebar.SetContextUsage(msg.Pct)
 		m.sidebar.SetTotalCost(msg.TotalCost)
@@ -430,13 +484,37 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return ModelWarmDoneMsg{ModelID: modelID}
 		})
 
+	case AgentRoleMsg:
+		phase := string(msg.Event.Role)
+		if msg.Event.Role == session.RoleMain {
+			phase = ""
+		}
+		m.sidebar.SetPhase(phase)
+		if label := m.displayModelName(msg.Event.Model); label != "" {
+			m.sidebar.SetModelName(label)
+		}
+		m.sidebar.SetReasoningEffort(msg.Event.Reasoning)
+		m.recalculateLayout()
+
+	case AgentRetryMsg:
+		m.AppendRawMessage("  ↳ " + session.RetryNotice(msg.Event))
+		if msg.Event.Next == "" {
+			m.spinnerBar.SetText(fmt.Sprintf("Retrying in %s...", msg.Event.Delay))
+		} else {
+			m.spinnerBar.SetText("Trying " + msg.Event.Next + "...")
+		}
+
 	case ReasoningSelectedMsg:
+		cmds = append(cmds, m.fireHook(hooks.ReasoningChanged, hooks.ReasoningChangedPayload{From: m.agent.ReasoningEffort(), To: msg.Level.Value}))
 		m.agent.SetReasoningEffort(msg.Level.Value)
 		m.sidebar.SetReasoningEffort(msg.Level.Value)
 		if msg.Level.Value == "" {
 			m.AppendRawMessage("  ↳ Reasoning effort: disabled")
 		} else {
 			m.AppendRawMessage(fmt.Sprintf("  ↳ Reasoning effort: %s", msg.Level.Label))
+			if note := m.reasoningNote(); note != "" {
+				m.AppendRawMessage(note)
+			}
 		}
 		m.recalculateLayout()
 
@@ -446,18 +524,39 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.recalculateLayout()
 			break
 		}
+		cmds = append(cmds, m.fireHook(hooks.ModelChanged, hooks.ModelChangedPayload{From: m.agent.ModelName(), To: msg.Model.ID}))
 		m.agent.SetModel(msg.Model.ID)
 		m.sidebar.SetModelName(msg.Model.Name)
 		m.AppendRawMessage(fmt.Sprintf("  ↳ Switched to %s (%s)", msg.Model.Name, msg.Model.ID))
 		m.agent.SetBaseURL(msg.Model.BaseURL)
+		if !msg.Model.SupportsTools() {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Note: %s does not support tool calls; it can answer questions but cannot read, search or edit files", msg.Model.Name))
+		}
+		if m.agent.ReasoningEffort() != "" && !msg.Model.SupportsReasoning() {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Note: %s does not list reasoning support; reasoning effort %s may be ignored", msg.Model.Name, m.agent.ReasoningEffort()))
+		}
+		m.recalculateLayout()
+		cmds = append(cmds, m.warmModelLimits(msg.Model.ID))
+
+	case OllamaPullMsg:
+		cmds = append(cmds, m.modelModal.Update(msg))
+		if msg.Done && msg.Err == nil {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Pulled %s", msg.Name))
+		} else if msg.Done && !errors.Is(msg.Err, context.Canceled) {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Couldn't pull %s: %v", msg.Name, msg.Err))
+		}
+		m.recalculateLayout()
+
+	case OllamaDeleteMsg:
+		cmds = append(cmds, m.modelModal.Update(msg))
+		if msg.Err == nil {
+			m.AppendRawMessage(fmt.Sprintf("  ↳ Deleted %s", msg.Name))
+		}
+		m.recalculateLayout()
+
+	case LocalModelsMsg:
+		cmds = append(cmds, m.modelModal.Update(msg))
 		m.recalculateLayout()
-		modelID := msg.Model.ID
-		cmds = append(cmds, func() tea.Msg {
-			warmCtx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
-			defer cancel()
-			err := m.agent.WarmModelUsageLimits(warmCtx, modelID)
-			return ModelWarmDoneMsg{ModelID: modelID, Err: err}
-		})
 
 	case ModelWarmDoneMsg:
 		// Ignore warm-up results for models that are no longer active.
@@ -517,7 +616,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.AppendRawMessage(fmt.Sprintf("Error: %v", msg.Err))
 		}
 		// Response content is already handled by OnMessage hook
-		return m, nil
+		return m, m.fireHook(hooks.Notification, hooks.NotificationPayload{Kind: hooks.NotificationIdle, Message: "Waiting for your input"})
 
 	case SubmitInputMsg:
 		// This is handled by submitInput() returning a command
@@ -578,6 +677,35 @@ func refreshGitStatus() tea.Msg {
 	return GitStatusMsg{Status: FetchGitStatus()}
 }
 
+// grantHint lists the "always allow" keys for the spinner bar.
+func grantHint(grants []permissions.Grant) string {
+	if len(grants) == 0 {
+		return ""
+	}
+	parts := make([]string, len(grants))
+	for i, g := range grants {
+		parts[i] = session.GrantKey(g.Scope) + ": " + g.Describe()
+	}
+	return " (" + strings.Join(parts, " · ") + ")"
+}
+
+// approveTool approves a pending tool call, saving grant first if one was
+// picked. Saving runs in a command rather than on the Update goroutine: a
+// project grant writes a file, and a failure is reported through
+// program.Send, which blocks until Update is free to receive it.
+func approveTool(msg *AgentToolCallMsg, grant *permissions.Grant) tea.Cmd {
+	if grant == nil || msg.Choose == nil {
+		msg.Approved <- true
+		return nil
+	}
+	choose, approved, scope := msg.Choose, msg.Approved, grant.Scope
+	return func() tea.Msg {
+		choose(scope)
+		approved <- true
+		return nil
+	}
+}
+
 // renderBatchReviewLine builds a formatted batch review line with the given status suffix.
 func (m Model) renderBatchReviewLine(count int, status string) string {
 	wrapWidth := m.mainWidth() - 2
@@ -590,17 +718,25 @@ func (m Model) renderBatchReviewLine(count int, status string) string {
 }
 
 func (m *Model) rerenderDiffPreviews() {
-	for _, block := range m.diffPreviews {
+	for i, block := range m.diffPreviews {
 		if block.messageIndex >= 0 && block.messageIndex < len(m.messages) {
-			m.messages[block.messageIndex] = m.renderDiffPreview(block.preview)
+			cursor := -1
+			if i == m.reviewFile {
+				cursor = m.reviewHunk
+			}
+			m.messages[block.messageIndex] = m.renderDiffPreview(block.preview, cursor, block.reverted)
 		}
 	}
 	m.updateViewportContent()
 }
 
-func (m Model) renderDiffPreview(preview AgentDiffPreviewMsg) string {
+// renderDiffPreview renders one file of a batch; cursor and reverted carry
+// the hunk review state (-1 and nil outside review).
+func (m Model) renderDiffPreview(preview AgentDiffPreviewMsg, cursor int, reverted []bool) string {
 	viewer := NewDiffViewer()
 	viewer.viewMode = m.diffViewer.viewMode
+	viewer.cursorHunk = cursor
+	viewer.reverted = reverted
 
 	width := m.mainWidth() - 2
 	if width < 40 {
@@ -622,3 +758,20 @@ func scheduleGitStatusTick() tea.Cmd {
 		return GitStatusTickMsg{}
 	})
 }
+
+// resolveUndoConflict answers a pending undo conflict and records the outcome
+// on its prompt line.
+func (m *Model) resolveUndoConflict(overwrite bool) {
+	msg := m.pendingUndoConflict
+	m.pendingUndoConflict = nil
+	status := "kept"
+	if overwrite {
+		status = "overwritten"
+	}
+	if len(m.messages) > 0 {
+		m.messages[len(m.messages)-1] = fmt.Sprintf("● %s => %s", session.UndoConflictPrompt(msg.Changes), status)
+		m.updateViewportContent()
+	}
+	msg.Approved <- overwrite
+	m.spinnerBar.SetText("Thinking...")
+}
@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	core "github.com/webforspeed/bono-core"
+	"github.com/webforspeed/bono/internal/permissions"
+)
+
+func TestGrantKeyNeedsEnterToApprove(t *testing.T) {
+	m := NewWithOptions(&core.Agent{}, context.Background(), SpinnerDot, nil)
+	approved := make(chan bool, 1)
+	var chosen permissions.GrantScope
+	call := AgentToolCallMsg{
+		Name:     "run_shell",
+		Args:     map[string]any{"command": "go test ./pkg"},
+		Approved: approved,
+		Grants:   []permissions.Grant{{Scope: permissions.GrantProject, Rule: permissions.Rule{Tool: "run_shell", Pattern: "go test *"}}},
+		Choose:   func(scope permissions.GrantScope) { chosen = scope },
+	}
+	model, _ := m.Update(call)
+
+	model, _ = model.Update(keyRunes("a"))
+	select {
+	case <-approved:
+		t.Fatal("a grant key approved the call without Enter")
+	default:
+	}
+	if chosen != "" {
+		t.Fatalf("grant %q saved before Enter", chosen)
+	}
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("Enter did not return a command to save the grant")
+	}
+	cmd()
+	if chosen != permissions.GrantProject || !<-approved {
+		t.Errorf("after Enter: chosen = %q", chosen)
+	}
+}